@@ -1,78 +1,72 @@
 package main
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
-	"net/url"
-	"strings"
+	"os"
 	"time"
 )
 
-// extract, extract data from the Crunchbase API. Parameters: lastUUID, if empty
+// pageSize, number of entities requested per page from the Crunchbase API.
+const pageSize = 1000
+
+// extract, extract data from the Crunchbase API. Parameters: query, search
+// sent to the API, e.g. app.query or one of its partitions. lastUUID, if empty
 // string start the request from the beginning, if not use UUID of last element.
-// limit, if true limit elements in server response to 1, if false limit is
-// set to 1000.
+// limit, number of elements requested, e.g. pageSize. The request is aborted
+// if 'ctx' is cancelled. The request is built and authenticated by the search
+// backend app.backend.
 // Output []byte with response body.
-func (app *application) extract(lastUUID string, limit bool) ([]byte, error) {
-
-	// Custom CB's Searched Save URL that got randomly selected
-	// and serves as basis for CB specific payload queries.
-	url := app.cbCustomHeader.UrlReferer
+func (app *application) extract(ctx context.Context, query *Query, lastUUID string, limit int) ([]byte, error) {
 
-	// Number of entities being requested.
-	limitElements := 1000
-	if limit {
-		limitElements = 1
+	// Encode the search defined by the user's query (or the default query)
+	// with the dynamic lastUUID parameter.
+	url, err := app.backend.searchURL()
+	if err != nil {
+		return nil, err
+	}
+	payload, err := app.backend.searchPayload(query, limit, lastUUID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the payload of the search request (extract): %w", err)
 	}
-	// UVC data team search (CB) with dynamic lastUUID parameter.
-	payloadString := fmt.Sprintf(`{"field_ids":["identifier","operating_status","founded_on","ipo_status","diversity_spotlights","location_identifiers","categories","description","last_funding_type","investor_identifiers","last_funding_at","funding_total","funding_stage","investor_type","last_equity_funding_type","last_funding_total","num_funding_rounds","num_lead_investors","num_investors","semrush_visits_latest_month","semrush_visits_latest_6_months_avg","semrush_visits_mom_pct","semrush_visit_duration","semrush_visit_duration_mom_pct","semrush_visit_pageviews","semrush_visit_pageview_mom_pct","semrush_bounce_rate","semrush_bounce_rate_mom_pct","semrush_global_rank","semrush_global_rank_mom","semrush_global_rank_mom_pct","apptopia_total_apps","apptopia_total_downloads","num_founders","founder_identifiers","num_employees_enum","investor_stage","website","linkedin","num_articles","hub_tags","twitter","facebook","short_description","contact_email","last_key_employee_change_date","last_layoff_date","num_event_appearances","rank_org_company","num_contacts","num_private_contacts","builtwith_num_technologies_used","siftery_num_products","ipqwery_num_patent_granted","ipqwery_num_trademark_registered","private_tags","num_private_notes"],"order":[{"field_id":"founded_on","sort":"desc"}],"query":[{"type":"predicate","field_id":"founded_on","operator_id":"gte","include_nulls":false,"values":["2020"]},{"type":"predicate","field_id":"operating_status","operator_id":"includes","include_nulls":false},{"type":"predicate","field_id":"location_identifiers","operator_id":"includes","values":["b25caef9-a1b8-3a5d-6232-93b2dfb6a1d1","6106f5dc-823e-5da8-40d7-51612c0b2c4e"]},{"type":"predicate","field_id":"funding_stage","operator_id":"includes","values":["seed","early_stage_venture","late_stage_venture"]}],"field_aggregators":[],"collection_id":"organization.companies","limit":%d, "after_id": "%s"}`, limitElements, lastUUID)
-
-	// Create io.Reader.
-	bigPayload := strings.NewReader(payloadString)
-	// Regions: NA and Europe, no Filters for industries, early stage, after 2020
-	// bigPayload := strings.NewReader(`{"field_ids":["identifier","operating_status","founded_on","ipo_status","diversity_spotlights","location_identifiers","categories","description","last_funding_type","investor_identifiers","last_funding_at","funding_total","funding_stage","investor_type","last_equity_funding_type","last_funding_total","num_funding_rounds","num_lead_investors","num_investors","semrush_visits_latest_month","semrush_visits_latest_6_months_avg","semrush_visits_mom_pct","semrush_visit_duration","semrush_visit_duration_mom_pct","semrush_visit_pageviews","semrush_visit_pageview_mom_pct","semrush_bounce_rate","semrush_bounce_rate_mom_pct","semrush_global_rank","semrush_global_rank_mom","semrush_global_rank_mom_pct","apptopia_total_apps","apptopia_total_downloads","num_founders","founder_identifiers","num_employees_enum","investor_stage","website","linkedin","num_articles","hub_tags","twitter","facebook","short_description","contact_email","last_key_employee_change_date","last_layoff_date","num_event_appearances","rank_org_company","num_contacts","num_private_contacts","builtwith_num_technologies_used","siftery_num_products","ipqwery_num_patent_granted","ipqwery_num_trademark_registered","private_tags","num_private_notes"],"order":[{"field_id":"founded_on","sort":"desc"}],"query":[{"type":"predicate","field_id":"founded_on","operator_id":"gte","include_nulls":false,"values":["2020"]},{"type":"predicate","field_id":"operating_status","operator_id":"includes","include_nulls":false},{"type":"predicate","field_id":"location_identifiers","operator_id":"includes","values":["b25caef9-a1b8-3a5d-6232-93b2dfb6a1d1","6106f5dc-823e-5da8-40d7-51612c0b2c4e"]},{"type":"predicate","field_id":"funding_stage","operator_id":"includes","values":["seed","early_stage_venture","late_stage_venture"]}],"field_aggregators":[],"collection_id":"organization.companies","limit":1000, "after_id": "${variable}"}`)
-	// Remove after_id, use after_id for next request
-
-	// DACH, specialized industries, early stage, after 2020
-	// payload := strings.NewReader(`{"field_ids":["identifier","operating_status","founded_on","ipo_status","diversity_spotlights","location_identifiers","categories","description","last_funding_type","investor_identifiers","last_funding_at","funding_total","funding_stage","investor_type","last_equity_funding_type","last_funding_total","num_funding_rounds","num_lead_investors","num_investors","semrush_visits_latest_month","semrush_visits_latest_6_months_avg","semrush_visits_mom_pct","semrush_visit_duration","semrush_visit_duration_mom_pct","semrush_visit_pageviews","semrush_visit_pageview_mom_pct","semrush_bounce_rate","semrush_bounce_rate_mom_pct","semrush_global_rank","semrush_global_rank_mom","semrush_global_rank_mom_pct","apptopia_total_apps","apptopia_total_downloads","num_founders","founder_identifiers","num_employees_enum","investor_stage","website","linkedin","num_articles","hub_tags","twitter","facebook","short_description","contact_email","last_key_employee_change_date","last_layoff_date","num_event_appearances","rank_org_company","num_contacts","num_private_contacts","builtwith_num_technologies_used","siftery_num_products","ipqwery_num_patent_granted","ipqwery_num_trademark_registered","private_tags","num_private_notes"],"order":[{"field_id":"founded_on","sort":"desc"}],"query":[{"type":"predicate","field_id":"founded_on","operator_id":"gte","include_nulls":false,"values":["2020"]},{"type":"predicate","field_id":"operating_status","operator_id":"includes","include_nulls":false},{"type":"predicate","field_id":"location_identifiers","operator_id":"includes","values":["6085b4bf-b18a-1763-a04e-fdde3f6aba94","6d705437-ce74-b061-9864-0079d15fb639","078d9679-a862-02a2-57c8-8337e9a1eec8"]},{"type":"predicate","field_id":"category_groups","operator_id":"includes","values":["85b6bca9-930a-11bc-a608-a513b76fb637","4fe3f3ac-e522-5889-7477-c1b6d6663710","d1079d33-97d7-1f5a-7e6c-b80d5373a3e0","e5514a50-8200-7f6b-de87-b07990670800","26833aa6-0585-2aa7-8c69-63b4b14727c5","ec09d1af-e88f-6a8d-1db8-1dd5e3d49ea0","adc31356-a675-00a1-305e-8becd771319e","133d294c-e5d0-2c4f-9acc-aed0ada1fa8a","701eef4f-18c1-4aff-b550-caf732cd575f","285e29fc-8f70-bf00-1749-9e94158f64f4","2e6eafef-f310-ba60-d932-62f866a87779"]},{"type":"predicate","field_id":"funding_stage","operator_id":"includes","values":["seed","early_stage_venture","late_stage_venture"]}],"field_aggregators":[],"collection_id":"organization.companies","limit":1000}`)
 
 	// Configure a timeout for the client's HTTP request. If the request takes
 	// more than this time duration, then it should be cancelled.
 	dataRequestDuration := time.Duration(time.Minute * 2)
-	ctx, cancel := context.WithTimeout(context.Background(), dataRequestDuration)
-	// Cancelling a context releases resources associated with it,
-	// cancel should be call as soon as the operations running in a context
-	// complete.
-	defer cancel()
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bigPayload)
-	if err != nil {
-		err = fmt.Errorf("unable to create a new POST request (extract) with timeout context: %w", err)
-		return nil, err
+	// Send HTTP request, transient failures (429, 5xx) are retried.
+	send := func() (*http.Response, []byte, error) {
+		return app.doWithRetry(ctx, "extract", dataRequestDuration, func(ctx context.Context) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+			if err != nil {
+				return nil, fmt.Errorf("unable to create a new POST request (extract) with timeout context: %w", err)
+			}
+			app.backend.addHeaders(req)
+			return req, nil
+		})
 	}
-	// The general and custom headers are required to trick the
-	// Crunchbase API to think that we are not a bot.
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("User-Agent", app.cbCustomHeader.UserAgent)
-	req.Header.Add("Accept", "*/*")
-	req.Header.Add("Accept-Language", app.cbCustomHeader.AcceptLanguage)
-	req.Header.Add("Referer", url)
-
-	// Send HTTP request.
-	res, err := app.client.Do(req)
+	res, body, err := send()
 	if err != nil {
-		err = fmt.Errorf("unable to send HTTP request (extract): %w", err)
 		return nil, err
 	}
-	defer res.Body.Close()
-
-	// Read the response's body.
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		err = fmt.Errorf("unable to read response's body after HTTP req. (extract): %w", err)
-		return nil, err
+	// The session expired (or a stored session was not valid anymore),
+	// authenticate once again and request the page again.
+	if isSessionRejected(res.StatusCode) {
+		renewed, err := app.backend.renewSession(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if renewed {
+			res, body, err = send()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if isSessionRejected(res.StatusCode) {
+		return nil, fmt.Errorf("HTTP request (extract) failed with status code %d (%s): %w", res.StatusCode, res.Status, errSessionRejected)
 	}
 	// Check if we got a 200 Code response, if not return error with
 	// status code.
@@ -84,192 +78,388 @@ func (app *application) extract(lastUUID string, limit bool) ([]byte, error) {
 	return body, nil
 }
 
-// login, requests new login/auth credentials to the Crunchbase API, sets the
-// new cookies to the app.Client and if the parameter 'storeCookies' is true, it
-// stores the new cookies on a persistent external file.
-func (app *application) login(storeCookies bool) error {
-	// Create the request to get new session cookies.
-	urlSessions := "https://www.crunchbase.com/v4/cb/sessions"
-	payloadString := fmt.Sprintf(`{"email": "%s", "password": "%s"}`, app.cbUsername, app.cbPassword)
-	payload := strings.NewReader(payloadString)
-	// Configure a timeout for the client's HTTP request. If the request takes
-	// more than this time duration, then it should be cancelled.
-	loginRequestDuration := time.Duration(time.Second * 45)
-	ctx, cancel := context.WithTimeout(context.Background(), loginRequestDuration)
-	// Canceling a context releases resources associated with it, so code should
-	// call cancel as soon as the operations running in a context complete.
-	defer cancel()
-	req, err := http.NewRequestWithContext(ctx, "POST", urlSessions, payload)
+// getTotalCount, extracts the total count of elements of 'query' from the
+// source of the extraction, e.g. by sending a request for a single element to
+// the API. It outputs the total count of elements as an int and an error type.
+func (app *application) getTotalCount(ctx context.Context, query *Query) (int, error) {
+	src, err := app.newSource(query, "", nil)
 	if err != nil {
-		return fmt.Errorf("unable to create a new POST request (login) with timeout context: %w", err)
+		return 0, err
 	}
+	defer src.Close()
 
-	// The general and custom headers are required to trick the
-	// Crunchbase API to think that we are not a bot.
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("User-Agent", app.cbCustomHeader.UserAgent)
-	req.Header.Add("Accept", "*/*")
-	req.Header.Add("Accept-Language", app.cbCustomHeader.AcceptLanguage)
-	req.Header.Add("Accept-Encoding", "gzip, deflate, br")
-	req.Header.Add("Referer", "https://www.crunchbase.com/login")
-
-	app.infoLog.Print("Requesting new auth credentials from CB API.")
-	// Send request through app.client (HTTP Client).
-	res, err := app.client.Do(req)
+	totalCount, err := src.Count(ctx)
 	if err != nil {
-		return fmt.Errorf("unable to send HTTP request (login): %w", err)
+		return 0, fmt.Errorf("unable to get the total count of elements: %w", err)
 	}
-	defer res.Body.Close()
+	return totalCount, nil
+}
 
-	// Check if we got a 201 Code response (201 Created), if not return error
-	// with status code. The API returns 201 when creating new auth cookies.
-	if res.StatusCode != 201 {
-		err = fmt.Errorf("HTTP request (login) failed with status code %d (%s).\n", res.StatusCode, res.Status)
-		return err
+// extractCBData, parses and stores data from the Crunchbase API. Every page is
+// stored in the sink selected in 'opts' (a newline-delimited JSON output file
+// or a MongoDB collection) as soon as it arrives and the progress of the
+// extraction is stored in a checkpoint file. If 'opts.resume' is true, the
+// extraction continues from the checkpoint of a previous run, instead of
+// starting at page one.
+// A query matching more entities than the max partition size is split into
+// disjoint partitions, which are extracted one after the other. Entities
+// which were already retrieved (same UUID) are not stored twice.
+// If 'ctx' is cancelled (Ctrl+C or SIGTERM), the extraction stops, the pages
+// retrieved so far are kept in the sink and the checkpoint records where the
+// run can be resumed.
+// Every run writes a reconciliation report, a run which stops before it is
+// complete writes a report marked as incomplete and returns an error.
+func (app *application) extractCBData(ctx context.Context, opts extractOptions) (err error) {
+	// Load the checkpoint of a previous run or start a new run.
+	cp, output, err := app.startCheckpoint(opts)
+	if err != nil {
+		return fmt.Errorf("unable to initialize the checkpoint of the extraction: %w", err)
 	}
+	app.infoLog.Printf("Extraction run ID: %s, extracted pages are stored in %s.", cp.RunId, output.location())
 
-	// Transform Crunchbase's API URL into *url.URL.
-	urlObj, err := url.Parse("https://www.crunchbase.com/v4/")
+	complete := false
+	defer func() {
+		if complete {
+			return
+		}
+		report := newIncompleteReport(cp, app.query, err)
+		app.logReport(report)
+		if saveErr := report.save(reportFileName(cp.RunId)); saveErr != nil {
+			app.errorLog.Printf("The report of the incomplete run %s could not be stored: %v", cp.RunId, saveErr)
+		}
+	}()
+
+	// The raw entities of every page are archived, so that the documents can
+	// be derived again later on without requesting Crunchbase again.
+	archive, err := app.openArchive(cp, opts.resume)
 	if err != nil {
-		return fmt.Errorf("unable to parse CB url: %w", err)
+		output.close()
+		return fmt.Errorf("unable to initialize the raw archive of run %s: %w", cp.RunId, err)
+	}
+	if archive != nil {
+		// The archive of a complete run is closed before the output is
+		// committed, this closes it if the run stops before.
+		defer func() {
+			if closeErr := archive.close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}()
+		app.infoLog.Printf("The raw entities are archived in %s.", archive.path)
+	}
+
+	// Checkpoints stored before the properties were observed have no schema.
+	if cp.Schema == nil {
+		cp.Schema = propertySchema{}
 	}
-	// Add retrieved cookies to cookie jar from app.client object.
-	app.client.Jar.SetCookies(urlObj, res.Cookies())
 
-	if storeCookies {
-		// Store newly created cookies in an external file to guarantee
-		// persistency of cookies between different program executions.
-		if err = app.storeCookies(res); err != nil {
-			return fmt.Errorf("unable to store cookies in external file: %w", err)
+	// A resumed run keeps the partitions of the checkpoint.
+	if len(cp.Partitions) == 0 {
+		// Get the total count of elements for a particular request.
+		totalCount, err := app.getTotalCount(ctx, app.query)
+		if err != nil {
+			output.close()
+			return fmt.Errorf("unable to retrieve the total count of entries from an API request: %w", err)
+		}
+		cp.TotalCount = totalCount
+		cp.Partitions, err = app.partitionQuery(ctx, totalCount, time.Now())
+		if err != nil {
+			if ctx.Err() != nil {
+				return app.interruptExtraction(cp, output)
+			}
+			output.close()
+			return fmt.Errorf("unable to partition the query: %w", err)
+		}
+		if err := cp.save(checkpointFile); err != nil {
+			output.close()
+			return fmt.Errorf("unable to store the checkpoint of the extraction: %w", err)
 		}
 	}
-	// After a sucessful cookie retrieval, sleep for a randomly-generated amount
-	// of seconds, in order to simulate a more human-like online behaviour.
-	// A human does not send an API request nanoseconds after logging in.
-	delay, err := app.calculateRandomDelay(app.userConfigurations.minDelayLogin, app.userConfigurations.maxDelayLogin)
+
+	// UUIDs of all entities stored so far, used to drop duplicates.
+	seen, err := output.storedUUIDs()
 	if err != nil {
-		return fmt.Errorf("unable to create a random time delay after retrieval of the cookies: %w", err)
+		output.close()
+		return fmt.Errorf("unable to read the entities already stored in %s: %w", output.location(), err)
 	}
-	app.infoLog.Printf("Delay after a successful cookie retrieval: %ds.\n", delay)
-	time.Sleep(time.Duration(delay) * time.Second)
 
-	return nil
+	for cp.Partition < len(cp.Partitions) {
+		if len(cp.Partitions) > 1 {
+			app.infoLog.Printf("Extracting partition %d/%d (%s, %d entities).", cp.Partition+1, len(cp.Partitions), cp.Partitions[cp.Partition], cp.Partitions[cp.Partition].Count)
+			// The count requests of the partitioning or the previous
+			// partition were sent right before.
+			if err := app.pageDelay(ctx); err != nil {
+				return app.interruptExtraction(cp, output)
+			}
+		}
+		done, err := app.extractPartition(ctx, cp, output, archive, seen)
+		if err != nil || done {
+			return err
+		}
+		// The next partition (or the retry of an incomplete partition)
+		// starts at its first page.
+		cp.LastUUID = ""
+		if p := &cp.Partitions[cp.Partition]; !p.complete() && p.Retries < maxPartitionRetries {
+			p.retry()
+			app.errorLog.Printf("Partition %s is incomplete (%d of %d entities), extracting it again from the first page (retry %d/%d).", p, p.Written+p.Quarantined, p.Count, p.Retries, maxPartitionRetries)
+		} else {
+			cp.Partition++
+		}
+		if err := cp.save(checkpointFile); err != nil {
+			output.close()
+			return fmt.Errorf("unable to store the checkpoint of the extraction: %w", err)
+		}
+	}
+	cp.Partition = len(cp.Partitions)
+
+	// A run whose archive is not completely written is not complete, it
+	// keeps its checkpoint and can be resumed.
+	if archive != nil {
+		if err := archive.close(); err != nil {
+			output.close()
+			return fmt.Errorf("run %s: %w", cp.RunId, err)
+		}
+	}
+	complete = true
 
-}
+	// Finalize the sink, e.g. atomically move the complete output file to its
+	// final path.
+	if err := output.commit(); err != nil {
+		return err
+	}
+	app.infoLog.Printf("%d entities were stored (run %s, sink: %s).", output.entitiesWritten(), cp.RunId, cp.Sink)
 
-// getTotalCount, sends a request to the API for a single element, and extracts
-// the total count of elements for that particular request. It outputs the total
-// count of elements as an int and an error type.
-func (app *application) getTotalCount() (int, error) {
-	// Limit results in response to only 1 element (parameter true). Start with
-	// the first element of the results, lastUUID = "".
-	payload, err := app.extract("", true)
-	if err != nil {
-		err = fmt.Errorf("unable to extract data from the Crunchbase API to get the total count of elements: %w", err)
-		return 0, err
+	// Check that the paging neither skipped nor repeated entities.
+	report := newReconciliationReport(cp, app.query)
+	app.logReport(report)
+	if err := report.save(reportFileName(cp.RunId)); err != nil {
+		return err
 	}
 
-	// Create a data container, from which the total count can be extracted.
-	dataContainer := DataContainer{}
-	err = json.Unmarshal(payload, &dataContainer)
-	if err != nil {
-		err = fmt.Errorf("unable to parse the JSON-encoded payload into the custom dataContainer data model: %w", err)
-		return 0, err
+	// Check whether Crunchbase added, removed or changed properties since the
+	// last full run. Replays do not receive raw entities.
+	if app.replayFile == "" {
+		if err := app.reportDrift(schemaStateFile, cp, report.Reconciled); err != nil {
+			return err
+		}
 	}
-	// Extract the total count value from the dataContainer.
-	totalCount := dataContainer.Count
 
-	return totalCount, nil
+	// Remember the run (full or incremental), so that the next incremental
+	// run only requests the entities changed since this run started. A run
+	// which does not reconcile is not remembered, so that the next run
+	// requests the missing entities again. Replays did not request
+	// Crunchbase and are not remembered either.
+	if app.replayFile == "" && report.Reconciled {
+		if err := app.recordSuccessfulRun(extractStateFile, cp); err != nil {
+			return err
+		}
+	}
 
+	// The run is complete, it does not have to be resumed anymore.
+	if err := removeCheckpoint(checkpointFile); err != nil {
+		return err
+	}
+	if !report.Reconciled {
+		return fmt.Errorf("run %s, check the report %s: %w", cp.RunId, reportFileName(cp.RunId), errNotReconciled)
+	}
+	return nil
 }
 
-// extractCBData, parses and stores data from the Crunchbase API.
-func (app *application) extractCBData() error {
-
-	// Get the total count of elements for a particular request.
-	totalCount, err := app.getTotalCount()
+// extractPartition, retrieves all pages of the current partition of the
+// checkpoint 'cp' and stores the entities which are not in 'seen' in
+// 'output'. The raw entities of every page are stored in 'archive', if it is
+// not nil. If the extraction has to stop (cancelled, blocked by the API or a
+// failure), the sink is closed and 'done' is true or an error is returned.
+func (app *application) extractPartition(ctx context.Context, cp *checkpoint, output documentSink, archive *rawArchive, seen map[string]bool) (done bool, err error) {
+	p := &cp.Partitions[cp.Partition]
+	query := app.query
+	if len(cp.Partitions) > 1 {
+		query = p.apply(app.query, app.collection.partitionField)
+	}
+	// The properties of every received page are observed for the drift
+	// report and the page is archived.
+	received := func(body []byte) {
+		cp.Schema.observePage(body)
+		if archive != nil {
+			archive.receive(body)
+		}
+	}
+	// In a new partition the source starts with the first page, in a resumed
+	// run it continues after the last entity of the checkpoint.
+	src, err := app.newSource(query, cp.LastUUID, received)
 	if err != nil {
-		return fmt.Errorf("unable to retrieve the total count of entries from an API request: %w", err)
+		output.close()
+		return true, err
 	}
-
-	// Initialize the variables needed for every iteration of the for-loop
-	// extracting the Crunchbase data. Implicit initializations are always
-	// better to convey the value to other people reading the codebase.
-	lastUUID := ""
-	// Important change: if one initializes the slice with make and the length
-	// of all expected entities/companies, then the condition for the for-loop
-	// below always will fail, since the length of the slice is no longer 0.
-	// But already the size of all expected elements. Appending new elements
-	// should not be a costly operations anyways.
-	organizationDocumentSlice := []OrganizationDocument{}
-
-	for len(organizationDocumentSlice) < totalCount {
-		// In the first iteration, lastUUID equals "", lastUUID is empty.
-		payload, err := app.extract(lastUUID, false)
+	defer src.Close()
+
+	for !p.complete() {
+		page, err := src.NextPage(ctx)
+		// A source without further entities would return the same page
+		// forever.
+		if err == io.EOF {
+			app.errorLog.Print("The source returned no new entities, stopping the extraction of this partition.")
+			return false, nil
+		}
 		if err != nil {
-			if len(organizationDocumentSlice) > 0 {
-				err = fmt.Errorf("unable to extract further data from the API (the API probably identifies the script as a bot), some results were successfully retrieved and will be exported to a JSON file: %w", err)
+			// The extraction was interrupted by the user, not by the API.
+			if ctx.Err() != nil {
+				return true, app.interruptExtraction(cp, output)
+			}
+			if output.entitiesWritten() > 0 {
+				err = fmt.Errorf("unable to extract further data from the API (the API probably identifies the script as a bot), %d results were successfully retrieved and are stored in %s, the run can be continued with --resume: %w", output.entitiesWritten(), output.location(), err)
 				app.errorLog.Print(err)
 				// There was a problem while extracting data from the Crunchbase
 				// API (the API probably blocks all of our requests because it
-				// thinks this script is a bot, but some data (entities) were
-				// already stored into the organizationDocumentSlice (since its
-				// length is larger than 0), therefore break out of the for-loop
-				// to stop sending more requests to the API and store the
-				// entities into a temporary file.
-				break
+				// thinks this script is a bot), but some data (entities) were
+				// already stored in the sink. Stop sending more requests to the
+				// API and keep both the sink's partial output and the
+				// checkpoint, so that the run can be resumed later on.
+				if err := output.close(); err != nil {
+					return true, err
+				}
+				return true, fmt.Errorf("run %s was blocked by the API: %w", cp.RunId, errIncomplete)
 			}
-			// No entities were stored before getting blocked by the API, so
-			// just return from this method without storing any data into an
-			// external file.
-			return fmt.Errorf("unable to extract any data from the API (bot detection), no results can be exported: %w", err)
+			// No entities were stored before getting blocked by the API.
+			output.close()
+			return true, fmt.Errorf("unable to extract any data from the API (bot detection), no results can be exported: %w", err)
 		}
 
-		// This is the slice that will be stored into a text file, it grows with
-		// each iteration of the for-loop.
-		err = decodeBody(payload, &organizationDocumentSlice)
-		if err != nil {
-			return fmt.Errorf("unable to decode the parsed body which was received from the Crunchbase API: %w", err)
+		// Archive the page as received, including duplicates and entities
+		// which cannot be parsed.
+		if archive != nil {
+			if err := archive.writePage(cp.RunId, app.collection.id); err != nil {
+				output.close()
+				return true, fmt.Errorf("unable to archive page in %s: %w", archive.path, err)
+			}
+		}
+
+		// Entities which could not be parsed are kept in the quarantine file
+		// instead of the sink.
+		parsed, quarantinedPage := splitQuarantined(page)
+		// Drop the entities which were already stored, e.g. if the paging
+		// returned an entity twice.
+		unique := make([]document, 0, len(parsed))
+		for _, document := range parsed {
+			if !markSeen(seen, document.entityUuid()) {
+				unique = append(unique, document)
+			}
+		}
+		quarantined := make([]quarantinedEntity, 0, len(quarantinedPage))
+		for _, entity := range quarantinedPage {
+			if !markSeen(seen, entityKey(entity.Uuid, entity.Raw)) {
+				quarantined = append(quarantined, entity)
+			}
+		}
+		if len(quarantined) > 0 {
+			if err := appendQuarantine(quarantineFileName(cp.RunId), quarantined); err != nil {
+				output.close()
+				return true, err
+			}
+			for _, entity := range quarantined {
+				app.errorLog.Printf("Entity %s was quarantined in %s: %s", entity.Uuid, quarantineFileName(cp.RunId), entity.Reason)
+			}
+		}
+		if len(unique) > 0 {
+			if err := output.writePage(unique); err != nil {
+				output.close()
+				return true, fmt.Errorf("unable to store page in %s: %w", output.location(), err)
+			}
+		}
+		p.Fetched += len(page)
+		p.Written += len(unique)
+		p.Quarantined += len(quarantined)
+		p.Pages++
+		// Only the last page of a partition is expected to be incomplete.
+		if len(page) < pageSize && !p.complete() {
+			p.ShortPages++
+			app.errorLog.Printf("The source returned %d entities instead of %d, although the partition is not complete yet (after_id: %s).", len(page), pageSize, cp.LastUUID)
 		}
 
-		// Parse lastUUID from last API response.
-		lastUUID = organizationDocumentSlice[len(organizationDocumentSlice)-1].Uuid
+		// Persist the progress, so that the run can be resumed from this page.
+		// A page whose entities have no UUID cannot advance the paging, it is
+		// stopped by the check below.
+		afterId := cp.LastUUID
+		if uuid := lastUUID(page); uuid != "" {
+			cp.LastUUID = uuid
+		}
+		output.updateCheckpoint(cp)
+		if archive != nil {
+			archive.updateCheckpoint(cp)
+		}
+		if err := cp.save(checkpointFile); err != nil {
+			output.close()
+			return true, fmt.Errorf("unable to store the checkpoint of the extraction: %w", err)
+		}
 		// Output the total number of entities extracted sofar, important metric
 		// to check consistency in number of extractions.
-		app.infoLog.Printf("Total # of entities extracted sofar: %d.", len(organizationDocumentSlice))
+		app.infoLog.Printf("Total # of entities extracted sofar: %d.", output.entitiesWritten())
 		// The program has already fetched and parsed all the available data
 		// so it can leave the for-loop without going through a last delay.
-		if len(organizationDocumentSlice) >= totalCount {
+		if p.complete() {
 			break
 		}
-		// Generate a random delay with a max and min delay constraints.
-		delay, err := app.calculateRandomDelay(app.userConfigurations.minDelayExtract, app.userConfigurations.maxDelayExtract)
-		if err != nil {
-			return fmt.Errorf("unable to create a random delay: %w", err)
+		// A source whose paging does not advance would return the same page
+		// forever.
+		if cp.LastUUID == afterId {
+			app.errorLog.Printf("The source returned the same page again, stopping the extraction of this partition (after_id: %s).", cp.LastUUID)
+			return false, nil
+		}
+		if err := app.pageDelay(ctx); err != nil {
+			if ctx.Err() != nil {
+				return true, app.interruptExtraction(cp, output)
+			}
+			output.close()
+			return true, err
 		}
-		app.infoLog.Printf("Delay until next API request: %ds\n", delay)
-		time.Sleep(time.Duration(delay) * time.Second)
+	}
+
+	return false, nil
+}
 
+// interruptExtraction, stops an extraction which was cancelled by the user.
+// The pages retrieved so far are committed to the normal output of the sink,
+// and the checkpoint (which is stored after every page) records where the run
+// can be resumed.
+func (app *application) interruptExtraction(cp *checkpoint, output documentSink) error {
+	if err := output.commit(); err != nil {
+		return fmt.Errorf("extraction was interrupted and the sink could not be committed: %w", err)
 	}
+	if output.entitiesWritten() == 0 {
+		return fmt.Errorf("extraction was interrupted before any entity was retrieved: %w", errIncomplete)
+	}
+	return fmt.Errorf("extraction was interrupted, %d entities are stored in %s, continue run %s with --resume (after_id: %s): %w", output.entitiesWritten(), output.location(), cp.RunId, cp.LastUUID, errIncomplete)
+}
 
-	// Encode the organizationDocument slice in JSON format, so that it can be
-	// exported into an external file.
-	organizationDocumentSliceJSON, err := json.Marshal(organizationDocumentSlice)
-	if err != nil {
-		return fmt.Errorf("unable to marshal []OrganizationDocument to JSON: %w", err)
+// startCheckpoint, returns the checkpoint and the sink of the previous run if
+// 'opts.resume' is true, otherwise it returns the checkpoint and the sink of a
+// new run.
+func (app *application) startCheckpoint(opts extractOptions) (*checkpoint, documentSink, error) {
+	if opts.resume {
+		cp, err := loadCheckpoint(checkpointFile, app.query)
+		if err != nil {
+			return nil, nil, err
+		}
+		if cp.Sink != opts.sink {
+			return nil, nil, fmt.Errorf("run %s used the sink '%s', it cannot be resumed with the sink '%s'", cp.RunId, cp.Sink, opts.sink)
+		}
+		output, err := app.newSink(opts.sink, cp, true)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to reopen the sink of run %s: %w", cp.RunId, err)
+		}
+		app.infoLog.Printf("Resuming run %s after %d entities (after_id: %s).", cp.RunId, cp.EntitiesWritten, cp.LastUUID)
+		return cp, output, nil
 	}
 
-	// Create temp file in which to store data.
-	f, err := createTempFile()
+	if _, err := os.Stat(checkpointFile); err == nil {
+		app.infoLog.Printf("The checkpoint file %s of a previous run will be overwritten, use --resume to continue a previous run.", checkpointFile)
+	}
+	cp, err := app.newCheckpoint(app.query, opts.sink)
 	if err != nil {
-		return fmt.Errorf("unable to create temp file to store data: %w", err)
+		return nil, nil, err
 	}
-	defer f.Close()
-	// Write data to temp file.
-	if _, err := f.Write(organizationDocumentSliceJSON); err != nil {
-		return fmt.Errorf("unable to write []OrganizationDocument to temp file: %w", err)
+	output, err := app.newSink(opts.sink, cp, false)
+	if err != nil {
+		return nil, nil, err
 	}
-
-	return nil
-
+	return cp, output, nil
 }
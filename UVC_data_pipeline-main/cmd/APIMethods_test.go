@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/erodrigufer/UVC_data_pipeline/internal/fixture"
+)
+
+// extractFixtures, fixtures of the extraction of the default query: the
+// login, the count request and a single page with all 3 entities of
+// payloadbody.json.
+const extractFixtures = "testdata/extract-organizations.fixtures.ndjson"
+
+// newFixtureTestApplication, returns an application which extracts the
+// default query with the cookie backend, without delays between requests.
+func newFixtureTestApplication() *application {
+	app := newRetryTestApplication(0)
+	app.cbCustomHeader = &CBCustomHeader{
+		UserAgent:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_11_2) AppleWebKit/601.3.9 (KHTML, like Gecko) Version/9.0.2 Safari/601.3.9",
+		AcceptLanguage: "en-US,en;q=0.5",
+		UrlReferer:     "https://www.crunchbase.com/v4/data/lists/organization.companies/343cbe1f-7511-4263-939c-0c3f3f7a729d?source=list",
+	}
+	app.query = defaultQuery()
+	app.collection = entityCollections[defaultCollectionId]
+	app.backend = newCookieBackend(app)
+	return app
+}
+
+func TestExtractWithFixtures(t *testing.T) {
+	replayer, err := fixture.OpenReplayer(extractFixtures)
+	if err != nil {
+		t.Fatalf("OpenReplayer() error = %v", err)
+	}
+	app := newFixtureTestApplication()
+	app.transport = replayer
+	if err := app.configureClient(true); err != nil {
+		t.Fatalf("configureClient() error = %v", err)
+	}
+
+	// The checkpoint, the output file and the report are stored in the
+	// working directory.
+	chdirTemp(t)
+
+	ctx := context.Background()
+	if err := app.handleAuthentication(ctx); err != nil {
+		t.Fatalf("handleAuthentication() error = %v", err)
+	}
+	cbURL, _ := url.Parse("https://www.crunchbase.com/v4/")
+	if cookies := app.client.Jar.Cookies(cbURL); len(cookies) == 0 {
+		t.Errorf("login did not set any session cookie")
+	}
+	if err := app.extractCBData(ctx, extractOptions{sink: sinkFile}); err != nil {
+		t.Fatalf("extractCBData() error = %v", err)
+	}
+
+	outputs, _ := filepath.Glob("CBData_*.ndjson")
+	if len(outputs) != 1 {
+		t.Fatalf("found output files %v, want exactly one", outputs)
+	}
+	fileData, err := os.ReadFile(outputs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	documents, err := unmarshalFile(fileData, app.collection.newDocument)
+	if err != nil {
+		t.Fatalf("unmarshalFile() error = %v", err)
+	}
+	if len(documents) != 3 {
+		t.Errorf("output contains %d documents, want 3", len(documents))
+	}
+	if unserved := replayer.Unserved(); unserved != 0 {
+		t.Errorf("%d recorded interactions were not requested", unserved)
+	}
+}
+
+func TestExtractFixtureErrors(t *testing.T) {
+	interactions, err := fixture.ReadFile(extractFixtures)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	login, count := interactions[0], interactions[1]
+	expiredLogin := login
+	expiredLogin.Response = fixture.Response{StatusCode: http.StatusUnauthorized}
+
+	tests := []struct {
+		name         string
+		interactions []fixture.Interaction
+		wantCount    int
+		wantErr      bool
+		// wantErrIs, error wrapped by the returned error, if it is not nil.
+		wantErrIs error
+	}{
+		{
+			name:         "Total count",
+			interactions: []fixture.Interaction{login, count},
+			wantCount:    3,
+		},
+		{
+			name:         "Failed login",
+			interactions: []fixture.Interaction{expiredLogin, count},
+			wantErr:      true,
+		},
+		{
+			name:         "Request which was not recorded",
+			interactions: []fixture.Interaction{login},
+			wantErr:      true,
+			wantErrIs:    fixture.ErrNotRecorded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newFixtureTestApplication()
+			app.transport = fixture.NewReplayer(tt.interactions)
+			if err := app.configureClient(true); err != nil {
+				t.Fatalf("configureClient() error = %v", err)
+			}
+
+			ctx := context.Background()
+			err := app.handleAuthentication(ctx)
+			var got int
+			if err == nil {
+				got, err = app.getTotalCount(ctx, app.query)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErrIs != nil && !errors.Is(err, tt.wantErrIs) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErrIs)
+			}
+			if got != tt.wantCount {
+				t.Errorf("getTotalCount() = %d, want %d", got, tt.wantCount)
+			}
+		})
+	}
+}
@@ -21,6 +21,10 @@ type application struct {
 	infoLog *log.Logger
 	// client, http.Client that deals with HTTP requests to Crunchbase API.
 	client *http.Client
+	// transport, http.RoundTripper injected into the client, e.g. a replayer
+	// of recorded fixtures. If it is nil, the client uses the default
+	// transport or a proxy.
+	transport http.RoundTripper
 	// mongoDB, is a client for a MongoDB instance running locally.
 	mongoDB *mongodb.MongoDBInstance
 	// mongoDBURI, the URI of the db to which the script will attempt a
@@ -29,9 +33,26 @@ type application struct {
 	// cbCustomHeader, CB based custom HTTP headers that changes for each new
 	// run of the script, to make API calls less suspicious.
 	cbCustomHeader *CBCustomHeader
+	// cbCustomConfigHeaders, possible values of the CB custom HTTP headers,
+	// from which cbCustomHeader is picked.
+	cbCustomConfigHeaders CBCustomConfigHeaders
 	// seededRand, is a *rand.Rand instance seeded from a unique source, used to
 	// generate random numbers.
 	seededRand *rand.Rand
+	// query, Crunchbase search sent by the 'extract' command, either loaded
+	// from a query file or the default query.
+	query *Query
+	// collection, Crunchbase collection searched by the query.
+	collection *entityCollection
+	// incrementalKey, hash of the query before it was restricted to the
+	// entities changed since the last run. It is only set for incremental
+	// extractions, and identifies the query in the extract state file (full
+	// runs are identified by the hash of app.query).
+	incrementalKey string
+	// incrementalSince, day since which app.query requests the changed
+	// entities, zero if the query requests all entities (e.g. the first
+	// incremental run).
+	incrementalSince time.Time
 	// userConfigurations is the struct that stores all the user-defined
 	// configuration values.
 	userConfigurations userConfigurations
@@ -43,6 +64,19 @@ type application struct {
 	cbUsername string
 	// cbPassword, password of the CB account which is used for requests.
 	cbPassword string
+	// cbBaseURL, base URL which replaces https://www.crunchbase.com in all
+	// requests of the 'cookie' backend, e.g. a local fake of Crunchbase. If
+	// it is empty, the requests are sent to Crunchbase.
+	cbBaseURL string
+	// cbAPIKey, key of the licensed Crunchbase API v4, used by the 'api'
+	// backend.
+	cbAPIKey string
+	// backend, how the search requests are sent to Crunchbase, e.g. the web
+	// search with the session of a CB account (see searchBackends).
+	backend searchBackend
+	// replayFile, path to a file with already extracted entities, which is
+	// used as source of the extraction instead of Crunchbase.
+	replayFile string
 }
 
 // userConfigurations, stores the user-defined configurations which can be
@@ -62,6 +96,54 @@ type userConfigurations struct {
 	// minDelayExtract, defines the minimal amount of delay (in s) to wait,
 	// after extracting data from the API.
 	minDelayExtract int
+	// maxRetries, defines how many times a request to the API is retried
+	// after a transport error, a 429 or a 5xx response.
+	maxRetries int
+	// baseBackoff, defines the delay (in s) before the first retry, the delay
+	// doubles with every further retry.
+	baseBackoff int
+	// maxBackoff, defines the maximal amount of delay (in s) between two
+	// retries, unless the API requests a longer delay with Retry-After.
+	maxBackoff int
+	// maxPartitionSize, defines the maximal number of entities extracted with
+	// a single search, larger queries are split into partitions. If it is 0,
+	// queries are never partitioned.
+	maxPartitionSize int
+}
+
+// extractOptions, options of the 'extract' command which are set through
+// flags.
+type extractOptions struct {
+	// noProxy, if true no proxy connection is established to extract data
+	// from CB.
+	noProxy bool
+	// queryFile, path to a YAML or JSON query file.
+	queryFile string
+	// profile, name of a query profile.
+	profile string
+	// resume, if true continue the run of the stored checkpoint.
+	resume bool
+	// sink, destination of the extracted pages, 'file' or 'mongo'.
+	sink string
+	// remote, IP address of the MongoDB instance used by the mongo sink.
+	remote string
+	// dryRun, if true only the total count of entities is requested and the
+	// size and duration of the extraction are estimated.
+	dryRun bool
+	// incremental, if true only the entities changed since the last
+	// successful run of the query are extracted.
+	incremental bool
+	// backend, 'cookie' (web search) or 'api' (Crunchbase API v4).
+	backend string
+	// replay, path to a file with already extracted entities, which are
+	// replayed instead of requesting Crunchbase.
+	replay string
+	// recordFixtures, path to a fixture file in which all requests sent to
+	// Crunchbase and their responses are recorded.
+	recordFixtures string
+	// replayFixtures, path to a fixture file whose recorded responses are
+	// served instead of sending requests to Crunchbase.
+	replayFixtures string
 }
 
 // DataContainer, type of data container that unpacks Crunchbase JSON output
@@ -140,6 +222,32 @@ type Properties struct {
 	NumVisitPerPageviews  float32           `json:"semrush_visit_pageviews"`
 	BounceRate            float32           `json:"semrush_bounce_rate"`
 	VisitDuration         int               `json:"semrush_visit_duration"`
+	IpoStatus             string            `json:"ipo_status"`
+	DiversitySpotlights   []Category        `json:"diversity_spotlights"`
+	InvestorType          []string          `json:"investor_type"`
+	NumLeadInvestors      int               `json:"num_lead_investors"`
+	VisitsLast6MonthsAvg  int               `json:"semrush_visits_latest_6_months_avg"`
+	VisitsMomPct          float32           `json:"semrush_visits_mom_pct"`
+	VisitDurationMomPct   float32           `json:"semrush_visit_duration_mom_pct"`
+	VisitPageviewsMomPct  float32           `json:"semrush_visit_pageview_mom_pct"`
+	BounceRateMomPct      float32           `json:"semrush_bounce_rate_mom_pct"`
+	GlobalRank            int               `json:"semrush_global_rank"`
+	GlobalRankMom         int               `json:"semrush_global_rank_mom"`
+	GlobalRankMomPct      float32           `json:"semrush_global_rank_mom_pct"`
+	NumApps               int               `json:"apptopia_total_apps"`
+	NumAppDownloads       int               `json:"apptopia_total_downloads"`
+	InvestorStage         []string          `json:"investor_stage"`
+	HubTags               []Category        `json:"hub_tags"`
+	Twitter               map[string]string `json:"twitter"`
+	LastKeyEmployeeChange string            `json:"last_key_employee_change_date"`
+	LastLayoffDate        string            `json:"last_layoff_date"`
+	NumEventAppearances   int               `json:"num_event_appearances"`
+	RankOrgCompany        int               `json:"rank_org_company"`
+	NumContacts           int               `json:"num_contacts"`
+	NumPrivateContacts    int               `json:"num_private_contacts"`
+	NumOfProducts         int               `json:"siftery_num_products"`
+	PrivateTags           []Category        `json:"private_tags"`
+	NumPrivateNotes       int               `json:"num_private_notes"`
 }
 
 // SemRush, type of SemRush that holds all the SemRush relevant data such as
@@ -149,6 +257,21 @@ type SemRush struct {
 	VisitDuration        int     `json:"sr_visit_duration" bson:"sr_visit_duration"`
 	BounceRate           float32 `json:"sr_bounce_rate" bson:"sr_bounce_rate"`
 	NumVisitPerPageviews float32 `json:"sr_visit_pageviews" bson:"sr_visit_pageviews"`
+	VisitsLast6MonthsAvg int     `json:"sr_visits_latest_6_months_avg" bson:"sr_visits_latest_6_months_avg"`
+	VisitsMomPct         float32 `json:"sr_visits_mom_pct" bson:"sr_visits_mom_pct"`
+	VisitDurationMomPct  float32 `json:"sr_visit_duration_mom_pct" bson:"sr_visit_duration_mom_pct"`
+	VisitPageviewsMomPct float32 `json:"sr_visit_pageview_mom_pct" bson:"sr_visit_pageview_mom_pct"`
+	BounceRateMomPct     float32 `json:"sr_bounce_rate_mom_pct" bson:"sr_bounce_rate_mom_pct"`
+	GlobalRank           int     `json:"sr_global_rank" bson:"sr_global_rank"`
+	GlobalRankMom        int     `json:"sr_global_rank_mom" bson:"sr_global_rank_mom"`
+	GlobalRankMomPct     float32 `json:"sr_global_rank_mom_pct" bson:"sr_global_rank_mom_pct"`
+}
+
+// Apptopia, type of Apptopia that holds the Apptopia data of the mobile apps
+// of an organization.
+type Apptopia struct {
+	NumApps      int `json:"ap_total_apps" bson:"ap_total_apps"`
+	NumDownloads int `json:"ap_total_downloads" bson:"ap_total_downloads"`
 }
 
 // OrgnizationDocument, type of OrganizationDocument that holds all the previous
@@ -157,11 +280,13 @@ type OrganizationDocument struct {
 	Uuid                  string     `json:"uuid" bson:"uuid"`
 	Timestamp             time.Time  `json:"timestamp" bson:"timestamp"`
 	EntityDefId           string     `json:"entityDefId" bson:"entityDefId"`
+	Source                string     `json:"source" bson:"source"`
 	OrganizationName      string     `json:"organizationName" bson:"organizationName"`
+	Permalink             string     `json:"permalink" bson:"permalink"`
 	Description           string     `json:"description" bson:"description"`
 	ShortDescription      string     `json:"shortDescription" bson:"shortDescription"`
 	FundingStage          string     `json:"fundingStage" bson:"fundingStage"`
-	FoundedOn             time.Time  `json:"foundedOn" bson:"foundedOn"`
+	FoundedOn             Date       `json:"foundedOn" bson:"foundedOn"`
 	OperatingStatus       string     `json:"operatingStatus" bson:"operatingStatus"`
 	Website               string     `json:"website" bson:"website"`
 	Linkedin              string     `json:"linkedin" bson:"linkedin"`
@@ -184,8 +309,28 @@ type OrganizationDocument struct {
 	LastEquityFundingType string     `json:"lastEquityFundingType" bson:"lastEquityFundingType"`
 	LastFundingType       string     `json:"lastFundingType" bson:"lastFundingType"`
 	LastFundingTotal      int        `json:"lastFundingTotal" bson:"lastFundingTotal"`
-	LastFundingAt         time.Time  `json:"lastFundingAt" bson:"lastFundingAt"`
+	LastFundingAt         Date       `json:"lastFundingAt" bson:"lastFundingAt"`
 	InvestorIdentifiers   []Person   `json:"investorIdentifiers" bson:"investorIdentifiers"`
+	IpoStatus             string     `json:"ipoStatus" bson:"ipoStatus"`
+	DiversitySpotlights   []Category `json:"diversitySpotlights" bson:"diversitySpotlights"`
+	InvestorType          []string   `json:"investorType" bson:"investorType"`
+	InvestorStage         []string   `json:"investorStage" bson:"investorStage"`
+	NumLeadInvestors      int        `json:"numLeadInvestors" bson:"numLeadInvestors"`
+	Apptopia              Apptopia   `json:"apptopia" bson:"apptopia"`
+	HubTags               []Category `json:"hubTags" bson:"hubTags"`
+	Twitter               string     `json:"twitter" bson:"twitter"`
+	LastKeyEmployeeChange Date       `json:"lastKeyEmployeeChange" bson:"lastKeyEmployeeChange"`
+	LastLayoffDate        Date       `json:"lastLayoffDate" bson:"lastLayoffDate"`
+	NumEventAppearances   int        `json:"numEventAppearances" bson:"numEventAppearances"`
+	RankOrgCompany        int        `json:"rankOrgCompany" bson:"rankOrgCompany"`
+	NumContacts           int        `json:"numContacts" bson:"numContacts"`
+	NumPrivateContacts    int        `json:"numPrivateContacts" bson:"numPrivateContacts"`
+	NumOfProducts         int        `json:"numOfProducts" bson:"numOfProducts"`
+	PrivateTags           []Category `json:"privateTags" bson:"privateTags"`
+	NumPrivateNotes       int        `json:"numPrivateNotes" bson:"numPrivateNotes"`
+	// Extras, properties received from Crunchbase which are not modelled in
+	// Properties, see unknownProperties.
+	Extras map[string]interface{} `json:"extras,omitempty" bson:"extras,omitempty"`
 }
 
 // CBCustomConfigHeaders, struct with CB custom HTTP headers that holds
@@ -205,3 +350,117 @@ type CBCustomHeader struct {
 	AcceptLanguage string
 	UserAgent      string
 }
+
+// PersonProperties, type of properties that unpacks the characteristics of a
+// person (e.g. a founder) from the 'people' collection.
+type PersonProperties struct {
+	Identifier              map[string]string `json:"identifier"`
+	FirstName               string            `json:"first_name"`
+	LastName                string            `json:"last_name"`
+	Gender                  string            `json:"gender"`
+	PrimaryJobTitle         string            `json:"primary_job_title"`
+	PrimaryOrganization     Person            `json:"primary_organization"`
+	Locations               []Location        `json:"location_identifiers"`
+	Description             string            `json:"description"`
+	Linkedin                map[string]string `json:"linkedin"`
+	Twitter                 map[string]string `json:"twitter"`
+	NumFoundedOrganizations int               `json:"num_founded_organizations"`
+	NumInvestments          int               `json:"num_investments"`
+	NumExits                int               `json:"num_exits"`
+}
+
+// PersonDocument, type of PersonDocument that holds the data of a person from
+// Crunchbase, persisted in our database.
+type PersonDocument struct {
+	Uuid                    string    `json:"uuid" bson:"uuid"`
+	Timestamp               time.Time `json:"timestamp" bson:"timestamp"`
+	EntityDefId             string    `json:"entityDefId" bson:"entityDefId"`
+	Name                    string    `json:"name" bson:"name"`
+	FirstName               string    `json:"firstName" bson:"firstName"`
+	LastName                string    `json:"lastName" bson:"lastName"`
+	Gender                  string    `json:"gender" bson:"gender"`
+	PrimaryJobTitle         string    `json:"primaryJobTitle" bson:"primaryJobTitle"`
+	PrimaryOrganization     Person    `json:"primaryOrganization" bson:"primaryOrganization"`
+	City                    string    `json:"city" bson:"city"`
+	Country                 string    `json:"country" bson:"country"`
+	Description             string    `json:"description" bson:"description"`
+	Linkedin                string    `json:"linkedin" bson:"linkedin"`
+	Twitter                 string    `json:"twitter" bson:"twitter"`
+	NumFoundedOrganizations int       `json:"numFoundedOrganizations" bson:"numFoundedOrganizations"`
+	NumInvestments          int       `json:"numInvestments" bson:"numInvestments"`
+	NumExits                int       `json:"numExits" bson:"numExits"`
+	// Extras, properties which are not modelled in PersonProperties.
+	Extras map[string]interface{} `json:"extras,omitempty" bson:"extras,omitempty"`
+}
+
+// FundingRoundProperties, type of properties that unpacks the characteristics
+// of a funding round from the 'funding_rounds' collection.
+type FundingRoundProperties struct {
+	Identifier              map[string]string `json:"identifier"`
+	AnnouncedOn             string            `json:"announced_on"`
+	FundedOrganization      Person            `json:"funded_organization_identifier"`
+	InvestmentType          string            `json:"investment_type"`
+	MoneyRaised             Funding           `json:"money_raised"`
+	PreMoneyValuation       Funding           `json:"pre_money_valuation"`
+	NumInvestors            int               `json:"num_investors"`
+	LeadInvestorIdentifiers []Person          `json:"lead_investor_identifiers"`
+	InvestorIdentifiers     []Person          `json:"investor_identifiers"`
+}
+
+// FundingRoundDocument, type of FundingRoundDocument that holds the data of a
+// funding round from Crunchbase, persisted in our database.
+type FundingRoundDocument struct {
+	Uuid                    string    `json:"uuid" bson:"uuid"`
+	Timestamp               time.Time `json:"timestamp" bson:"timestamp"`
+	EntityDefId             string    `json:"entityDefId" bson:"entityDefId"`
+	Name                    string    `json:"name" bson:"name"`
+	AnnouncedOn             Date      `json:"announcedOn" bson:"announcedOn"`
+	FundedOrganization      Person    `json:"fundedOrganization" bson:"fundedOrganization"`
+	InvestmentType          string    `json:"investmentType" bson:"investmentType"`
+	MoneyRaised             int       `json:"moneyRaised" bson:"moneyRaised"`
+	PreMoneyValuation       int       `json:"preMoneyValuation" bson:"preMoneyValuation"`
+	NumInvestors            int       `json:"numInvestors" bson:"numInvestors"`
+	LeadInvestorIdentifiers []Person  `json:"leadInvestorIdentifiers" bson:"leadInvestorIdentifiers"`
+	InvestorIdentifiers     []Person  `json:"investorIdentifiers" bson:"investorIdentifiers"`
+	// Extras, properties which are not modelled in FundingRoundProperties.
+	Extras map[string]interface{} `json:"extras,omitempty" bson:"extras,omitempty"`
+}
+
+// InvestorProperties, type of properties that unpacks the characteristics of
+// an investor (organization or person) from the 'principal.investors'
+// collection.
+type InvestorProperties struct {
+	Identifier                map[string]string `json:"identifier"`
+	InvestorType              []string          `json:"investor_type"`
+	InvestorStage             []string          `json:"investor_stage"`
+	Locations                 []Location        `json:"location_identifiers"`
+	NumInvestments            int               `json:"num_investments"`
+	NumLeadInvestments        int               `json:"num_lead_investments"`
+	NumExits                  int               `json:"num_exits"`
+	NumPortfolioOrganizations int               `json:"num_portfolio_organizations"`
+	ShortDescription          string            `json:"short_description"`
+	Website                   map[string]string `json:"website"`
+	Linkedin                  map[string]string `json:"linkedin"`
+}
+
+// InvestorDocument, type of InvestorDocument that holds the data of an
+// investor from Crunchbase, persisted in our database.
+type InvestorDocument struct {
+	Uuid                      string    `json:"uuid" bson:"uuid"`
+	Timestamp                 time.Time `json:"timestamp" bson:"timestamp"`
+	EntityDefId               string    `json:"entityDefId" bson:"entityDefId"`
+	Name                      string    `json:"name" bson:"name"`
+	InvestorType              []string  `json:"investorType" bson:"investorType"`
+	InvestorStage             []string  `json:"investorStage" bson:"investorStage"`
+	City                      string    `json:"city" bson:"city"`
+	Country                   string    `json:"country" bson:"country"`
+	NumInvestments            int       `json:"numInvestments" bson:"numInvestments"`
+	NumLeadInvestments        int       `json:"numLeadInvestments" bson:"numLeadInvestments"`
+	NumExits                  int       `json:"numExits" bson:"numExits"`
+	NumPortfolioOrganizations int       `json:"numPortfolioOrganizations" bson:"numPortfolioOrganizations"`
+	ShortDescription          string    `json:"shortDescription" bson:"shortDescription"`
+	Website                   string    `json:"website" bson:"website"`
+	Linkedin                  string    `json:"linkedin" bson:"linkedin"`
+	// Extras, properties which are not modelled in InvestorProperties.
+	Extras map[string]interface{} `json:"extras,omitempty" bson:"extras,omitempty"`
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// backendAPI, extraction through the licensed Crunchbase API v4 (Basic or
+// Enterprise), authenticated with an API key.
+const backendAPI = "api"
+
+// apiBaseURL, base URL of the Crunchbase API v4.
+const apiBaseURL = "https://api.crunchbase.com/api/v4"
+
+// apiBackend, search backend of the Crunchbase API v4. The requests are
+// authenticated with the API key app.cbAPIKey.
+type apiBackend struct {
+	app *application
+}
+
+// newAPIBackend, returns the search backend of the Crunchbase API v4.
+func newAPIBackend(app *application) searchBackend {
+	return &apiBackend{app: app}
+}
+
+// authenticate, checks that the API key is accepted, see
+// handleAPIAuthentication.
+func (b *apiBackend) authenticate(ctx context.Context) error {
+	return b.app.handleAPIAuthentication(ctx)
+}
+
+// supports, only the collections with an endpoint in the API can be
+// searched, e.g. not 'principal.investors'.
+func (b *apiBackend) supports(collection *entityCollection) error {
+	_, err := apiSearchURL(collection)
+	return err
+}
+
+// searchURL, returns the search endpoint of app.collection in the API.
+func (b *apiBackend) searchURL() (string, error) {
+	return apiSearchURL(b.app.collection)
+}
+
+// searchPayload, encodes 'query' as the body of a search request of the API.
+func (b *apiBackend) searchPayload(query *Query, limit int, afterId string) ([]byte, error) {
+	return query.apiPayload(limit, afterId)
+}
+
+// addHeaders, adds the API key to 'req', see addAPIHeaders.
+func (b *apiBackend) addHeaders(req *http.Request) {
+	b.app.addAPIHeaders(req)
+}
+
+// renewSession, a rejected API key cannot be renewed.
+func (b *apiBackend) renewSession(ctx context.Context) (bool, error) {
+	return false, nil
+}
+
+// apiSearchPayload, body of a POST request to a search endpoint of the
+// Crunchbase API v4. Unlike the web search, the collection is part of the URL
+// and the payload has no field aggregators.
+type apiSearchPayload struct {
+	FieldIds []string          `json:"field_ids"`
+	Order    []QueryOrder      `json:"order"`
+	Query    []searchPredicate `json:"query"`
+	Limit    int               `json:"limit"`
+	AfterId  string            `json:"after_id,omitempty"`
+}
+
+// apiPayload, encodes the Query as the JSON body of a search request to the
+// Crunchbase API v4, see payload.
+func (q *Query) apiPayload(limit int, afterId string) ([]byte, error) {
+	predicates := make([]searchPredicate, len(q.Predicates))
+	for i, predicate := range q.Predicates {
+		predicates[i] = searchPredicate{Type: "predicate", QueryPredicate: predicate}
+	}
+
+	body := apiSearchPayload{
+		FieldIds: q.FieldIds,
+		Order:    q.Order,
+		Query:    predicates,
+		Limit:    limit,
+		AfterId:  afterId,
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode query as JSON payload of the API: %w", err)
+	}
+	return data, nil
+}
+
+// apiSearchURL, returns the URL of the search endpoint of 'collection' in the
+// Crunchbase API v4, e.g. '/searches/organizations'.
+func apiSearchURL(collection *entityCollection) (string, error) {
+	if collection.apiCollection == "" {
+		return "", fmt.Errorf("the collection '%s' cannot be searched with the '%s' backend", collection.id, backendAPI)
+	}
+	return fmt.Sprintf("%s/searches/%s", apiBaseURL, collection.apiCollection), nil
+}
+
+// apiEntityURL, returns the URL of the lookup of the entity 'id' (UUID or
+// permalink) of 'collection' in the Crunchbase API v4, with the fields
+// 'fieldIds'.
+func apiEntityURL(collection *entityCollection, id string, fieldIds []string) (string, error) {
+	if collection.apiCollection == "" {
+		return "", fmt.Errorf("the entities of the collection '%s' cannot be looked up with the '%s' backend", collection.id, backendAPI)
+	}
+	entityURL := fmt.Sprintf("%s/entities/%s/%s", apiBaseURL, collection.apiCollection, url.PathEscape(id))
+	// The API expects a comma-separated list of fields.
+	if len(fieldIds) > 0 {
+		values := url.Values{}
+		values.Set("field_ids", strings.Join(fieldIds, ","))
+		entityURL += "?" + values.Encode()
+	}
+	return entityURL, nil
+}
+
+// addAPIHeaders, adds the headers required by the Crunchbase API v4 to 'req',
+// i.e. the API key.
+func (app *application) addAPIHeaders(req *http.Request) {
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("X-cb-user-key", app.cbAPIKey)
+}
+
+// lookupEntity, requests the entity 'id' (UUID or permalink) of 'collection'
+// from the Crunchbase API v4 and returns the response's body. Only the fields
+// 'fieldIds' are requested.
+func (app *application) lookupEntity(ctx context.Context, collection *entityCollection, id string, fieldIds []string) ([]byte, error) {
+	entityURL, err := apiEntityURL(collection, id, fieldIds)
+	if err != nil {
+		return nil, err
+	}
+
+	// Configure a timeout for the client's HTTP request. If the request takes
+	// more than this time duration, then it should be cancelled.
+	lookupRequestDuration := time.Duration(time.Second * 45)
+	res, body, err := app.doWithRetry(ctx, "lookup", lookupRequestDuration, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", entityURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create a new GET request (lookup) with timeout context: %w", err)
+		}
+		app.addAPIHeaders(req)
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP request (lookup) of entity %s failed with status code %d (%s)", id, res.StatusCode, res.Status)
+	}
+	return body, nil
+}
+
+// handleAPIAuthentication, checks that the API key is accepted by the
+// Crunchbase API v4 by looking up a well-known organization, so that an
+// invalid key is detected before the extraction starts.
+func (app *application) handleAPIAuthentication(ctx context.Context) error {
+	if app.cbAPIKey == "" {
+		return fmt.Errorf("CB_API_KEY in .env file is empty or not defined, it is required by the '%s' backend", backendAPI)
+	}
+	if _, err := apiSearchURL(app.collection); err != nil {
+		return err
+	}
+	if _, err := app.lookupEntity(ctx, entityCollections[defaultCollectionId], "crunchbase", []string{"identifier"}); err != nil {
+		return fmt.Errorf("the Crunchbase API did not accept the API key: %w", err)
+	}
+	app.infoLog.Print("The Crunchbase API accepted the API key.")
+	return nil
+}
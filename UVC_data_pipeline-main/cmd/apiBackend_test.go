@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestQueryAPIPayload(t *testing.T) {
+	tests := []struct {
+		name        string
+		afterId     string
+		wantAfterId bool
+	}{
+		{
+			name:        "First page",
+			afterId:     "",
+			wantAfterId: false,
+		},
+		{
+			name:        "Next page",
+			afterId:     "8f3a1c",
+			wantAfterId: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := defaultQuery().apiPayload(pageSize, tt.afterId)
+			if err != nil {
+				t.Fatalf("apiPayload() error = %v", err)
+			}
+			body := map[string]interface{}{}
+			if err := json.Unmarshal(data, &body); err != nil {
+				t.Fatalf("unable to decode payload: %v", err)
+			}
+			// The collection is part of the URL of the API.
+			for _, key := range []string{"collection_id", "field_aggregators"} {
+				if _, ok := body[key]; ok {
+					t.Errorf("payload contains %s", key)
+				}
+			}
+			if _, ok := body["after_id"]; ok != tt.wantAfterId {
+				t.Errorf("payload contains after_id = %v, want %v", ok, tt.wantAfterId)
+			}
+		})
+	}
+}
+
+func TestAPIURLs(t *testing.T) {
+	organizations := entityCollections[defaultCollectionId]
+	searchURL, err := apiSearchURL(organizations)
+	if err != nil {
+		t.Fatalf("apiSearchURL() error = %v", err)
+	}
+	if want := "https://api.crunchbase.com/api/v4/searches/organizations"; searchURL != want {
+		t.Errorf("apiSearchURL() = %s, want %s", searchURL, want)
+	}
+
+	entityURL, err := apiEntityURL(organizations, "crunchbase", []string{"identifier", "founded_on"})
+	if err != nil {
+		t.Fatalf("apiEntityURL() error = %v", err)
+	}
+	if want := "https://api.crunchbase.com/api/v4/entities/organizations/crunchbase?field_ids=identifier%2Cfounded_on"; entityURL != want {
+		t.Errorf("apiEntityURL() = %s, want %s", entityURL, want)
+	}
+
+	if _, err := apiSearchURL(entityCollections["principal.investors"]); err == nil {
+		t.Errorf("apiSearchURL() of a collection without API endpoint, want error")
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// archivedEntity, raw entity received from Crunchbase, as stored in the raw
+// archive of a run, so that documents can be derived again from it later on
+// (e.g. after the parser changed) without requesting Crunchbase again.
+type archivedEntity struct {
+	RunId        string          `json:"run_id"`
+	CollectionId string          `json:"collection_id"`
+	Uuid         string          `json:"uuid"`
+	FetchedAt    time.Time       `json:"fetched_at"`
+	Entity       json.RawMessage `json:"entity"`
+}
+
+// rawArchive, archive of the raw entities received during a run, a gzip
+// compressed NDJSON file with one archivedEntity per line. Every page is
+// appended as a separate gzip member (readers of gzip concatenate members)
+// and synced to disk right after it was written.
+type rawArchive struct {
+	f    *os.File
+	path string
+	// offset, size in bytes of the synced archive, i.e. of all completely
+	// written pages.
+	offset int64
+	// received, body of the last page received from Crunchbase, which is
+	// archived by writePage.
+	received []byte
+}
+
+// archiveFileName, returns the name of the raw archive of a run.
+func archiveFileName(runId string) string {
+	return fmt.Sprintf("./CBData_%s.raw.ndjson.gz", runId)
+}
+
+// createRawArchive, creates a new raw archive at 'path'. It fails if the
+// archive already exists.
+func createRawArchive(path string) (*rawArchive, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create raw archive: %w", err)
+	}
+	return &rawArchive{f: f, path: path}, nil
+}
+
+// openRawArchive, reopens the raw archive at 'path' to append further pages.
+// The archive is truncated to 'offset' bytes, in order to drop a page which
+// was written but never recorded in a checkpoint.
+func openRawArchive(path string, offset int64) (*rawArchive, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open raw archive: %w", err)
+	}
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to truncate raw archive to %d bytes: %w", offset, err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to seek to the end of the raw archive: %w", err)
+	}
+	return &rawArchive{f: f, path: path, offset: offset}, nil
+}
+
+// openArchive, returns the raw archive of the run of the checkpoint 'cp'. If
+// 'resume' is true, the archive of the run is continued. Replays and runs
+// started before runs were archived are not archived, nil is returned.
+func (app *application) openArchive(cp *checkpoint, resume bool) (*rawArchive, error) {
+	if app.replayFile != "" {
+		return nil, nil
+	}
+	if resume {
+		if cp.ArchiveFile == "" {
+			return nil, nil
+		}
+		return openRawArchive(cp.ArchiveFile, cp.ArchiveOffset)
+	}
+	cp.ArchiveFile = archiveFileName(cp.RunId)
+	return createRawArchive(cp.ArchiveFile)
+}
+
+// receive, keeps the body of a page received from Crunchbase until it is
+// archived with writePage.
+func (a *rawArchive) receive(body []byte) {
+	a.received = body
+}
+
+// writePage, appends the entities of the page received last to the archive
+// and syncs the archive to disk.
+func (a *rawArchive) writePage(runId, collectionId string) error {
+	page := struct {
+		Entities []json.RawMessage `json:"entities"`
+	}{}
+	if err := json.Unmarshal(a.received, &page); err != nil {
+		return fmt.Errorf("unable to decode the page to archive: %w", err)
+	}
+	a.received = nil
+
+	buf := new(bytes.Buffer)
+	zw := gzip.NewWriter(buf)
+	encoder := json.NewEncoder(zw)
+	fetchedAt := time.Now()
+	for _, entity := range page.Entities {
+		identifier := struct {
+			Uuid string `json:"uuid"`
+		}{}
+		if err := json.Unmarshal(entity, &identifier); err != nil {
+			return fmt.Errorf("unable to decode the UUID of an entity to archive: %w", err)
+		}
+		archived := archivedEntity{RunId: runId, CollectionId: collectionId, Uuid: identifier.Uuid, FetchedAt: fetchedAt, Entity: entity}
+		if err := encoder.Encode(archived); err != nil {
+			return fmt.Errorf("unable to encode entity %s for the raw archive: %w", identifier.Uuid, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("unable to compress page for the raw archive: %w", err)
+	}
+
+	n, err := a.f.Write(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("unable to write raw archive: %w", err)
+	}
+	if err := a.f.Sync(); err != nil {
+		return fmt.Errorf("unable to sync raw archive: %w", err)
+	}
+	a.offset += int64(n)
+	return nil
+}
+
+// updateCheckpoint, records the size of the archive in a checkpoint.
+func (a *rawArchive) updateCheckpoint(cp *checkpoint) {
+	cp.ArchiveOffset = a.offset
+}
+
+// close, closes the archive file. An archive which is already closed is not
+// closed again.
+func (a *rawArchive) close() error {
+	if a.f == nil {
+		return nil
+	}
+	err := a.f.Close()
+	a.f = nil
+	if err != nil {
+		return fmt.Errorf("unable to close raw archive: %w", err)
+	}
+	return nil
+}
+
+// readRawArchive, calls 'fn' with every entity of the raw archive at 'path',
+// in the order in which the entities were archived. Reading stops at the
+// first error returned by 'fn'.
+func readRawArchive(path string, fn func(entity archivedEntity) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("unable to open raw archive: %w", err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("unable to decompress raw archive %s: %w", path, err)
+	}
+	defer zr.Close()
+
+	decoder := json.NewDecoder(zr)
+	for line := 1; decoder.More(); line++ {
+		entity := archivedEntity{}
+		if err := decoder.Decode(&entity); err != nil {
+			return fmt.Errorf("unable to decode entity %d of raw archive %s: %w", line, path, err)
+		}
+		if err := fn(entity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
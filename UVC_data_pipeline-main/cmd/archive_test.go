@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// archivedUUIDs, returns the UUIDs of the entities in the raw archive at
+// 'path'.
+func archivedUUIDs(t *testing.T, path string) []string {
+	t.Helper()
+	uuids := []string{}
+	err := readRawArchive(path, func(entity archivedEntity) error {
+		if len(entity.Entity) == 0 {
+			t.Errorf("archived entity %s has no raw entity", entity.Uuid)
+		}
+		uuids = append(uuids, entity.Uuid)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("readRawArchive() error = %v", err)
+	}
+	return uuids
+}
+
+func TestRawArchiveResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "CBData_test.raw.ndjson.gz")
+
+	archive, err := createRawArchive(path)
+	if err != nil {
+		t.Fatalf("createRawArchive() error = %v", err)
+	}
+	archive.receive([]byte(`{"count":4,"entities":[{"uuid":"1a","properties":{}},{"uuid":"2a","properties":{"founded_on":"?"}}]}`))
+	if err := archive.writePage("run1", defaultCollectionId); err != nil {
+		t.Fatalf("writePage() error = %v", err)
+	}
+	// Offset as it would have been stored in a checkpoint.
+	cp := &checkpoint{}
+	archive.updateCheckpoint(cp)
+	// This page is written, but the program 'crashes' before the checkpoint
+	// is stored.
+	archive.receive([]byte(`{"entities":[{"uuid":"3a"}]}`))
+	if err := archive.writePage("run1", defaultCollectionId); err != nil {
+		t.Fatalf("writePage() error = %v", err)
+	}
+	if err := archive.close(); err != nil {
+		t.Fatalf("close() error = %v", err)
+	}
+
+	// Resuming drops the page which was not recorded in the checkpoint.
+	archive, err = openRawArchive(path, cp.ArchiveOffset)
+	if err != nil {
+		t.Fatalf("openRawArchive() error = %v", err)
+	}
+	archive.receive([]byte(`{"entities":[{"uuid":"3b"},{"uuid":"4b"}]}`))
+	if err := archive.writePage("run1", defaultCollectionId); err != nil {
+		t.Fatalf("writePage() error = %v", err)
+	}
+	if err := archive.close(); err != nil {
+		t.Fatalf("close() error = %v", err)
+	}
+
+	if got, want := archivedUUIDs(t, path), []string{"1a", "2a", "3b", "4b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("archived entities = %v, want %v", got, want)
+	}
+}
+
+func TestRawArchiveWritePage(t *testing.T) {
+	tests := []struct {
+		name      string
+		page      string
+		wantUUIDs []string
+		wantErr   bool
+	}{
+		{
+			name:      "Entities with UUID",
+			page:      `{"entities":[{"uuid":"1a"},{"uuid":"2a","properties":[]}]}`,
+			wantUUIDs: []string{"1a", "2a"},
+		},
+		{
+			name:      "Entity without UUID",
+			page:      `{"entities":[{"properties":{}}]}`,
+			wantUUIDs: []string{""},
+		},
+		{
+			name:      "Invalid UUID",
+			page:      `{"entities":[{"uuid":"1a"},{"uuid":2}]}`,
+			wantUUIDs: []string{},
+			wantErr:   true,
+		},
+		{
+			name:      "Invalid page",
+			page:      `{"entities":{}}`,
+			wantUUIDs: []string{},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "CBData_test.raw.ndjson.gz")
+			archive, err := createRawArchive(path)
+			if err != nil {
+				t.Fatalf("createRawArchive() error = %v", err)
+			}
+			archive.receive([]byte(tt.page))
+			if err := archive.writePage("run1", defaultCollectionId); (err != nil) != tt.wantErr {
+				t.Errorf("writePage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err := archive.close(); err != nil {
+				t.Fatalf("close() error = %v", err)
+			}
+			// A page which cannot be archived is not written at all.
+			if tt.wantErr {
+				if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+					t.Fatalf("raw archive = %v (error: %v), want an empty file", info, err)
+				}
+				return
+			}
+			if got := archivedUUIDs(t, path); !reflect.DeepEqual(got, tt.wantUUIDs) {
+				t.Errorf("archived entities = %v, want %v", got, tt.wantUUIDs)
+			}
+		})
+	}
+}
+
+func TestRawArchiveClose(t *testing.T) {
+	archive, err := createRawArchive(filepath.Join(t.TempDir(), "CBData_test.raw.ndjson.gz"))
+	if err != nil {
+		t.Fatalf("createRawArchive() error = %v", err)
+	}
+	// The file was closed behind the back of the archive, e.g. by a failing
+	// disk.
+	archive.f.Close()
+	if err := archive.close(); err == nil {
+		t.Errorf("close() of a failing file returned no error")
+	}
+	if err := archive.close(); err != nil {
+		t.Errorf("close() of a closed archive error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// searchBackend, way in which the search requests of an extraction are sent to
+// Crunchbase, e.g. through the web search with the session of a CB account.
+// A new provider implements searchBackend and registers its constructor in
+// searchBackends, the extraction itself does not depend on the provider.
+type searchBackend interface {
+	// authenticate, authenticates the requests of the extraction before it
+	// starts, e.g. logs in or checks that an API key is accepted.
+	authenticate(ctx context.Context) error
+	// supports, returns an error if the entities of 'collection' cannot be
+	// searched through the provider, so that the flags of an extraction are
+	// rejected before it starts.
+	supports(collection *entityCollection) error
+	// searchURL, returns the URL to which the search requests of
+	// app.collection are sent.
+	searchURL() (string, error)
+	// searchPayload, encodes 'query' as the body of a search request of at
+	// most 'limit' entities after the entity 'afterId'.
+	searchPayload(query *Query, limit int, afterId string) ([]byte, error)
+	// addHeaders, adds the headers required by the provider to the search
+	// request 'req'.
+	addHeaders(req *http.Request)
+	// renewSession, authenticates again after Crunchbase rejected a request
+	// (401 or 403). It returns false if the authentication cannot be renewed,
+	// e.g. an API key.
+	renewSession(ctx context.Context) (bool, error)
+}
+
+// searchBackends, constructors of the search backends, accessible by their
+// name (--backend).
+var searchBackends = map[string]func(app *application) searchBackend{
+	backendCookie: newCookieBackend,
+	backendAPI:    newAPIBackend,
+}
+
+// newSearchBackend, returns the search backend named 'name'.
+func (app *application) newSearchBackend(name string) (searchBackend, error) {
+	newBackend, ok := searchBackends[name]
+	if !ok {
+		names := make([]string, 0, len(searchBackends))
+		for n := range searchBackends {
+			names = append(names, "'"+n+"'")
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown backend '%s', use one of %s", name, strings.Join(names, ", "))
+	}
+	return newBackend(app), nil
+}
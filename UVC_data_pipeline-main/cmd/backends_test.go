@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewSearchBackend(t *testing.T) {
+	app := newFixtureTestApplication()
+	app.cbAPIKey = "key"
+
+	tests := []struct {
+		backend    string
+		wantURL    string
+		wantHeader string
+		wantErr    bool
+	}{
+		{
+			backend:    backendCookie,
+			wantURL:    "https://www.crunchbase.com/v4/data/lists/organization.companies/343cbe1f-7511-4263-939c-0c3f3f7a729d?source=list",
+			wantHeader: "Referer",
+		},
+		{
+			backend:    backendAPI,
+			wantURL:    "https://api.crunchbase.com/api/v4/searches/organizations",
+			wantHeader: "X-cb-user-key",
+		},
+		{
+			backend: "scraper",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.backend, func(t *testing.T) {
+			backend, err := app.newSearchBackend(tt.backend)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newSearchBackend() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got, err := backend.searchURL(); err != nil || got != tt.wantURL {
+				t.Errorf("searchURL() = %s, %v, want %s", got, err, tt.wantURL)
+			}
+			req, err := http.NewRequest("POST", tt.wantURL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			backend.addHeaders(req)
+			if req.Header.Get(tt.wantHeader) == "" {
+				t.Errorf("addHeaders() did not add the header %s", tt.wantHeader)
+			}
+		})
+	}
+}
+
+func TestSearchBackendSupports(t *testing.T) {
+	app := newFixtureTestApplication()
+	tests := []struct {
+		backend      string
+		collectionId string
+		wantErr      bool
+	}{
+		{backendCookie, defaultCollectionId, false},
+		{backendCookie, "principal.investors", false},
+		{backendAPI, defaultCollectionId, false},
+		{backendAPI, "funding_rounds", false},
+		// The API has no endpoint of the investors.
+		{backendAPI, "principal.investors", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.backend+" "+tt.collectionId, func(t *testing.T) {
+			backend, err := app.newSearchBackend(tt.backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := backend.supports(entityCollections[tt.collectionId]); (err != nil) != tt.wantErr {
+				t.Errorf("supports() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSetupExtractCommandsRejectsUnsupportedCollection(t *testing.T) {
+	queryFile := filepath.Join(t.TempDir(), "investors.yaml")
+	if err := os.WriteFile(queryFile, []byte("collection_id: principal.investors\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	app := newFixtureTestApplication()
+	// The API key would be accepted, the flags are rejected before.
+	app.cbAPIKey = "key"
+	err := app.setupExtractCommands(context.Background(), extractOptions{queryFile: queryFile, sink: sinkFile, backend: backendAPI})
+	if err == nil || !strings.Contains(err.Error(), "cannot be searched") {
+		t.Errorf("setupExtractCommands() error = %v, want the collection to be rejected", err)
+	}
+}
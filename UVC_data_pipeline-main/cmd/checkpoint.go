@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// checkpointFile, path to the file in which the progress of an ongoing
+// extraction is persisted after every page.
+const checkpointFile = "./extract-checkpoint.json"
+
+// checkpoint, progress of an extraction run. It is stored after every page
+// retrieved from the Crunchbase API, so that a crashed or blocked run can
+// continue with the next page instead of starting at page one.
+type checkpoint struct {
+	// RunId, unique identifier of the extraction run.
+	RunId string `json:"run_id"`
+	// QueryHash, hash of the query used by the run. A run can only be resumed
+	// with the same query.
+	QueryHash string `json:"query_hash"`
+	// LastUUID, UUID of the last entity retrieved from the current partition,
+	// used as 'after_id' for the next request.
+	LastUUID string `json:"last_uuid"`
+	// TotalCount, number of entities matched by the whole query according to
+	// the API, the counts of the partitions must add up to it.
+	TotalCount int `json:"total_count,omitempty"`
+	// Partitions, disjoint partitions of the query, a query which is not
+	// partitioned has a single partition.
+	Partitions []partition `json:"partitions"`
+	// Partition, index of the partition currently being extracted.
+	Partition int `json:"partition"`
+	// Sink, destination of the extracted pages, see documentSink.
+	Sink string `json:"sink"`
+	// OutputFile, final path of the NDJSON output file of the run, if the
+	// run uses the file sink.
+	OutputFile string `json:"output_file"`
+	// OutputOffset, size in bytes of the output file after the last page
+	// recorded by this checkpoint.
+	OutputOffset int64 `json:"output_offset"`
+	// EntitiesWritten, number of entities written into the output file.
+	EntitiesWritten int `json:"entities_written"`
+	// ArchiveFile, path of the raw archive of the run, empty if the run is
+	// not archived (e.g. a replay).
+	ArchiveFile string `json:"archive_file,omitempty"`
+	// ArchiveOffset, size in bytes of the raw archive after the last page
+	// recorded by this checkpoint.
+	ArchiveOffset int64 `json:"archive_offset,omitempty"`
+	// Schema, properties received so far and their types, compared with the
+	// last run by the drift report.
+	Schema propertySchema `json:"schema,omitempty"`
+	// StartedAt, time at which the run was started.
+	StartedAt time.Time `json:"started_at"`
+	// UpdatedAt, time at which the checkpoint was last stored.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// newCheckpoint, returns an empty checkpoint for a new run of 'query' that
+// stores its pages in 'sink'.
+func (app *application) newCheckpoint(query *Query, sink string) (*checkpoint, error) {
+	queryHash, err := query.hash()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	runId := app.newRunId(now)
+	return &checkpoint{
+		RunId:      runId,
+		QueryHash:  queryHash,
+		Sink:       sink,
+		OutputFile: outputFileName(runId),
+		StartedAt:  now,
+	}, nil
+}
+
+// newRunId, returns a unique identifier for an extraction run started at 't',
+// e.g. '20230214T101502Z-8f3a1c'.
+func (app *application) newRunId(t time.Time) string {
+	return fmt.Sprintf("%s-%06x", t.UTC().Format("20060102T150405Z"), app.seededRand.Intn(1<<24))
+}
+
+// loadCheckpoint, loads the checkpoint stored at 'path' and checks that it was
+// created with 'query'.
+func loadCheckpoint(path string, query *Query) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read checkpoint file %s: %w", path, err)
+	}
+
+	cp := new(checkpoint)
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("unable to decode checkpoint file %s: %w", path, err)
+	}
+
+	queryHash, err := query.hash()
+	if err != nil {
+		return nil, err
+	}
+	if cp.QueryHash != queryHash {
+		return nil, fmt.Errorf("checkpoint of run %s was created with a different query, it cannot be resumed with the current query", cp.RunId)
+	}
+
+	return cp, nil
+}
+
+// save, stores the checkpoint at 'path'.
+func (cp *checkpoint) save(path string) error {
+	cp.UpdatedAt = time.Now()
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("unable to encode checkpoint as JSON: %w", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("unable to store checkpoint: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic, writes 'data' into the file at 'path'. The data is first
+// written to a temporary file which then replaces 'path', so that a crash
+// while writing never leaves a corrupted file behind.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file: %w", err)
+	}
+	// Remove the temporary file if anything goes wrong, after a successful
+	// rename this is a no-op.
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write temporary file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to sync temporary file to disk: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("unable to close temporary file: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("unable to replace file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// removeCheckpoint, removes the checkpoint at 'path' after a run finished. A
+// missing checkpoint is not an error.
+func removeCheckpoint(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to remove checkpoint file %s: %w", path, err)
+	}
+	return nil
+}
+
+// hash, returns a hex encoded SHA-256 hash of the search sent to the API by
+// the Query. The description of the query does not change the hash.
+func (q *Query) hash() (string, error) {
+	payload, err := q.payload(0, "")
+	if err != nil {
+		return "", fmt.Errorf("unable to hash query: %w", err)
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"math/rand"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckpointSaveLoad(t *testing.T) {
+	app := new(application)
+	app.seededRand = rand.New(
+		rand.NewSource(time.Now().UnixNano()))
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	cp, err := app.newCheckpoint(defaultQuery(), sinkFile)
+	if err != nil {
+		t.Fatalf("newCheckpoint() error = %v", err)
+	}
+	cp.LastUUID = "2a"
+	cp.EntitiesWritten = 2
+	if err := cp.save(path); err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+
+	t.Run("Resume with the same query", func(t *testing.T) {
+		got, err := loadCheckpoint(path, defaultQuery())
+		if err != nil {
+			t.Fatalf("loadCheckpoint() error = %v", err)
+		}
+		if got.RunId != cp.RunId || got.LastUUID != "2a" || got.EntitiesWritten != 2 {
+			t.Errorf("loadCheckpoint() = %+v, want run %s with 2 entities after 2a", got, cp.RunId)
+		}
+	})
+
+	t.Run("Resume with a different query", func(t *testing.T) {
+		query := defaultQuery()
+		query.Predicates = query.Predicates[1:]
+		if _, err := loadCheckpoint(path, query); err == nil {
+			t.Errorf("loadCheckpoint() with a different query should have returned an error")
+		}
+	})
+
+	t.Run("Remove checkpoint", func(t *testing.T) {
+		if err := removeCheckpoint(path); err != nil {
+			t.Fatalf("removeCheckpoint() error = %v", err)
+		}
+		// Removing a checkpoint which does not exist is not an error.
+		if err := removeCheckpoint(path); err != nil {
+			t.Errorf("removeCheckpoint() of missing file error = %v", err)
+		}
+		if _, err := loadCheckpoint(path, defaultQuery()); err == nil {
+			t.Errorf("loadCheckpoint() of removed checkpoint should have returned an error")
+		}
+	})
+}
+
+func TestQueryHashIgnoresDescription(t *testing.T) {
+	query := defaultQuery()
+	want, err := query.hash()
+	if err != nil {
+		t.Fatalf("hash() error = %v", err)
+	}
+	query.Description = "another description"
+	got, err := query.hash()
+	if err != nil {
+		t.Fatalf("hash() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("hash() = %s, want %s", got, want)
+	}
+}
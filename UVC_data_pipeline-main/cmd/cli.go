@@ -1,17 +1,28 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/urfave/cli/v2"
 )
 
 // runTUI, run the TUI (Terminal User Interface), handled by the CLI package.
+// The commands run with a root context, which is cancelled when the program
+// receives a SIGINT (Ctrl+C) or a SIGTERM, so that long running commands can
+// shut down gracefully.
 func (app *application) runTUI() {
 	app.setupCLI()
 
-	if err := app.tui.Run(os.Args); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := app.tui.RunContext(ctx, os.Args)
+	stop()
+	if err != nil {
 		app.errorLog.Fatal(err)
 	}
 }
@@ -35,20 +46,124 @@ func (app *application) setupCLI() {
 						Name:  "no-proxy",
 						Usage: "Do not use a proxy while establishing a connection with Crunchbase.",
 					},
+					&cli.StringFlag{
+						Name:    "query",
+						Aliases: []string{"q"},
+						Usage:   "`PATH` to a YAML or JSON file with the Crunchbase query (default: NA and Europe, seed to late stage, founded 2020+).",
+					},
+					&cli.StringFlag{
+						Name:    "profile",
+						Aliases: []string{"p"},
+						Usage:   "`NAME` of a query profile in the profiles directory, check 'profiles list'.",
+					},
+					&cli.BoolFlag{
+						Name:  "resume",
+						Usage: "Continue the extraction from the checkpoint of a previous crashed or blocked run.",
+					},
+					&cli.StringFlag{
+						Name:  "sink",
+						Value: sinkFile,
+						Usage: "`SINK` in which the extracted pages are stored: 'file' (NDJSON file) or 'mongo' (upsert into the CB collection, requires --remote).",
+					},
+					&cli.StringFlag{
+						Name:    "remote",
+						Aliases: []string{"r"},
+						Usage:   "`IP` address of remote server hosting the MongoDB instance (mongo sink).",
+					},
+					&cli.IntFlag{
+						Name:  "max-retries",
+						Value: defaultMaxRetries,
+						Usage: "Number of times a request to Crunchbase is retried after a transport error, a 429 or a 5xx response.",
+					},
+					&cli.IntFlag{
+						Name:  "max-backoff",
+						Value: defaultMaxBackoff,
+						Usage: "Maximal delay in `SECONDS` between two retries (exponential backoff), unless Crunchbase requests a longer delay.",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Only print the number of entities matched by the query, the number of pages and the expected duration of the extraction, without downloading any page.",
+					},
+					&cli.IntFlag{
+						Name:  "max-partition-size",
+						Value: defaultMaxPartitionSize,
+						Usage: "Maximal number of entities extracted with a single search, larger queries are split into partitions by year (0 disables partitioning).",
+					},
+					&cli.StringFlag{
+						Name:  "backend",
+						Value: backendCookie,
+						Usage: "`BACKEND` used to extract data: 'cookie' (web search with the CB account) or 'api' (Crunchbase API v4 with CB_API_KEY).",
+					},
+					&cli.StringFlag{
+						Name:  "replay",
+						Usage: "Replay the entities of a local `FILE` (e.g. the output file of a previous run) instead of requesting Crunchbase, e.g. to load them into the mongo sink.",
+					},
+					&cli.BoolFlag{
+						Name:  "incremental",
+						Usage: "Only extract the entities changed since the last successful run of the same query.",
+					},
+					&cli.StringFlag{
+						Name:  "record-fixtures",
+						Usage: "Record all requests sent to Crunchbase and their responses, without credentials and session cookies, in the fixture `FILE`.",
+					},
+					&cli.StringFlag{
+						Name:  "replay-fixtures",
+						Usage: "Serve the responses recorded in the fixture `FILE` instead of sending requests to Crunchbase.",
+					},
 				},
 				Action: func(cCtx *cli.Context) error {
-					noProxyFlag := cCtx.Bool("no-proxy")
+					if cCtx.Int("max-retries") < 0 || cCtx.Int("max-backoff") < 0 || cCtx.Int("max-partition-size") < 0 {
+						err := fmt.Errorf("--max-retries, --max-backoff and --max-partition-size cannot be negative.")
+						return cli.Exit(err, 1)
+					}
+					app.userConfigurations.maxRetries = cCtx.Int("max-retries")
+					app.userConfigurations.maxBackoff = cCtx.Int("max-backoff")
+					app.userConfigurations.maxPartitionSize = cCtx.Int("max-partition-size")
+					opts := extractOptions{
+						noProxy:        cCtx.Bool("no-proxy"),
+						queryFile:      cCtx.String("query"),
+						profile:        cCtx.String("profile"),
+						resume:         cCtx.Bool("resume"),
+						sink:           cCtx.String("sink"),
+						remote:         cCtx.String("remote"),
+						dryRun:         cCtx.Bool("dry-run"),
+						incremental:    cCtx.Bool("incremental"),
+						backend:        cCtx.String("backend"),
+						replay:         cCtx.String("replay"),
+						recordFixtures: cCtx.String("record-fixtures"),
+						replayFixtures: cCtx.String("replay-fixtures"),
+					}
 					// Perform the required setup and configuration, e.g.
 					// configuring the *http.Client with or without a proxy,
 					// or handling the authentication with the CB API.
-					if err := app.setupExtractCommands(noProxyFlag); err != nil {
+					if err := app.setupExtractCommands(cCtx.Context, opts); err != nil {
 						err = fmt.Errorf("setup for 'extract' command failed: %w", err)
 						app.errorLog.Printf("extracting data from CB API failed: %v", err)
 						return cli.Exit(err, 1)
 
 					}
 
-					if err := app.extractCBData(); err != nil {
+					if opts.dryRun {
+						if err := app.dryRunExtract(cCtx.Context); err != nil {
+							err = fmt.Errorf("error while executing 'extract --dry-run' command: %w", err)
+							app.errorLog.Print(err)
+							return cli.Exit(err, 1)
+						}
+						return nil
+					}
+
+					if err := app.extractCBData(cCtx.Context, opts); err != nil {
+						// The run is complete but its result is not
+						// trustworthy, use a dedicated exit status.
+						if errors.Is(err, errNotReconciled) {
+							app.errorLog.Print(err)
+							return cli.Exit(err, 2)
+						}
+						// The run stopped early and can be resumed.
+						if errors.Is(err, errIncomplete) {
+							app.errorLog.Print(err)
+							return cli.Exit(err, 3)
+						}
 						err = fmt.Errorf("error while executing 'extractCBData' command: %w", err)
 						app.errorLog.Printf("extracting data from CB API failed: %v", err)
 						return cli.Exit(err, 1)
@@ -56,6 +171,239 @@ func (app *application) setupCLI() {
 					return nil
 				},
 			},
+			&cli.Command{
+				Name:  "reprocess",
+				Usage: "Rebuild the documents of a run from its raw archive with the current parser, without sending any request to Crunchbase.",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "run",
+						Required: true,
+						Usage:    "`RUN_ID` of the extraction run whose raw archive is reprocessed.",
+					},
+					&cli.StringFlag{
+						Name:  "archive",
+						Usage: "`PATH` to the raw archive of the run (default: ./CBData_<RUN_ID>.raw.ndjson.gz).",
+					},
+					&cli.StringFlag{
+						Name:  "sink",
+						Value: sinkFile,
+						Usage: "`SINK` in which the documents are stored: 'file' (replaces the NDJSON output file of the run) or 'mongo' (upsert into the CB collection, requires --remote).",
+					},
+					&cli.StringFlag{
+						Name:    "remote",
+						Aliases: []string{"r"},
+						Usage:   "`IP` address of remote server hosting the MongoDB instance (mongo sink).",
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					opts := reprocessOptions{
+						runId:   cCtx.String("run"),
+						archive: cCtx.String("archive"),
+						sink:    cCtx.String("sink"),
+						remote:  cCtx.String("remote"),
+					}
+					if err := app.setupReprocessCommands(opts); err != nil {
+						err = fmt.Errorf("setup for 'reprocess' command failed: %w", err)
+						app.errorLog.Print(err)
+						return cli.Exit(err, 1)
+					}
+
+					if err := app.reprocess(opts); err != nil {
+						err = fmt.Errorf("error while executing 'reprocess' command: %w", err)
+						app.errorLog.Print(err)
+						return cli.Exit(err, 1)
+					}
+					return nil
+				},
+			},
+			&cli.Command{
+				Name:  "profiles",
+				Usage: "Inspect the named query profiles used by 'extract --profile'.",
+				Subcommands: []*cli.Command{
+					&cli.Command{
+						Name:  "list",
+						Usage: "List all available profiles.",
+						Action: func(cCtx *cli.Context) error {
+							if err := app.printProfiles(); err != nil {
+								err = fmt.Errorf("error while executing 'profiles list' command: %w", err)
+								app.errorLog.Print(err)
+								return cli.Exit(err, 1)
+							}
+							return nil
+						},
+					},
+					&cli.Command{
+						Name:      "show",
+						Usage:     "Show the predicates applied by a profile.",
+						ArgsUsage: "NAME",
+						Action: func(cCtx *cli.Context) error {
+							if cCtx.NArg() != 1 {
+								err := fmt.Errorf("'profiles show' expects exactly one profile name.")
+								return cli.Exit(err, 1)
+							}
+							if err := app.printProfile(cCtx.Args().First()); err != nil {
+								err = fmt.Errorf("error while executing 'profiles show' command: %w", err)
+								app.errorLog.Print(err)
+								return cli.Exit(err, 1)
+							}
+							return nil
+						},
+					},
+				},
+			},
+			&cli.Command{
+				Name:  "import",
+				Usage: "Import organizations from other sources into the database.",
+				Subcommands: []*cli.Command{
+					&cli.Command{
+						Name:  "file",
+						Usage: "Import a CSV or JSON lines file with a mapping of its columns.",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "file",
+								Aliases:  []string{"f"},
+								Required: true,
+								Usage:    "`PATH` to the CSV (.csv) or JSON lines (.jsonl, .ndjson, .json) file.",
+							},
+							&cli.StringFlag{
+								Name:     "mapping",
+								Aliases:  []string{"m"},
+								Required: true,
+								Usage:    "`PATH` to the mapping file (YAML or JSON) of the columns of the file onto the fields of the organizations.",
+							},
+							&cli.StringFlag{
+								Name:     "remote",
+								Aliases:  []string{"r"},
+								Required: true,
+								Usage:    "`IP` address of remote server hosting the MongoDB instance.",
+							},
+							&cli.BoolFlag{
+								Name:  "upsert",
+								Usage: "Merge the imported fields into the organizations which are already stored (same upsert key of the mapping) instead of inserting them again, fields without a value in the import keep their stored value.",
+							},
+						},
+						Action: func(cCtx *cli.Context) error {
+							mapping, err := loadImportMapping(cCtx.String("mapping"))
+							if err != nil {
+								err = fmt.Errorf("error while executing 'import file' command: %w", err)
+								app.errorLog.Print(err)
+								return cli.Exit(err, 1)
+							}
+
+							if err := app.setupDBCommands(cCtx.String("remote")); err != nil {
+								err = fmt.Errorf("setup for 'import' command failed: %w", err)
+								app.errorLog.Print(err)
+								return cli.Exit(err, 1)
+							}
+
+							if err := app.importFile(cCtx.String("file"), mapping, cCtx.Bool("upsert")); err != nil {
+								err = fmt.Errorf("error while executing 'import file' command, file %s could not be imported: %w", cCtx.String("file"), err)
+								app.errorLog.Print(err)
+								return cli.Exit(err, 1)
+							}
+							return nil
+						},
+					},
+					&cli.Command{
+						Name:  "crunchbase-csv",
+						Usage: "Import a CSV export of the Crunchbase UI.",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "file",
+								Aliases:  []string{"f"},
+								Required: true,
+								Usage:    "`PATH` to the CSV file exported from Crunchbase.",
+							},
+							&cli.StringFlag{
+								Name:     "remote",
+								Aliases:  []string{"r"},
+								Required: true,
+								Usage:    "`IP` address of remote server hosting the MongoDB instance.",
+							},
+							&cli.BoolFlag{
+								Name:  "upsert",
+								Usage: "Merge the imported fields into the organizations which are already stored (same permalink) instead of inserting them again, fields without a value in the import keep their stored value.",
+							},
+						},
+						Action: func(cCtx *cli.Context) error {
+							if err := app.setupDBCommands(cCtx.String("remote")); err != nil {
+								err = fmt.Errorf("setup for 'import' command failed: %w", err)
+								app.errorLog.Print(err)
+								return cli.Exit(err, 1)
+							}
+
+							if err := app.importFile(cCtx.String("file"), crunchbaseCSVMapping(), cCtx.Bool("upsert")); err != nil {
+								err = fmt.Errorf("error while executing 'import crunchbase-csv' command, file %s could not be imported: %w", cCtx.String("file"), err)
+								app.errorLog.Print(err)
+								return cli.Exit(err, 1)
+							}
+							return nil
+						},
+					},
+				},
+			},
+			&cli.Command{
+				Name:  "auth",
+				Usage: "Manage the session of the CB account used by the 'cookie' backend.",
+				Subcommands: []*cli.Command{
+					&cli.Command{
+						Name:  "status",
+						Usage: "Show if a stored session can be reused by the next extraction.",
+						Action: func(cCtx *cli.Context) error {
+							if err := app.authStatus(os.Stdout, time.Now()); err != nil {
+								err = fmt.Errorf("error while executing 'auth status' command: %w", err)
+								app.errorLog.Print(err)
+								return cli.Exit(err, 1)
+							}
+							return nil
+						},
+					},
+					&cli.Command{
+						Name:  "login",
+						Usage: "Log in with the CB account and store the session for the next extractions.",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "no-proxy",
+								Usage: "Log in without a proxy.",
+							},
+						},
+						Action: func(cCtx *cli.Context) error {
+							if app.cbUsername == "" || app.cbPassword == "" {
+								err := fmt.Errorf("CB_USERNAME and CB_PASSWORD in .env file are required to log in")
+								app.errorLog.Print(err)
+								return cli.Exit(err, 1)
+							}
+							if err := app.configureClient(cCtx.Bool("no-proxy")); err != nil {
+								err = fmt.Errorf("setup for 'auth login' command failed: %w", err)
+								app.errorLog.Print(err)
+								return cli.Exit(err, 1)
+							}
+							// No request follows the login, there is no need
+							// to wait after it.
+							app.userConfigurations.maxDelayLogin = 0
+							if err := app.login(cCtx.Context, true); err != nil {
+								err = fmt.Errorf("error while executing 'auth login' command: %w", err)
+								app.errorLog.Print(err)
+								return cli.Exit(err, 1)
+							}
+							app.infoLog.Printf("Stored the new session in %s.", cookiesFile)
+							return nil
+						},
+					},
+					&cli.Command{
+						Name:  "logout",
+						Usage: "Remove the stored session, the next extraction logs in again.",
+						Action: func(cCtx *cli.Context) error {
+							if err := app.logout(); err != nil {
+								err = fmt.Errorf("error while executing 'auth logout' command: %w", err)
+								app.errorLog.Print(err)
+								return cli.Exit(err, 1)
+							}
+							return nil
+						},
+					},
+				},
+			},
 			&cli.Command{
 				Name:  "db",
 				Usage: "Perform operations in the database.",
@@ -71,7 +419,7 @@ func (app *application) setupCLI() {
 								Name:     "file",
 								Aliases:  []string{"f"},
 								Required: true,
-								Usage:    "`PATH` to the file that will be inserted into the database.",
+								Usage:    "`PATH` to the file (JSON array or newline-delimited JSON) that will be inserted into the database.",
 							},
 							&cli.StringFlag{
 								Name:     "remote",
@@ -79,6 +427,11 @@ func (app *application) setupCLI() {
 								Required: true,
 								Usage:    "`IP` address of remote server hosting the MongoDB instance.",
 							},
+							&cli.StringFlag{
+								Name:  "collection-id",
+								Value: defaultCollectionId,
+								Usage: "Crunchbase `COLLECTION` of the documents in the file (organization.companies, people, funding_rounds or principal.investors).",
+							},
 						},
 						Action: func(cCtx *cli.Context) error {
 							// If the "file" flag was not set properly, the
@@ -97,7 +450,7 @@ func (app *application) setupCLI() {
 								return cli.Exit(err, 1)
 							}
 
-							if err := app.insertDB(cCtx.String("file")); err != nil {
+							if err := app.insertDB(cCtx.String("file"), cCtx.String("collection-id")); err != nil {
 								err = fmt.Errorf("error while executing 'insertDB' command, file %s could not be inserted into the database: %w", cCtx.String("file"), err)
 								app.errorLog.Print(err)
 								return cli.Exit(err, 1)
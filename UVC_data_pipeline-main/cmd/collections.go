@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// document, an entity of a Crunchbase collection after it was parsed into one
+// of our document types (e.g. OrganizationDocument), ready to be stored by a
+// sink.
+type document interface {
+	// entityUuid, returns the Crunchbase UUID of the entity, used for paging
+	// (after_id) and to upsert documents.
+	entityUuid() string
+}
+
+// entityCollection, a Crunchbase collection which can be extracted, e.g.
+// 'organization.companies' or 'funding_rounds'.
+type entityCollection struct {
+	// id, collection_id of the collection in the Crunchbase search API.
+	id string
+	// searchPath, path of the web search endpoint of the collection, e.g.
+	// '/v4/data/searches/people'. If it is an empty string, the randomly
+	// chosen CB referer URL is used.
+	searchPath string
+	// apiCollection, name of the collection in the endpoints of the
+	// Crunchbase API v4, e.g. '/searches/organizations'. If it is an empty
+	// string, the collection is not available through the API.
+	apiCollection string
+	// fieldIds, fields requested if a query does not define its own fields.
+	fieldIds []string
+	// order, sort order used if a query does not define its own order.
+	order []QueryOrder
+	// updatedField, date field filtered by incremental extractions, if a
+	// query does not define its own incremental field.
+	updatedField string
+	// partitionField, date field on which large queries are split into
+	// partitions by year ranges. If it is an empty string, the collection is
+	// never partitioned.
+	partitionField string
+	// mongoCollection, default MongoDB collection in which the documents are
+	// stored. It can be overwritten with the environment variable mongoEnv.
+	mongoCollection string
+	// mongoEnv, name of the environment variable (.env file) with the MongoDB
+	// collection of the documents.
+	mongoEnv string
+	// decode, parses the body of a response of the search API into documents.
+	decode func(payload []byte) ([]document, error)
+	// parse, parses a single raw entity of the collection into a document,
+	// e.g. an entity of the raw archive of a run.
+	parse func(raw json.RawMessage) (document, error)
+	// newDocument, returns an empty document, used to decode stored documents.
+	newDocument func() document
+}
+
+// entityCollections, all Crunchbase collections supported by the extractor,
+// accessible by their collection_id.
+var entityCollections = map[string]*entityCollection{
+	"organization.companies": {
+		id:             "organization.companies",
+		apiCollection:  "organizations",
+		fieldIds:       defaultFieldIds,
+		order:          []QueryOrder{{FieldId: "founded_on", Sort: "desc"}},
+		updatedField:   "updated_at",
+		partitionField: "founded_on",
+		// The collection for organizations must always be defined in the
+		// .env file (see loadEnv).
+		mongoEnv:    "COLL_CB",
+		decode:      decodeOrganizations,
+		parse:       parseOrganization,
+		newDocument: func() document { return new(OrganizationDocument) },
+	},
+	"people": {
+		id:              "people",
+		searchPath:      "/v4/data/searches/people",
+		apiCollection:   "people",
+		fieldIds:        []string{"identifier", "first_name", "last_name", "gender", "primary_job_title", "primary_organization", "location_identifiers", "description", "linkedin", "twitter", "num_founded_organizations", "num_investments", "num_exits"},
+		order:           []QueryOrder{{FieldId: "rank_person", Sort: "asc"}},
+		updatedField:    "updated_at",
+		mongoCollection: "crunchbasePeople",
+		mongoEnv:        "COLL_CB_PEOPLE",
+		decode:          decodePeople,
+		parse:           parsePerson,
+		newDocument:     func() document { return new(PersonDocument) },
+	},
+	"funding_rounds": {
+		id:              "funding_rounds",
+		searchPath:      "/v4/data/searches/funding_rounds",
+		apiCollection:   "funding_rounds",
+		fieldIds:        []string{"identifier", "announced_on", "funded_organization_identifier", "investment_type", "money_raised", "pre_money_valuation", "num_investors", "lead_investor_identifiers", "investor_identifiers"},
+		order:           []QueryOrder{{FieldId: "announced_on", Sort: "desc"}},
+		updatedField:    "updated_at",
+		partitionField:  "announced_on",
+		mongoCollection: "crunchbaseFundingRounds",
+		mongoEnv:        "COLL_CB_FUNDING_ROUNDS",
+		decode:          decodeFundingRounds,
+		parse:           parseFundingRound,
+		newDocument:     func() document { return new(FundingRoundDocument) },
+	},
+	"principal.investors": {
+		id:              "principal.investors",
+		searchPath:      "/v4/data/searches/principal.investors",
+		fieldIds:        []string{"identifier", "investor_type", "investor_stage", "location_identifiers", "num_investments", "num_lead_investments", "num_exits", "num_portfolio_organizations", "short_description", "website", "linkedin"},
+		order:           []QueryOrder{{FieldId: "num_investments", Sort: "desc"}},
+		updatedField:    "updated_at",
+		mongoCollection: "crunchbaseInvestors",
+		mongoEnv:        "COLL_CB_INVESTORS",
+		decode:          decodeInvestors,
+		parse:           parseInvestor,
+		newDocument:     func() document { return new(InvestorDocument) },
+	},
+}
+
+// lookupCollection, returns the supported collection with the collection_id
+// 'id'.
+func lookupCollection(id string) (*entityCollection, error) {
+	collection, ok := entityCollections[id]
+	if !ok {
+		ids := make([]string, 0, len(entityCollections))
+		for id := range entityCollections {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		return nil, fmt.Errorf("unsupported collection '%s', supported collections: %v", id, ids)
+	}
+	return collection, nil
+}
+
+// mongoCollection, returns the MongoDB collection in which the documents of
+// 'collection' are stored.
+func (app *application) mongoCollection(collection *entityCollection) string {
+	if collection.id == defaultCollectionId {
+		return app.collCB
+	}
+	if coll := os.Getenv(collection.mongoEnv); coll != "" {
+		return coll
+	}
+	return collection.mongoCollection
+}
+
+func (document OrganizationDocument) entityUuid() string {
+	return document.Uuid
+}
+
+func (document PersonDocument) entityUuid() string {
+	return document.Uuid
+}
+
+func (document FundingRoundDocument) entityUuid() string {
+	return document.Uuid
+}
+
+func (document InvestorDocument) entityUuid() string {
+	return document.Uuid
+}
+
+// decodeOrganizations, decodes a page of the 'organization.companies'
+// collection.
+func decodeOrganizations(payload []byte) ([]document, error) {
+	return decodeEntities(payload, parseOrganization)
+}
+
+// parseOrganization, parses an entity of the 'organization.companies'
+// collection.
+func parseOrganization(raw json.RawMessage) (document, error) {
+	entity := Entity{}
+	if err := json.Unmarshal(raw, &entity); err != nil {
+		return nil, fmt.Errorf("unable to decode entity into Entity type: %w", err)
+	}
+	organizationDocument := OrganizationDocument{}
+	if err := organizationDocument.parseRawData(entity); err != nil {
+		return nil, fmt.Errorf("unable to parse CB data into organizationDocument type: %w", err)
+	}
+	extras, err := unknownProperties(raw, Properties{})
+	if err != nil {
+		return nil, err
+	}
+	organizationDocument.Extras = extras
+	return organizationDocument, nil
+}
+
+// decodePeople, decodes a page of the 'people' collection.
+func decodePeople(payload []byte) ([]document, error) {
+	return decodeEntities(payload, parsePerson)
+}
+
+// parsePerson, parses an entity of the 'people' collection.
+func parsePerson(raw json.RawMessage) (document, error) {
+	entity := struct {
+		Uuid       string           `json:"uuid"`
+		Properties PersonProperties `json:"properties"`
+	}{}
+	if err := json.Unmarshal(raw, &entity); err != nil {
+		return nil, fmt.Errorf("unable to decode entity into person: %w", err)
+	}
+	extras, err := unknownProperties(raw, PersonProperties{})
+	if err != nil {
+		return nil, err
+	}
+
+	properties := entity.Properties
+	return PersonDocument{
+		Uuid:                    entity.Uuid,
+		Timestamp:               time.Now(),
+		EntityDefId:             properties.Identifier["entity_def_id"],
+		Name:                    properties.Identifier["value"],
+		FirstName:               properties.FirstName,
+		LastName:                properties.LastName,
+		Gender:                  properties.Gender,
+		PrimaryJobTitle:         properties.PrimaryJobTitle,
+		PrimaryOrganization:     properties.PrimaryOrganization,
+		City:                    locationName(properties.Locations, "city"),
+		Country:                 locationName(properties.Locations, "country"),
+		Description:             properties.Description,
+		Linkedin:                properties.Linkedin["value"],
+		Twitter:                 properties.Twitter["value"],
+		NumFoundedOrganizations: properties.NumFoundedOrganizations,
+		NumInvestments:          properties.NumInvestments,
+		NumExits:                properties.NumExits,
+		Extras:                  extras,
+	}, nil
+}
+
+// decodeFundingRounds, decodes a page of the 'funding_rounds' collection.
+func decodeFundingRounds(payload []byte) ([]document, error) {
+	return decodeEntities(payload, parseFundingRound)
+}
+
+// parseFundingRound, parses an entity of the 'funding_rounds' collection.
+func parseFundingRound(raw json.RawMessage) (document, error) {
+	entity := struct {
+		Uuid       string                 `json:"uuid"`
+		Properties FundingRoundProperties `json:"properties"`
+	}{}
+	if err := json.Unmarshal(raw, &entity); err != nil {
+		return nil, fmt.Errorf("unable to decode entity into funding round: %w", err)
+	}
+	extras, err := unknownProperties(raw, FundingRoundProperties{})
+	if err != nil {
+		return nil, err
+	}
+
+	properties := entity.Properties
+	fundingRoundDocument := FundingRoundDocument{
+		Uuid:                    entity.Uuid,
+		Timestamp:               time.Now(),
+		EntityDefId:             properties.Identifier["entity_def_id"],
+		Name:                    properties.Identifier["value"],
+		FundedOrganization:      properties.FundedOrganization,
+		InvestmentType:          properties.InvestmentType,
+		MoneyRaised:             properties.MoneyRaised.ValueUSD,
+		PreMoneyValuation:       properties.PreMoneyValuation.ValueUSD,
+		NumInvestors:            properties.NumInvestors,
+		LeadInvestorIdentifiers: properties.LeadInvestorIdentifiers,
+		InvestorIdentifiers:     properties.InvestorIdentifiers,
+		Extras:                  extras,
+	}
+	// Some rounds have no announcement date.
+	announcedOn, err := parseCBDate(properties.AnnouncedOn, "")
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse AnnouncedOn field of funding round %s into Date value: %w", entity.Uuid, err)
+	}
+	fundingRoundDocument.AnnouncedOn = announcedOn
+	return fundingRoundDocument, nil
+}
+
+// decodeInvestors, decodes a page of the 'principal.investors' collection.
+func decodeInvestors(payload []byte) ([]document, error) {
+	return decodeEntities(payload, parseInvestor)
+}
+
+// parseInvestor, parses an entity of the 'principal.investors' collection.
+func parseInvestor(raw json.RawMessage) (document, error) {
+	entity := struct {
+		Uuid       string             `json:"uuid"`
+		Properties InvestorProperties `json:"properties"`
+	}{}
+	if err := json.Unmarshal(raw, &entity); err != nil {
+		return nil, fmt.Errorf("unable to decode entity into investor: %w", err)
+	}
+	extras, err := unknownProperties(raw, InvestorProperties{})
+	if err != nil {
+		return nil, err
+	}
+
+	properties := entity.Properties
+	return InvestorDocument{
+		Uuid:                      entity.Uuid,
+		Timestamp:                 time.Now(),
+		EntityDefId:               properties.Identifier["entity_def_id"],
+		Name:                      properties.Identifier["value"],
+		InvestorType:              properties.InvestorType,
+		InvestorStage:             properties.InvestorStage,
+		City:                      locationName(properties.Locations, "city"),
+		Country:                   locationName(properties.Locations, "country"),
+		NumInvestments:            properties.NumInvestments,
+		NumLeadInvestments:        properties.NumLeadInvestments,
+		NumExits:                  properties.NumExits,
+		NumPortfolioOrganizations: properties.NumPortfolioOrganizations,
+		ShortDescription:          properties.ShortDescription,
+		Website:                   properties.Website["value"],
+		Linkedin:                  properties.Linkedin["value"],
+		Extras:                    extras,
+	}, nil
+}
+
+// locationName, returns the name of the first location of type
+// 'locationType' (e.g. 'city' or 'country'), or an empty string if there is
+// no such location.
+func locationName(locations []Location, locationType string) string {
+	filtered := FilterLocation(locations, func(location Location) bool {
+		return location.LocationType == locationType
+	})
+	if len(filtered) == 0 {
+		return ""
+	}
+	return filtered[0].Name
+}
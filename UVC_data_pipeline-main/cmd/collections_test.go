@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecodeFundingRounds(t *testing.T) {
+	tests := []struct {
+		name            string
+		payload         string
+		wantAnnouncedOn Date
+		// wantQuarantined, the round cannot be parsed and is quarantined.
+		wantQuarantined bool
+		wantErr         bool
+	}{
+		{
+			name:            "Round with announcement date",
+			payload:         `{"entities":[{"uuid":"3f","properties":{"identifier":{"value":"Seed Round - Blub.ai"},"announced_on":"2022-03-14","money_raised":{"value_usd":1500000}}}]}`,
+			wantAnnouncedOn: Date{Time: time.Date(2022, time.March, 14, 0, 0, 0, 0, time.UTC), Precision: PrecisionDay},
+			wantErr:         false,
+		},
+		{
+			name:            "Round without announcement date",
+			payload:         `{"entities":[{"uuid":"3f","properties":{"identifier":{"value":"Seed Round - Blub.ai"}}}]}`,
+			wantAnnouncedOn: Date{},
+			wantErr:         false,
+		},
+		{
+			name:            "Invalid announcement date",
+			payload:         `{"entities":[{"uuid":"3f","properties":{"announced_on":"14.03.2022"}}]}`,
+			wantQuarantined: true,
+			wantErr:         false,
+		},
+		{
+			name:    "Invalid page",
+			payload: `{"entities":{"uuid":"3f"}}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			documents, err := decodeFundingRounds([]byte(tt.payload))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decodeFundingRounds() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(documents) != 1 {
+				t.Fatalf("decodeFundingRounds() returned %d documents, want 1", len(documents))
+			}
+			quarantined, ok := documents[0].(quarantinedEntity)
+			if ok != tt.wantQuarantined {
+				t.Fatalf("quarantined = %t, want %t", ok, tt.wantQuarantined)
+			}
+			if ok {
+				if quarantined.entityUuid() != "3f" {
+					t.Errorf("uuid of quarantined entity = %s, want 3f", quarantined.entityUuid())
+				}
+				return
+			}
+			fundingRound := documents[0].(FundingRoundDocument)
+			if fundingRound.entityUuid() != "3f" {
+				t.Errorf("uuid = %s, want 3f", fundingRound.entityUuid())
+			}
+			if fundingRound.AnnouncedOn != tt.wantAnnouncedOn {
+				t.Errorf("AnnouncedOn = %v, want %v", fundingRound.AnnouncedOn, tt.wantAnnouncedOn)
+			}
+		})
+	}
+}
+
+func TestWebSearchURL(t *testing.T) {
+	tests := []struct {
+		name         string
+		collectionId string
+		cbBaseURL    string
+		want         string
+	}{
+		{name: "Organizations", collectionId: "organization.companies", want: "https://www.crunchbase.com/v4/data/lists/organization.companies/343cbe1f-7511-4263-939c-0c3f3f7a729d?source=list"},
+		{name: "People", collectionId: "people", want: "https://www.crunchbase.com/v4/data/searches/people"},
+		{name: "Organizations on a base URL", collectionId: "organization.companies", cbBaseURL: "http://127.0.0.1:8080", want: "http://127.0.0.1:8080/v4/data/lists/organization.companies/343cbe1f-7511-4263-939c-0c3f3f7a729d?source=list"},
+		{name: "Funding rounds on a base URL", collectionId: "funding_rounds", cbBaseURL: "http://127.0.0.1:8080", want: "http://127.0.0.1:8080/v4/data/searches/funding_rounds"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newFixtureTestApplication()
+			app.collection = entityCollections[tt.collectionId]
+			app.cbBaseURL = tt.cbBaseURL
+			got, err := app.webSearchURL()
+			if err != nil {
+				t.Fatalf("webSearchURL() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("webSearchURL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// backendCookie, extraction through the Crunchbase web search, using the
+// session cookies of a CB account and browser-like headers.
+const backendCookie = "cookie"
+
+// cookieBackend, search backend of the Crunchbase web search. The requests are
+// authenticated with the session cookies of the CB account and carry the
+// browser-like headers chosen for the run (app.cbCustomHeader).
+type cookieBackend struct {
+	app *application
+}
+
+// newCookieBackend, returns the search backend of the Crunchbase web search.
+func newCookieBackend(app *application) searchBackend {
+	return &cookieBackend{app: app}
+}
+
+// authenticate, reuses the session of a previous run, if it did not expire,
+// otherwise it logs in with the credentials of the CB account.
+func (b *cookieBackend) authenticate(ctx context.Context) error {
+	if b.app.cbUsername == "" || b.app.cbPassword == "" {
+		return fmt.Errorf("CB_USERNAME and CB_PASSWORD in .env file are required by the '%s' backend", backendCookie)
+	}
+	return b.app.handleAuthenticationPersistentCookies(ctx)
+}
+
+// supports, every collection can be searched through the web search.
+func (b *cookieBackend) supports(collection *entityCollection) error {
+	return nil
+}
+
+// searchURL, returns the web search endpoint of app.collection.
+func (b *cookieBackend) searchURL() (string, error) {
+	return b.app.webSearchURL()
+}
+
+// searchPayload, encodes 'query' as the body of a web search request.
+func (b *cookieBackend) searchPayload(query *Query, limit int, afterId string) ([]byte, error) {
+	return query.payload(limit, afterId)
+}
+
+// addHeaders, adds the browser-like headers of the run to 'req'.
+func (b *cookieBackend) addHeaders(req *http.Request) {
+	// The general and custom headers are required to trick the Crunchbase
+	// API to think that we are not a bot.
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("User-Agent", b.app.cbCustomHeader.UserAgent)
+	req.Header.Add("Accept", "*/*")
+	req.Header.Add("Accept-Language", b.app.cbCustomHeader.AcceptLanguage)
+	req.Header.Add("Referer", b.app.cbCustomHeader.UrlReferer)
+}
+
+// renewSession, logs in again, the new session is stored for the next runs.
+func (b *cookieBackend) renewSession(ctx context.Context) (bool, error) {
+	if err := b.app.renewSession(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// webSearchURL, returns the URL of the web search endpoint of app.collection,
+// moved to app.cbBaseURL if it is set. Collections without their own endpoint
+// (organizations) are searched through the randomly chosen CB referer URL.
+func (app *application) webSearchURL() (string, error) {
+	rawURL := app.cbCustomHeader.UrlReferer
+	if app.collection != nil && app.collection.searchPath != "" {
+		rawURL = crunchbaseWebURL + app.collection.searchPath
+	}
+	u, err := app.webURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// login, requests new login/auth credentials to the Crunchbase API, sets the
+// new cookies to the app.Client and if the parameter 'storeCookies' is true, it
+// stores the new cookies on a persistent external file. The login is aborted if
+// 'ctx' is cancelled.
+func (app *application) login(ctx context.Context, storeCookies bool) error {
+	// Create the request to get new session cookies.
+	urlSessions, err := app.webURL(crunchbaseWebURL + "/v4/cb/sessions")
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}{app.cbUsername, app.cbPassword})
+	if err != nil {
+		return fmt.Errorf("unable to create the payload of the login request: %w", err)
+	}
+	// Configure a timeout for the client's HTTP request. If the request takes
+	// more than this time duration, then it should be cancelled.
+	loginRequestDuration := time.Duration(time.Second * 45)
+
+	app.infoLog.Print("Requesting new auth credentials from CB API.")
+	// Send request through app.client (HTTP Client), transient failures (429,
+	// 5xx) are retried.
+	res, _, err := app.doWithRetry(ctx, "login", loginRequestDuration, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", urlSessions.String(), bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("unable to create a new POST request (login) with timeout context: %w", err)
+		}
+
+		// The general and custom headers are required to trick the
+		// Crunchbase API to think that we are not a bot.
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("User-Agent", app.cbCustomHeader.UserAgent)
+		req.Header.Add("Accept", "*/*")
+		req.Header.Add("Accept-Language", app.cbCustomHeader.AcceptLanguage)
+		req.Header.Add("Accept-Encoding", "gzip, deflate, br")
+		req.Header.Add("Referer", crunchbaseWebURL+"/login")
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Check if we got a 201 Code response (201 Created), if not return error
+	// with status code. The API returns 201 when creating new auth cookies.
+	if res.StatusCode != 201 {
+		err = fmt.Errorf("HTTP request (login) failed with status code %d (%s).\n", res.StatusCode, res.Status)
+		return err
+	}
+
+	// Transform Crunchbase's API URL into *url.URL.
+	urlObj, err := app.webURL(crunchbaseWebURL + "/v4/")
+	if err != nil {
+		return fmt.Errorf("unable to parse CB url: %w", err)
+	}
+	// Add retrieved cookies to cookie jar from app.client object.
+	app.client.Jar.SetCookies(urlObj, res.Cookies())
+
+	if storeCookies {
+		// Store newly created cookies in an external file to guarantee
+		// persistency of cookies between different program executions.
+		if err = app.storeCookies(res); err != nil {
+			return fmt.Errorf("unable to store cookies in external file: %w", err)
+		}
+	}
+	// After a sucessful cookie retrieval, sleep for a randomly-generated amount
+	// of seconds, in order to simulate a more human-like online behaviour.
+	// A human does not send an API request nanoseconds after logging in.
+	// A max delay of 0 disables the delay, e.g. for a local fake of
+	// Crunchbase.
+	if app.userConfigurations.maxDelayLogin == 0 {
+		return nil
+	}
+	delay, err := app.calculateRandomDelay(app.userConfigurations.minDelayLogin, app.userConfigurations.maxDelayLogin)
+	if err != nil {
+		return fmt.Errorf("unable to create a random time delay after retrieval of the cookies: %w", err)
+	}
+	app.infoLog.Printf("Delay after a successful cookie retrieval: %ds.\n", delay)
+	if err := sleepContext(ctx, time.Duration(delay)*time.Second); err != nil {
+		return fmt.Errorf("login was cancelled: %w", err)
+	}
+
+	return nil
+
+}
+
+// handleAuthentication, handles getting session cookies by sending a login
+// request to the Crunchbase API.
+// If it returns an error, exit the application, fatal error.
+func (app *application) handleAuthentication(ctx context.Context) error {
+	// Parameter for app.login = false, so that login() does not store the
+	// cookies on a persistent file.
+	if err := app.login(ctx, false); err != nil {
+		// If login fails, exit program, fatal error.
+		return fmt.Errorf("unable to login (authenticate) into Crunchbase API: %w", err)
+	}
+	app.infoLog.Print("Received new cookies from Crunchbase API.")
+	return nil
+}
+
+// handleAuthenticationPersistentCookies, handles getting session cookies by
+// sending a login request to the Crunchbase API. If first tries to load an
+// already present persistent file with cookies. It also stores new cookies,
+// if it needs to ask for new cookies to the CB API.
+// If it returns an error, exit the application, fatal error.
+func (app *application) handleAuthenticationPersistentCookies(ctx context.Context) error {
+	err := app.loadCookies()
+	if err != nil {
+		// There is no cookies file before the first run, this is not an
+		// error.
+		app.infoLog.Printf("No stored session can be reused (%v).", err)
+		// If loadCookies fails, try to get new cookies through login().
+		// loadCookies() can fail, if for example, there is no file in
+		// the local repository that contains the current cookies.
+		err = app.login(ctx, true)
+		if err != nil {
+			// If login fails, exit program, fatal error.
+			return fmt.Errorf("unable to login (authenticate) into Crunchbase API: %w", err)
+		}
+		app.infoLog.Print("Received new cookies from Crunchbase API.")
+	}
+	return nil
+}
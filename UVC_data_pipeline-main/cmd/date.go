@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// DatePrecision, precision of a date reported by Crunchbase, e.g. 'year' if
+// only the year in which a company was founded is known.
+type DatePrecision string
+
+const (
+	PrecisionDay   DatePrecision = "day"
+	PrecisionMonth DatePrecision = "month"
+	PrecisionYear  DatePrecision = "year"
+)
+
+// precisionLayouts, layout of a date of every precision, a Date is encoded
+// as JSON in the layout of its precision, e.g. '2021' or '2021-03'.
+var precisionLayouts = map[DatePrecision]string{
+	PrecisionDay:   "2006-01-02",
+	PrecisionMonth: "2006-01",
+	PrecisionYear:  "2006",
+}
+
+// Date, date with its precision, so that a company founded in 2021 (precision
+// 'year') is not mistaken for a company founded on 1 January 2021. The zero
+// value is an unknown date. A Date is encoded as a string in the layout of
+// its precision in JSON (e.g. '2021-03') and as a BSON date in BSON, so that
+// it can be queried and indexed as a date. The documents store the precision
+// of their dates in a sibling field, e.g. 'foundedOnPrecision'.
+type Date struct {
+	Time      time.Time
+	Precision DatePrecision
+}
+
+// NewDate, returns the Date of 't' with the precision 'precision', the time
+// is truncated to the first day of the month or year of its precision.
+func NewDate(t time.Time, precision DatePrecision) Date {
+	t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch precision {
+	case PrecisionYear:
+		t = time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	case PrecisionMonth:
+		t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		precision = PrecisionDay
+	}
+	return Date{Time: t, Precision: precision}
+}
+
+// parseCBDate, parses a date of the Crunchbase API (e.g. '2021-01-01') with
+// the precision reported by the API. The precision 'day' is assumed if it is
+// empty, an empty date is returned as the zero Date.
+func parseCBDate(value string, precision string) (Date, error) {
+	if value == "" {
+		return Date{}, nil
+	}
+	if precision == "" {
+		precision = string(PrecisionDay)
+	}
+	layout, ok := precisionLayouts[DatePrecision(precision)]
+	if !ok {
+		return Date{}, fmt.Errorf("unknown date precision '%s'", precision)
+	}
+	// The API reports the first day of the month or year if the precision
+	// is lower than a day, but a date in the layout of its precision is
+	// accepted as well.
+	t, err := time.Parse(precisionLayouts[PrecisionDay], value)
+	if err != nil {
+		if t, err = time.Parse(layout, value); err != nil {
+			return Date{}, err
+		}
+	}
+	return NewDate(t, DatePrecision(precision)), nil
+}
+
+// layoutPrecision, returns the precision of the dates formatted with
+// 'layout', e.g. 'month' for 'Jan 2006'.
+func layoutPrecision(layout string) DatePrecision {
+	day := time.Date(2021, time.March, 17, 0, 0, 0, 0, time.UTC)
+	if day.Format(layout) != day.AddDate(0, 0, 1).Format(layout) {
+		return PrecisionDay
+	}
+	if day.Format(layout) != day.AddDate(0, 1, 0).Format(layout) {
+		return PrecisionMonth
+	}
+	return PrecisionYear
+}
+
+// IsZero, returns true if the date is unknown.
+func (d Date) IsZero() bool {
+	return d.Time.IsZero()
+}
+
+// String, returns the date in the layout of its precision, e.g. '2021' for
+// the precision 'year', or an empty string if the date is unknown.
+func (d Date) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	layout, ok := precisionLayouts[d.Precision]
+	if !ok {
+		layout = precisionLayouts[PrecisionDay]
+	}
+	return d.Time.Format(layout)
+}
+
+// MarshalJSON, encodes the date as a string in the layout of its precision,
+// an unknown date is encoded as null.
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON, decodes a date in the layout of its precision. Dates stored
+// as timestamps (before dates had a precision) have the precision 'day'.
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var value *string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("unable to decode date: %w", err)
+	}
+	*d = Date{}
+	if value == nil || *value == "" {
+		return nil
+	}
+	for _, precision := range []DatePrecision{PrecisionDay, PrecisionMonth, PrecisionYear} {
+		if t, err := time.Parse(precisionLayouts[precision], *value); err == nil {
+			*d = NewDate(t, precision)
+			return nil
+		}
+	}
+	t, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return fmt.Errorf("unable to decode date '%s': %w", *value, err)
+	}
+	if !t.IsZero() {
+		*d = NewDate(t, PrecisionDay)
+	}
+	return nil
+}
+
+// MarshalBSONValue, encodes the date as a BSON date, an unknown date is
+// encoded as null. The precision is not part of the value, see
+// storedPrecision.
+func (d Date) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	if d.IsZero() {
+		return bsontype.Null, nil, nil
+	}
+	return bson.MarshalValue(d.Time)
+}
+
+// UnmarshalBSONValue, decodes a BSON date with the precision 'day', the
+// precision stored in the sibling field of the date is applied by
+// withPrecision.
+func (d *Date) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	*d = Date{}
+	switch t {
+	case bsontype.Null, bsontype.Undefined:
+		return nil
+	case bsontype.DateTime:
+		stored, ok := (bson.RawValue{Type: t, Value: data}).TimeOK()
+		if !ok {
+			return fmt.Errorf("unable to decode BSON date")
+		}
+		if !stored.IsZero() {
+			*d = NewDate(stored.UTC(), PrecisionDay)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unable to decode BSON %s as a date", t)
+	}
+}
+
+// storedPrecision, returns the precision which is stored in the sibling
+// field of the date 'd', empty if the date is unknown.
+func storedPrecision(d Date) DatePrecision {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Precision
+}
+
+// withPrecision, returns the date 'd' decoded from BSON with the precision
+// 'precision' stored in its sibling field. Documents stored without the
+// sibling field keep the precision of the decoded date.
+func withPrecision(d Date, precision DatePrecision) Date {
+	if d.IsZero() || precision == "" {
+		return d
+	}
+	return Date{Time: d.Time, Precision: precision}
+}
+
+// organizationFields, fields of an OrganizationDocument without its BSON
+// methods.
+type organizationFields OrganizationDocument
+
+// bsonOrganizationDocument, BSON document of an OrganizationDocument, with
+// the precision of every date in a sibling field.
+type bsonOrganizationDocument struct {
+	Fields                         organizationFields `bson:",inline"`
+	FoundedOnPrecision             DatePrecision      `bson:"foundedOnPrecision,omitempty"`
+	LastFundingAtPrecision         DatePrecision      `bson:"lastFundingAtPrecision,omitempty"`
+	LastKeyEmployeeChangePrecision DatePrecision      `bson:"lastKeyEmployeeChangePrecision,omitempty"`
+	LastLayoffDatePrecision        DatePrecision      `bson:"lastLayoffDatePrecision,omitempty"`
+}
+
+// MarshalBSON, encodes the organization with the precision of its dates in
+// sibling fields.
+func (d OrganizationDocument) MarshalBSON() ([]byte, error) {
+	return bson.Marshal(bsonOrganizationDocument{
+		Fields:                         organizationFields(d),
+		FoundedOnPrecision:             storedPrecision(d.FoundedOn),
+		LastFundingAtPrecision:         storedPrecision(d.LastFundingAt),
+		LastKeyEmployeeChangePrecision: storedPrecision(d.LastKeyEmployeeChange),
+		LastLayoffDatePrecision:        storedPrecision(d.LastLayoffDate),
+	})
+}
+
+// UnmarshalBSON, decodes an organization encoded by MarshalBSON.
+func (d *OrganizationDocument) UnmarshalBSON(data []byte) error {
+	stored := bsonOrganizationDocument{}
+	if err := bson.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+	*d = OrganizationDocument(stored.Fields)
+	d.FoundedOn = withPrecision(d.FoundedOn, stored.FoundedOnPrecision)
+	d.LastFundingAt = withPrecision(d.LastFundingAt, stored.LastFundingAtPrecision)
+	d.LastKeyEmployeeChange = withPrecision(d.LastKeyEmployeeChange, stored.LastKeyEmployeeChangePrecision)
+	d.LastLayoffDate = withPrecision(d.LastLayoffDate, stored.LastLayoffDatePrecision)
+	return nil
+}
+
+// fundingRoundFields, fields of a FundingRoundDocument without its BSON
+// methods.
+type fundingRoundFields FundingRoundDocument
+
+// bsonFundingRoundDocument, BSON document of a FundingRoundDocument, with
+// the precision of its date in a sibling field.
+type bsonFundingRoundDocument struct {
+	Fields               fundingRoundFields `bson:",inline"`
+	AnnouncedOnPrecision DatePrecision      `bson:"announcedOnPrecision,omitempty"`
+}
+
+// MarshalBSON, encodes the funding round with the precision of its date in a
+// sibling field.
+func (d FundingRoundDocument) MarshalBSON() ([]byte, error) {
+	return bson.Marshal(bsonFundingRoundDocument{
+		Fields:               fundingRoundFields(d),
+		AnnouncedOnPrecision: storedPrecision(d.AnnouncedOn),
+	})
+}
+
+// UnmarshalBSON, decodes a funding round encoded by MarshalBSON.
+func (d *FundingRoundDocument) UnmarshalBSON(data []byte) error {
+	stored := bsonFundingRoundDocument{}
+	if err := bson.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+	*d = FundingRoundDocument(stored.Fields)
+	d.AnnouncedOn = withPrecision(d.AnnouncedOn, stored.AnnouncedOnPrecision)
+	return nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseCBDate(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		precision string
+		want      string
+		wantErr   bool
+	}{
+		{name: "Day", value: "2021-03-14", precision: "day", want: "2021-03-14"},
+		{name: "Month", value: "2021-03-01", precision: "month", want: "2021-03"},
+		{name: "Year", value: "2021-01-01", precision: "year", want: "2021"},
+		{name: "Year in the layout of its precision", value: "2021", precision: "year", want: "2021"},
+		{name: "Without precision", value: "2021-03-14", precision: "", want: "2021-03-14"},
+		{name: "Empty date", value: "", precision: "year", want: ""},
+		{name: "Unknown precision", value: "2021-01-01", precision: "quarter", wantErr: true},
+		{name: "Invalid date", value: "14.03.2021", precision: "day", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			date, err := parseCBDate(tt.value, tt.precision)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCBDate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if date.String() != tt.want {
+				t.Errorf("parseCBDate() = %s, want %s", date, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayoutPrecision(t *testing.T) {
+	tests := map[string]DatePrecision{
+		"2006-01-02":   PrecisionDay,
+		"Jan 2, 2006":  PrecisionDay,
+		"02.01.2006":   PrecisionDay,
+		"2006-01":      PrecisionMonth,
+		"January 2006": PrecisionMonth,
+		"2006":         PrecisionYear,
+	}
+	for layout, want := range tests {
+		if got := layoutPrecision(layout); got != want {
+			t.Errorf("layoutPrecision(%q) = %s, want %s", layout, got, want)
+		}
+	}
+}
+
+func TestDateJSON(t *testing.T) {
+	year := NewDate(time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC), PrecisionYear)
+	month := NewDate(time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC), PrecisionMonth)
+	day := NewDate(time.Date(2021, time.March, 14, 0, 0, 0, 0, time.UTC), PrecisionDay)
+
+	tests := []struct {
+		name     string
+		date     Date
+		wantJSON string
+	}{
+		{name: "Year", date: year, wantJSON: `"2021"`},
+		{name: "Month", date: month, wantJSON: `"2021-03"`},
+		{name: "Day", date: day, wantJSON: `"2021-03-14"`},
+		{name: "Unknown date", date: Date{}, wantJSON: `null`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.date)
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			if string(data) != tt.wantJSON {
+				t.Errorf("json.Marshal() = %s, want %s", data, tt.wantJSON)
+			}
+			got := Date{}
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if got != tt.date {
+				t.Errorf("json.Unmarshal() = %v, want %v", got, tt.date)
+			}
+		})
+	}
+
+	// Documents stored before dates had a precision contain timestamps.
+	legacy := map[string]Date{
+		`"2021-03-14T00:00:00Z"`: day,
+		`"0001-01-01T00:00:00Z"`: {},
+		`""`:                     {},
+	}
+	for data, want := range legacy {
+		got := Date{}
+		if err := json.Unmarshal([]byte(data), &got); err != nil {
+			t.Fatalf("json.Unmarshal(%s) error = %v", data, err)
+		}
+		if got != want {
+			t.Errorf("json.Unmarshal(%s) = %v, want %v", data, got, want)
+		}
+	}
+	if err := json.Unmarshal([]byte(`"sometime"`), &Date{}); err == nil {
+		t.Errorf("json.Unmarshal() of an invalid date returned no error")
+	}
+}
+
+func TestDateBSON(t *testing.T) {
+	tests := []struct {
+		name string
+		date Date
+		// wantPrecision, precision stored next to the date.
+		wantPrecision interface{}
+	}{
+		{name: "Year", date: NewDate(time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC), PrecisionYear), wantPrecision: "year"},
+		{name: "Day", date: NewDate(time.Date(2021, time.March, 14, 0, 0, 0, 0, time.UTC), PrecisionDay), wantPrecision: "day"},
+		{name: "Unknown date", date: Date{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := bson.Marshal(OrganizationDocument{FoundedOn: tt.date})
+			if err != nil {
+				t.Fatalf("bson.Marshal() error = %v", err)
+			}
+			// The date is stored as a BSON date, so that it can be queried
+			// and indexed.
+			stored := bson.M{}
+			if err := bson.Unmarshal(data, &stored); err != nil {
+				t.Fatal(err)
+			}
+			if tt.date.IsZero() {
+				if stored["foundedOn"] != nil {
+					t.Errorf("foundedOn = %v, want null", stored["foundedOn"])
+				}
+			} else if foundedOn, ok := stored["foundedOn"].(primitive.DateTime); !ok || !foundedOn.Time().Equal(tt.date.Time) {
+				t.Errorf("foundedOn = %#v, want the BSON date %v", stored["foundedOn"], tt.date.Time)
+			}
+			if stored["foundedOnPrecision"] != tt.wantPrecision {
+				t.Errorf("foundedOnPrecision = %v, want %v", stored["foundedOnPrecision"], tt.wantPrecision)
+			}
+
+			got := OrganizationDocument{}
+			if err := bson.Unmarshal(data, &got); err != nil {
+				t.Fatalf("bson.Unmarshal() error = %v", err)
+			}
+			if got.FoundedOn != tt.date {
+				t.Errorf("bson.Unmarshal() = %v, want %v", got.FoundedOn, tt.date)
+			}
+		})
+	}
+
+	// The funding rounds store the precision of their date as well.
+	announced := NewDate(time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC), PrecisionMonth)
+	data, err := bson.Marshal(FundingRoundDocument{AnnouncedOn: announced})
+	if err != nil {
+		t.Fatal(err)
+	}
+	round := FundingRoundDocument{}
+	if err := bson.Unmarshal(data, &round); err != nil {
+		t.Fatalf("bson.Unmarshal() error = %v", err)
+	}
+	if round.AnnouncedOn != announced {
+		t.Errorf("bson.Unmarshal() of a funding round = %v, want %v", round.AnnouncedOn, announced)
+	}
+
+	// Documents stored before dates had a precision contain BSON dates
+	// without precision.
+	data, err = bson.Marshal(bson.M{"foundedOn": time.Date(2021, time.March, 14, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := OrganizationDocument{}
+	if err := bson.Unmarshal(data, &got); err != nil {
+		t.Fatalf("bson.Unmarshal() error = %v", err)
+	}
+	if want := NewDate(time.Date(2021, time.March, 14, 0, 0, 0, 0, time.UTC), PrecisionDay); got.FoundedOn != want {
+		t.Errorf("bson.Unmarshal() of a BSON date = %v, want %v", got.FoundedOn, want)
+	}
+}
@@ -54,8 +54,14 @@ func (app *application) configureMongoDB() error {
 	return nil
 }
 
-// insertDB, inserts a local `file` (path of file) into a MongoDB instance.
-func (app *application) insertDB(file string) error {
+// insertDB, inserts a local `file` (path of file) with documents of the
+// Crunchbase collection 'collectionId' into a MongoDB instance.
+func (app *application) insertDB(file, collectionId string) error {
+	collection, err := lookupCollection(collectionId)
+	if err != nil {
+		return err
+	}
+
 	// Read data from file (path of file)
 	fileData, err := os.ReadFile(file)
 	if err != nil {
@@ -63,20 +69,20 @@ func (app *application) insertDB(file string) error {
 	}
 	// Unmarshal .json data into slice.
 	// We suppose that the file's data is composed of many mongo documents.
-	organizationDocumentSlice, err := unmarshalFile(fileData)
+	documents, err := unmarshalFile(fileData, collection.newDocument)
 	if err != nil {
 		return fmt.Errorf("unable to unmarshal data from file %s: %w", file, err)
 	}
 
 	// Documents to be inserted into the db. Create an interface{} slice of the
 	// correct size.
-	docs := make([]interface{}, len(organizationDocumentSlice))
+	docs := make([]interface{}, len(documents))
 	// Populate the interface{} with the values.
-	for i, u := range organizationDocumentSlice {
+	for i, u := range documents {
 		docs[i] = u
 	}
 	// Insert the documents into the DB.
-	if err := app.mongoDB.InsertMultipleDocuments(docs, app.dbName, app.collCB); err != nil {
+	if err := app.mongoDB.InsertMultipleDocuments(docs, app.dbName, app.mongoCollection(collection)); err != nil {
 		return fmt.Errorf("failed to insert multiple documents into DB: %w", err)
 	}
 
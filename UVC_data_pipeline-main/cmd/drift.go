@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// schemaStateFile, path to the file in which the schema of the properties
+// received by the last full and reconciled run of every collection is stored.
+const schemaStateFile = "./extract-schema.json"
+
+// propertySchema, JSON types (e.g. 'string', 'number' or 'object') of the
+// properties of the raw entities received from Crunchbase, accessible by the
+// name of the property. A property which was only received as null has no
+// types.
+type propertySchema map[string][]string
+
+// jsonType, returns the JSON type of the raw value 'value'.
+func jsonType(value json.RawMessage) string {
+	if len(value) == 0 {
+		return "null"
+	}
+	switch value[0] {
+	case '"':
+		return "string"
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case 't', 'f':
+		return "boolean"
+	case 'n':
+		return "null"
+	default:
+		return "number"
+	}
+}
+
+// observe, adds the properties of the raw entity 'raw' and their types to the
+// schema.
+func (schema propertySchema) observe(raw json.RawMessage) {
+	entity := struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}{}
+	// An entity which cannot be decoded is quarantined by its collection.
+	if err := json.Unmarshal(raw, &entity); err != nil {
+		return
+	}
+	for name, value := range entity.Properties {
+		types := schema[name]
+		if types == nil {
+			types = []string{}
+		}
+		if t := jsonType(value); t != "null" && !containsString(types, t) {
+			types = append(types, t)
+			sort.Strings(types)
+		}
+		schema[name] = types
+	}
+}
+
+// observePage, adds the properties of all raw entities of the page 'body' to
+// the schema. A page which was received again (e.g. by a resumed run) does
+// not change the schema.
+func (schema propertySchema) observePage(body []byte) {
+	page := struct {
+		Entities []json.RawMessage `json:"entities"`
+	}{}
+	if err := json.Unmarshal(body, &page); err != nil {
+		return
+	}
+	for _, entity := range page.Entities {
+		schema.observe(entity)
+	}
+}
+
+// containsString, returns true if 'values' contains 'value'.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// collectionSchema, schema of the properties received by the last full and
+// reconciled run of a collection.
+type collectionSchema struct {
+	RunId string `json:"run_id"`
+	// FieldIds, fields requested by the query of the run.
+	FieldIds    []string       `json:"field_ids"`
+	Properties  propertySchema `json:"properties"`
+	CompletedAt time.Time      `json:"completed_at"`
+}
+
+// schemaState, schemas of the last full and reconciled runs, accessible by
+// the ID of the collection.
+type schemaState struct {
+	Collections map[string]collectionSchema `json:"collections"`
+}
+
+// loadSchemaState, loads the schema state stored at 'path'. A missing file
+// returns an empty state, e.g. before the first run.
+func loadSchemaState(path string) (*schemaState, error) {
+	state := &schemaState{Collections: map[string]collectionSchema{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read schema state file %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("unable to decode schema state file %s: %w", path, err)
+	}
+	if state.Collections == nil {
+		state.Collections = map[string]collectionSchema{}
+	}
+	return state, nil
+}
+
+// save, stores the schema state at 'path'.
+func (state *schemaState) save(path string) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode schema state as JSON: %w", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("unable to store schema state: %w", err)
+	}
+	return nil
+}
+
+// fieldDrift, property whose presence or types changed since the last run.
+type fieldDrift struct {
+	Field string `json:"field"`
+	// Types, types of the property in the run, empty if the property is
+	// missing.
+	Types []string `json:"types,omitempty"`
+	// PreviousTypes, types of the property in the last run, empty if the
+	// property is new.
+	PreviousTypes []string `json:"previous_types,omitempty"`
+}
+
+// driftReport, changes of the properties received from Crunchbase by a run,
+// compared with the last full and reconciled run of the same collection.
+type driftReport struct {
+	RunId        string `json:"run_id"`
+	CollectionId string `json:"collection_id"`
+	// Partial, true if the run did not receive every entity of the
+	// collection (e.g. an incremental run or a run which did not reconcile).
+	// Crunchbase omits properties without a value, so the properties which
+	// were not received by a partial run are not observed rather than
+	// missing, and the run is not the reference of the next reports.
+	Partial bool `json:"partial,omitempty"`
+	// PreviousRunId, run with which the properties are compared, empty if
+	// the collection was never extracted before.
+	PreviousRunId string `json:"previous_run_id,omitempty"`
+	// New, properties which were not received by the last run.
+	New []fieldDrift `json:"new"`
+	// Missing, properties which were received by the last run but not by
+	// this run.
+	Missing []fieldDrift `json:"missing"`
+	// TypeChanged, properties received with other types than by the last
+	// run.
+	TypeChanged []fieldDrift `json:"type_changed"`
+	// Drifted, true if any property is new, missing or changed its type.
+	Drifted bool `json:"drifted"`
+}
+
+// driftFileName, returns the name of the drift report of a run.
+func driftFileName(runId string) string {
+	return fmt.Sprintf("./CBData_%s.drift.json", runId)
+}
+
+// newDriftReport, compares the properties 'schema' received by the run
+// 'runId', which requested the fields 'fieldIds', with the properties of the
+// last run 'previous'. A property which was only requested by one of both
+// runs is expected to appear or disappear, and is not reported. The missing
+// properties of a 'partial' run are not reported either.
+func newDriftReport(runId, collectionId string, fieldIds []string, schema propertySchema, previous *collectionSchema, partial bool) driftReport {
+	report := driftReport{
+		RunId:        runId,
+		CollectionId: collectionId,
+		Partial:      partial,
+		New:          []fieldDrift{},
+		Missing:      []fieldDrift{},
+		TypeChanged:  []fieldDrift{},
+	}
+	if previous == nil {
+		return report
+	}
+	report.PreviousRunId = previous.RunId
+
+	requested := stringSet(fieldIds)
+	requestedBefore := stringSet(previous.FieldIds)
+	for field, types := range schema {
+		previousTypes, ok := previous.Properties[field]
+		switch {
+		case !ok:
+			if !requested[field] || requestedBefore[field] {
+				report.New = append(report.New, fieldDrift{Field: field, Types: types})
+			}
+		// A property which was only received as null in one of both runs
+		// did not change its type.
+		case len(types) > 0 && len(previousTypes) > 0 && !equalStrings(types, previousTypes):
+			report.TypeChanged = append(report.TypeChanged, fieldDrift{Field: field, Types: types, PreviousTypes: previousTypes})
+		}
+	}
+	for field, previousTypes := range previous.Properties {
+		if _, ok := schema[field]; ok || partial {
+			continue
+		}
+		if requested[field] || !requestedBefore[field] {
+			report.Missing = append(report.Missing, fieldDrift{Field: field, PreviousTypes: previousTypes})
+		}
+	}
+	for _, drifts := range [][]fieldDrift{report.New, report.Missing, report.TypeChanged} {
+		sort.Slice(drifts, func(i, j int) bool { return drifts[i].Field < drifts[j].Field })
+	}
+	report.Drifted = len(report.New)+len(report.Missing)+len(report.TypeChanged) > 0
+	return report
+}
+
+// stringSet, returns the set of 'values'.
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// equalStrings, returns true if 'a' and 'b' contain the same values in the
+// same order.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// save, stores the report as JSON at 'path'.
+func (report driftReport) save(path string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode drift report as JSON: %w", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("unable to store drift report: %w", err)
+	}
+	return nil
+}
+
+// reportDrift, compares the properties received by the complete run of the
+// checkpoint 'cp' with the last full and reconciled run of the collection
+// stored in the schema state file 'path' and stores the drift report of the
+// run. The properties of a full run which reconciled ('reconciled') are
+// recorded as the last schema of the collection; the schema of an
+// incremental run or of a run which missed entities is not, as it would
+// report the properties which it did not receive as missing from the next
+// run on.
+func (app *application) reportDrift(path string, cp *checkpoint, reconciled bool) error {
+	state, err := loadSchemaState(path)
+	if err != nil {
+		return err
+	}
+	var previous *collectionSchema
+	if last, ok := state.Collections[app.collection.id]; ok {
+		previous = &last
+	}
+	partial := !app.incrementalSince.IsZero() || !reconciled
+	report := newDriftReport(cp.RunId, app.collection.id, app.query.FieldIds, cp.Schema, previous, partial)
+	app.logDrift(report)
+	if err := report.save(driftFileName(cp.RunId)); err != nil {
+		return err
+	}
+	if partial {
+		return nil
+	}
+
+	state.Collections[app.collection.id] = collectionSchema{
+		RunId:       cp.RunId,
+		FieldIds:    app.query.FieldIds,
+		Properties:  cp.Schema,
+		CompletedAt: time.Now(),
+	}
+	if err := state.save(path); err != nil {
+		return fmt.Errorf("the schema of run %s could not be recorded for the next drift report: %w", cp.RunId, err)
+	}
+	return nil
+}
+
+// logDrift, prints a summary of the drift report and every drifted property.
+func (app *application) logDrift(report driftReport) {
+	if report.Partial {
+		app.infoLog.Printf("Run %s did not receive every entity of collection %s, its properties are not the reference of the next drift report and properties which it did not receive are not reported as missing.", report.RunId, report.CollectionId)
+	}
+	if report.PreviousRunId == "" {
+		if !report.Partial {
+			app.infoLog.Printf("No previous run of collection %s, the properties of run %s are the reference of the next drift report.", report.CollectionId, report.RunId)
+		}
+		return
+	}
+	if !report.Drifted {
+		app.infoLog.Printf("The properties received by run %s match the last run %s.", report.RunId, report.PreviousRunId)
+		return
+	}
+	app.errorLog.Printf("The properties received by run %s drifted from the last run %s: %d new, %d missing, %d with another type, check the report %s.", report.RunId, report.PreviousRunId, len(report.New), len(report.Missing), len(report.TypeChanged), driftFileName(report.RunId))
+	for _, d := range report.New {
+		app.errorLog.Printf("New property %s %v.", d.Field, d.Types)
+	}
+	for _, d := range report.Missing {
+		app.errorLog.Printf("Missing property %s %v.", d.Field, d.PreviousTypes)
+	}
+	for _, d := range report.TypeChanged {
+		app.errorLog.Printf("Property %s changed its type from %v to %v.", d.Field, d.PreviousTypes, d.Types)
+	}
+}
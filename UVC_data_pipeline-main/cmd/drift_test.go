@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/erodrigufer/UVC_data_pipeline/internal/cbfake"
+)
+
+func TestPropertySchemaObservePage(t *testing.T) {
+	schema := propertySchema{}
+	page := []byte(`{"entities":[
+		{"uuid":"1a","properties":{"identifier":{"value":"Blub.ai"},"num_founders":2,"last_layoff_date":null}},
+		{"uuid":"2a","properties":{"num_founders":"two","investor_type":["angel"],"exited":false}}
+	]}`)
+	schema.observePage(page)
+	// A page which is received again does not change the schema.
+	schema.observePage(page)
+
+	want := propertySchema{
+		"identifier":       {"object"},
+		"num_founders":     {"number", "string"},
+		"last_layoff_date": {},
+		"investor_type":    {"array"},
+		"exited":           {"boolean"},
+	}
+	if !reflect.DeepEqual(schema, want) {
+		t.Errorf("observePage() = %v, want %v", schema, want)
+	}
+}
+
+func TestNewDriftReport(t *testing.T) {
+	previous := &collectionSchema{
+		RunId:    "20230214T101502Z-000001",
+		FieldIds: []string{"identifier", "num_founders", "rank_org_company", "ipo_status"},
+		Properties: propertySchema{
+			"identifier":       {"object"},
+			"num_founders":     {"number"},
+			"rank_org_company": {"number"},
+			"ipo_status":       {},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		fieldIds        []string
+		schema          propertySchema
+		previous        *collectionSchema
+		partial         bool
+		wantNew         []string
+		wantMissing     []string
+		wantTypeChanged []string
+	}{
+		{
+			name:     "First run",
+			fieldIds: previous.FieldIds,
+			schema:   previous.Properties,
+		},
+		{
+			name:     "Unchanged",
+			fieldIds: previous.FieldIds,
+			schema:   previous.Properties,
+			previous: previous,
+		},
+		{
+			name:        "Renamed property",
+			fieldIds:    previous.FieldIds,
+			schema:      propertySchema{"identifier": {"object"}, "num_founders": {"number"}, "rank_company": {"number"}, "ipo_status": {}},
+			previous:    previous,
+			wantNew:     []string{"rank_company"},
+			wantMissing: []string{"rank_org_company"},
+		},
+		{
+			name:            "Changed type",
+			fieldIds:        previous.FieldIds,
+			schema:          propertySchema{"identifier": {"object"}, "num_founders": {"string"}, "rank_org_company": {"number"}, "ipo_status": {"string"}},
+			previous:        previous,
+			wantTypeChanged: []string{"num_founders"},
+		},
+		{
+			name:     "Property not received by a partial run",
+			fieldIds: previous.FieldIds,
+			schema:   propertySchema{"identifier": {"object"}, "num_founders": {"number"}, "rank_company": {"number"}},
+			previous: previous,
+			partial:  true,
+			wantNew:  []string{"rank_company"},
+		},
+		{
+			name:     "Fields requested by one of both runs",
+			fieldIds: []string{"identifier", "num_founders", "ipo_status", "twitter"},
+			schema:   propertySchema{"identifier": {"object"}, "num_founders": {"number"}, "ipo_status": {}, "twitter": {"object"}},
+			previous: previous,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := newDriftReport("20230215T101502Z-000002", defaultCollectionId, tt.fieldIds, tt.schema, tt.previous, tt.partial)
+			fields := func(drifts []fieldDrift) []string {
+				names := []string{}
+				for _, d := range drifts {
+					names = append(names, d.Field)
+				}
+				return names
+			}
+			for _, check := range []struct {
+				kind      string
+				got, want []string
+			}{
+				{"new", fields(report.New), tt.wantNew},
+				{"missing", fields(report.Missing), tt.wantMissing},
+				{"type changed", fields(report.TypeChanged), tt.wantTypeChanged},
+			} {
+				if !reflect.DeepEqual(check.got, append([]string{}, check.want...)) {
+					t.Errorf("%s properties = %v, want %v", check.kind, check.got, check.want)
+				}
+			}
+			wantDrifted := len(tt.wantNew)+len(tt.wantMissing)+len(tt.wantTypeChanged) > 0
+			if report.Drifted != wantDrifted {
+				t.Errorf("Drifted = %v, want %v", report.Drifted, wantDrifted)
+			}
+		})
+	}
+}
+
+func TestDriftReportOfRuns(t *testing.T) {
+	// The reports, the output files and the schema state file are stored in
+	// the working directory.
+	chdirTemp(t)
+
+	// lastDriftReport, returns the drift report of the last run, i.e. the
+	// report which was not returned before.
+	reports := map[string]bool{}
+	lastDriftReport := func(t *testing.T) driftReport {
+		t.Helper()
+		files, _ := filepath.Glob("CBData_*.drift.json")
+		for _, file := range files {
+			if reports[file] {
+				continue
+			}
+			reports[file] = true
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			report := driftReport{}
+			if err := json.Unmarshal(data, &report); err != nil {
+				t.Fatal(err)
+			}
+			return report
+		}
+		t.Fatalf("no new drift report in %v", files)
+		return driftReport{}
+	}
+	// baselineRunId, returns the run recorded as the reference of the next
+	// drift report.
+	baselineRunId := func(t *testing.T) string {
+		t.Helper()
+		state, err := loadSchemaState(schemaStateFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return state.Collections[defaultCollectionId].RunId
+	}
+
+	server := cbfake.NewServer(cbfake.Config{Entities: 300})
+	defer server.Close()
+	if status := runFakeCLI(t, server, "extract", "--no-proxy"); status != 0 {
+		t.Fatalf("first extract exit status = %d, want 0", status)
+	}
+	first := lastDriftReport(t)
+	if first.PreviousRunId != "" || first.Drifted {
+		t.Errorf("drift report of the first run = %+v, want no previous run", first)
+	}
+
+	// Crunchbase renamed a property.
+	renamed := cbfake.NewServer(cbfake.Config{Entities: 300, RenamedProperties: map[string]string{"rank_org_company": "rank_company"}})
+	defer renamed.Close()
+	if status := runFakeCLI(t, renamed, "extract", "--no-proxy"); status != 0 {
+		t.Fatalf("second extract exit status = %d, want 0", status)
+	}
+	second := lastDriftReport(t)
+	if second.PreviousRunId != first.RunId {
+		t.Errorf("PreviousRunId = %s, want %s", second.PreviousRunId, first.RunId)
+	}
+	if !second.Drifted || len(second.New) != 1 || second.New[0].Field != "rank_company" || len(second.Missing) != 1 || second.Missing[0].Field != "rank_org_company" {
+		t.Errorf("drift report = %+v, want rank_company new and rank_org_company missing", second)
+	}
+
+	// The renamed property is kept in the extras of the documents.
+	fileData, err := os.ReadFile(outputFileName(second.RunId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	documents, err := unmarshalFile(fileData, entityCollections[defaultCollectionId].newDocument)
+	if err != nil {
+		t.Fatalf("unmarshalFile() error = %v", err)
+	}
+	if rank := documents[0].(*OrganizationDocument).Extras["rank_company"]; rank == nil {
+		t.Errorf("Extras = %v, want rank_company", documents[0].(*OrganizationDocument).Extras)
+	}
+	if baseline := baselineRunId(t); baseline != second.RunId {
+		t.Errorf("reference run = %s, want the full run %s", baseline, second.RunId)
+	}
+
+	// An incremental run does not receive rank_company for the changed
+	// entities; the property is not reported as missing, and the run does
+	// not replace the reference of the next report.
+	incremental := cbfake.NewServer(cbfake.Config{Entities: 300, RenamedProperties: map[string]string{"rank_org_company": "rank_org"}})
+	defer incremental.Close()
+	if status := runFakeCLI(t, incremental, "extract", "--no-proxy", "--incremental"); status != 0 {
+		t.Fatalf("incremental extract exit status = %d, want 0", status)
+	}
+	third := lastDriftReport(t)
+	if !third.Partial || third.PreviousRunId != second.RunId {
+		t.Errorf("drift report = %+v, want a partial report compared with %s", third, second.RunId)
+	}
+	if len(third.New) != 1 || third.New[0].Field != "rank_org" || len(third.Missing) != 0 {
+		t.Errorf("drift report = %+v, want rank_org new and no missing property", third)
+	}
+	if baseline := baselineRunId(t); baseline != second.RunId {
+		t.Errorf("reference run = %s, want the full run %s", baseline, second.RunId)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// extractionEstimate, expected size and duration of an extraction.
+type extractionEstimate struct {
+	// totalCount, number of entities matched by the query.
+	totalCount int
+	// pages, number of requests needed to retrieve all entities.
+	pages int
+	// minDuration and maxDuration, shortest and longest possible duration of
+	// the run, based on the random delays after the login and between pages.
+	minDuration time.Duration
+	maxDuration time.Duration
+}
+
+// expectedDuration, returns the mean duration of the run, since the random
+// delays are uniformly distributed.
+func (e extractionEstimate) expectedDuration() time.Duration {
+	return (e.minDuration + e.maxDuration) / 2
+}
+
+// estimateExtraction, estimates the number of pages and the duration of an
+// extraction of 'totalCount' entities with pages of 'pageSize' entities. The
+// duration only takes the delays of 'config' into account, i.e. one delay
+// after the login and one delay between two pages, the time spent on the
+// requests themselves is negligible in comparison.
+func estimateExtraction(totalCount, pageSize int, config userConfigurations) extractionEstimate {
+	pages := (totalCount + pageSize - 1) / pageSize
+	// There is no delay after the last page.
+	delays := pages - 1
+	if delays < 0 {
+		delays = 0
+	}
+
+	return extractionEstimate{
+		totalCount:  totalCount,
+		pages:       pages,
+		minDuration: time.Duration(config.minDelayLogin+delays*config.minDelayExtract) * time.Second,
+		maxDuration: time.Duration(config.maxDelayLogin+delays*config.maxDelayExtract) * time.Second,
+	}
+}
+
+// dryRunExtract, requests only the total count of entities matched by the
+// query and prints the number of pages and the expected duration of the
+// extraction, without downloading any page.
+func (app *application) dryRunExtract(ctx context.Context) error {
+	totalCount, err := app.getTotalCount(ctx, app.query)
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the total count of entries from an API request: %w", err)
+	}
+
+	estimate := estimateExtraction(totalCount, pageSize, app.userConfigurations)
+	fmt.Printf("Entities matched by the query: %d\n", estimate.totalCount)
+	fmt.Printf("Pages (%d entities per page): %d\n", pageSize, estimate.pages)
+	fmt.Printf("Expected duration: %s (between %s and %s)\n", estimate.expectedDuration(), estimate.minDuration, estimate.maxDuration)
+	if maxSize := app.userConfigurations.maxPartitionSize; maxSize > 0 && totalCount > maxSize && app.collection.partitionField != "" {
+		fmt.Printf("The query will be split into partitions of at most %d entities by %s, counting the partitions requires a few additional requests.\n", maxSize, app.collection.partitionField)
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimateExtraction(t *testing.T) {
+	config := userConfigurations{
+		minDelayLogin:   60,
+		maxDelayLogin:   120,
+		minDelayExtract: 120,
+		maxDelayExtract: 420,
+	}
+
+	tests := []struct {
+		name         string
+		totalCount   int
+		wantPages    int
+		wantMin      time.Duration
+		wantMax      time.Duration
+		wantExpected time.Duration
+	}{
+		{
+			name:         "No entities",
+			totalCount:   0,
+			wantPages:    0,
+			wantMin:      60 * time.Second,
+			wantMax:      120 * time.Second,
+			wantExpected: 90 * time.Second,
+		},
+		{
+			name:         "Single page",
+			totalCount:   1000,
+			wantPages:    1,
+			wantMin:      60 * time.Second,
+			wantMax:      120 * time.Second,
+			wantExpected: 90 * time.Second,
+		},
+		{
+			name:         "Partial last page",
+			totalCount:   2001,
+			wantPages:    3,
+			wantMin:      (60 + 2*120) * time.Second,
+			wantMax:      (120 + 2*420) * time.Second,
+			wantExpected: (90 + 2*270) * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := estimateExtraction(tt.totalCount, 1000, config)
+			if got.pages != tt.wantPages {
+				t.Errorf("estimateExtraction() pages = %d, want %d", got.pages, tt.wantPages)
+			}
+			if got.minDuration != tt.wantMin || got.maxDuration != tt.wantMax {
+				t.Errorf("estimateExtraction() duration = [%s, %s], want [%s, %s]", got.minDuration, got.maxDuration, tt.wantMin, tt.wantMax)
+			}
+			if got.expectedDuration() != tt.wantExpected {
+				t.Errorf("expectedDuration() = %s, want %s", got.expectedDuration(), tt.wantExpected)
+			}
+		})
+	}
+}
@@ -1,26 +1,91 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/cookiejar"
+
+	"github.com/erodrigufer/UVC_data_pipeline/internal/fixture"
 )
 
-// setupExtractCommands, configures http.Client and CB cookies for 'extract'
-// commands. If the 'noProxy' parameter is true, then no proxy connection is
-// established to extract data from CB.
-func (app *application) setupExtractCommands(noProxy bool) error {
-	if err := app.configureClient(noProxy); err != nil {
+// setupExtractCommands, configures the query, the sink, http.Client and CB
+// cookies for 'extract' commands, as defined by the options 'opts'. The
+// authentication is aborted if 'ctx' is cancelled.
+func (app *application) setupExtractCommands(ctx context.Context, opts extractOptions) error {
+	// Load the query and connect to the db before authenticating, so that an
+	// invalid query file or an unreachable db does not waste a login request.
+	if err := app.configureQuery(opts.queryFile, opts.profile); err != nil {
+		return fmt.Errorf("error while configuring the query: %w", err)
+	}
+	if opts.incremental {
+		if err := app.configureIncremental(extractStateFile); err != nil {
+			return fmt.Errorf("error while configuring the incremental extraction: %w", err)
+		}
+	}
+	backend, err := app.newSearchBackend(opts.backend)
+	if err != nil {
+		return err
+	}
+	if err := backend.supports(app.collection); err != nil {
+		return err
+	}
+	switch {
+	case opts.dryRun:
+		// A dry run does not store any page.
+	case opts.sink == sinkFile:
+	case opts.sink == sinkMongo:
+		if opts.remote == "" {
+			return fmt.Errorf("--remote flag missing: the mongo sink requires the IP address of the MongoDB instance")
+		}
+		if err := app.setupDBCommands(opts.remote); err != nil {
+			return fmt.Errorf("error while configuring the mongo sink: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown sink '%s', use '%s' or '%s'", opts.sink, sinkFile, sinkMongo)
+	}
+	// A replay reads the entities from a local file, it does not send any
+	// request to Crunchbase.
+	if opts.replay != "" {
+		app.replayFile = opts.replay
+		app.infoLog.Printf("Replaying the entities of the file %s instead of requesting Crunchbase.", opts.replay)
+		return nil
+	}
+	if opts.recordFixtures != "" && opts.replayFixtures != "" {
+		return fmt.Errorf("fixtures cannot be recorded and replayed at the same time")
+	}
+	// The requests of replayed fixtures must match the recorded requests.
+	if opts.recordFixtures != "" || opts.replayFixtures != "" {
+		app.fixCBCustomHeaders()
+	}
+	if opts.replayFixtures != "" {
+		replayer, err := fixture.OpenReplayer(opts.replayFixtures)
+		if err != nil {
+			return fmt.Errorf("error while loading the fixtures: %w", err)
+		}
+		app.transport = replayer
+	}
+	if err := app.configureClient(opts.noProxy); err != nil {
 		return fmt.Errorf("error while configuring the HTTP client: %w", err)
 	}
-	if err := app.handleAuthentication(); err != nil {
-		return fmt.Errorf("authentication with CB API failed: %w", err)
+	if opts.recordFixtures != "" {
+		recorder, err := fixture.NewRecorder(opts.recordFixtures, app.client.Transport)
+		if err != nil {
+			return fmt.Errorf("error while configuring the recording of fixtures: %w", err)
+		}
+		app.client.Transport = recorder
+		app.infoLog.Printf("Recording the requests sent to Crunchbase in the fixture file %s.", opts.recordFixtures)
 	}
+	if err := backend.authenticate(ctx); err != nil {
+		return fmt.Errorf("authentication with the '%s' backend failed: %w", opts.backend, err)
+	}
+	app.backend = backend
 	return nil
 }
 
 // configureClient, configures the http.Client used by the application, so
-// that if a flag was set or not, it proxies its traffic through a proxy.
+// that if a flag was set or not, it proxies its traffic through a proxy. An
+// injected app.transport replaces the proxy.
 func (app *application) configureClient(noProxy bool) error {
 	// Create an HTTP Client to use in multiple API calls, and to handle the
 	// cookie jar.
@@ -33,6 +98,12 @@ func (app *application) configureClient(noProxy bool) error {
 	// its value to the app's cookiejar.
 	app.client.Jar = jar
 
+	if app.transport != nil {
+		app.infoLog.Print("Sending the requests through an injected transport, e.g. replayed fixtures.")
+		app.client.Transport = app.transport
+		return nil
+	}
+
 	// If noProxy is false (it was not set as a flag) then a proxy should be
 	// configured.
 	if !noProxy { // Configure a proxy.
@@ -50,40 +121,38 @@ func (app *application) configureClient(noProxy bool) error {
 	return nil
 }
 
-// handleAuthentication, handles getting session cookies by sending a login
-// request to the Crunchbase API.
-// If it returns an error, exit the application, fatal error.
-func (app *application) handleAuthentication() error {
-	// Parameter for app.login = false, so that login() does not store the
-	// cookies on a persistent file.
-	if err := app.login(false); err != nil {
-		// If login fails, exit program, fatal error.
-		return fmt.Errorf("unable to login (authenticate) into Crunchbase API: %w", err)
-	}
-	app.infoLog.Print("Received new cookies from Crunchbase API.")
-	return nil
-}
-
-// handleAuthenticationPersistentCookies, handles getting session cookies by
-// sending a login request to the Crunchbase API. If first tries to load an
-// already present persistent file with cookies. It also stores new cookies,
-// if it needs to ask for new cookies to the CB API.
-// If it returns an error, exit the application, fatal error.
-func (app *application) handleAuthenticationPersistentCookies() error {
-	err := app.loadCookies()
-	if err != nil {
-		err = fmt.Errorf("unable to load cookies from file: %w", err)
-		// Print an error here, just in case there is no cookies file.
-		app.errorLog.Print(err)
-		// If loadCookies fails, try to get new cookies through login().
-		// loadCookies() can fail, if for example, there is no file in
-		// the local repository that contains the current cookies.
-		err = app.login(true)
+// configureQuery, loads the query sent to Crunchbase either from 'queryFile'
+// or from the profile named 'profile'. If both are empty strings, the default
+// query is used.
+func (app *application) configureQuery(queryFile, profile string) error {
+	switch {
+	case queryFile != "" && profile != "":
+		return fmt.Errorf("a query file and a profile were both given, use only one of them")
+	case queryFile != "":
+		query, err := loadQuery(queryFile)
+		if err != nil {
+			return err
+		}
+		app.query = query
+		app.infoLog.Printf("Using query from file %s.", queryFile)
+	case profile != "":
+		query, err := loadProfile(profilesDir, profile)
 		if err != nil {
-			// If login fails, exit program, fatal error.
-			return fmt.Errorf("unable to login (authenticate) into Crunchbase API: %w", err)
+			return err
 		}
-		app.infoLog.Print("Received new cookies from Crunchbase API.")
+		app.query = query
+		app.infoLog.Printf("Using query profile '%s' (%s).", profile, query.Description)
+	default:
+		app.query = defaultQuery()
+		app.infoLog.Print("No query file or profile provided, using the default query.")
 	}
+
+	collection, err := lookupCollection(app.query.CollectionId)
+	if err != nil {
+		return err
+	}
+	app.collection = collection
+	app.infoLog.Printf("Extracting the Crunchbase collection '%s'.", collection.id)
+
 	return nil
 }
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erodrigufer/UVC_data_pipeline/internal/cbfake"
+	"github.com/urfave/cli/v2"
+)
+
+// chdirTemp, changes the working directory to a temporary directory for the
+// duration of the test 't', e.g. for the files which are stored in the
+// working directory.
+func chdirTemp(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Errorf("unable to restore the working directory: %v", err)
+		}
+	})
+}
+
+// runFakeCLI, runs the command line 'args' of cbExtractor against the
+// fake Crunchbase 'server' and returns the exit status.
+func runFakeCLI(t *testing.T, server *cbfake.Server, args ...string) int {
+	t.Helper()
+	app := newFixtureTestApplication()
+	app.cbBaseURL = server.URL
+	app.cbUsername = "analyst@uvc.com"
+	app.cbPassword = "secret"
+	app.setupCLI()
+	// Do not exit the test binary with the exit status.
+	app.tui.ExitErrHandler = func(cCtx *cli.Context, err error) {}
+
+	err := app.tui.RunContext(context.Background(), append([]string{"cbExtractor"}, args...))
+	if err == nil {
+		return 0
+	}
+	var exitErr cli.ExitCoder
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("cbExtractor %v returned an error without exit status: %v", args, err)
+	}
+	return exitErr.ExitCode()
+}
+
+// extractedDocuments, returns the number of documents in the output file of
+// the extraction in the working directory, or in its partial output file if
+// the run is not complete.
+func extractedDocuments(t *testing.T) int {
+	t.Helper()
+	files, _ := filepath.Glob("CBData_*.ndjson*")
+	outputs := []string{}
+	for _, file := range files {
+		// Skip the quarantine file and the raw archive of the run.
+		if strings.HasSuffix(strings.TrimSuffix(file, partialSuffix), ".ndjson") && !strings.HasSuffix(file, ".quarantine.ndjson") {
+			outputs = append(outputs, file)
+		}
+	}
+	if len(outputs) != 1 {
+		t.Fatalf("found output files %v, want exactly one", outputs)
+	}
+	fileData, err := os.ReadFile(outputs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	documents, err := unmarshalFile(fileData, entityCollections[defaultCollectionId].newDocument)
+	if err != nil {
+		t.Fatalf("unmarshalFile() error = %v", err)
+	}
+	return len(documents)
+}
+
+// quarantinedEntities, returns the number of entities in the quarantine file
+// of the extraction in the working directory, 0 if there is none.
+func quarantinedEntities(t *testing.T) int {
+	t.Helper()
+	files, _ := filepath.Glob("CBData_*.quarantine.ndjson")
+	if len(files) == 0 {
+		return 0
+	}
+	fileData, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	entities, err := unmarshalFile(fileData, func() document { return &quarantinedEntity{} })
+	if err != nil {
+		t.Fatalf("unmarshalFile() error = %v", err)
+	}
+	return len(entities)
+}
+
+// runReport, returns the reconciliation report of the extraction in the
+// working directory.
+func runReport(t *testing.T) reconciliationReport {
+	t.Helper()
+	files, _ := filepath.Glob("CBData_*.report.json")
+	if len(files) != 1 {
+		t.Fatalf("found reports %v, want exactly one", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := reconciliationReport{}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+// archivedEntities, returns the number of entities in the raw archive of
+// the extraction in the working directory.
+func archivedEntities(t *testing.T) int {
+	t.Helper()
+	files, _ := filepath.Glob("CBData_*.raw.ndjson.gz")
+	if len(files) != 1 {
+		t.Fatalf("found raw archives %v, want exactly one", files)
+	}
+	return len(archivedUUIDs(t, files[0]))
+}
+
+func TestExtractAgainstFakeCrunchbase(t *testing.T) {
+	tests := []struct {
+		name   string
+		config cbfake.Config
+		args   []string
+		// resumeArgs, arguments of a second run after the first one, if it
+		// is not nil.
+		resumeArgs     []string
+		wantStatus     int
+		wantDocuments  int
+		wantCheckpoint bool
+		// wantLogins, number of logins of all runs, if it is not 0.
+		wantLogins int
+		// wantQuarantined, number of entities in the quarantine file.
+		wantQuarantined int
+		// wantArchived, number of entities in the raw archive, if it is
+		// not the number of documents and quarantined entities.
+		wantArchived int
+	}{
+		{
+			name:          "Complete extraction",
+			config:        cbfake.Config{Entities: 2500, Email: "analyst@uvc.com", Password: "secret"},
+			args:          []string{"extract", "--no-proxy"},
+			wantDocuments: 2500,
+		},
+		{
+			// Malformed organizations do not stop the run, they are
+			// quarantined and the run reconciles.
+			name:            "Malformed entities are quarantined",
+			config:          cbfake.Config{Entities: 2500, MalformedEvery: 100},
+			args:            []string{"extract", "--no-proxy"},
+			wantDocuments:   2475,
+			wantQuarantined: 25,
+		},
+		{
+			// The paging skipped entities, the incomplete partition is
+			// extracted again from its first page and the run reconciles.
+			name:          "Incomplete partition is retried",
+			config:        cbfake.Config{Entities: 2500, SkipEvery: 100},
+			args:          []string{"extract", "--no-proxy"},
+			wantDocuments: 2500,
+			// Both passes through the partition are archived.
+			wantArchived: 2475 + 2500,
+		},
+		{
+			name:          "Throttled requests are retried",
+			config:        cbfake.Config{Entities: 2500, ThrottleEvery: 2},
+			args:          []string{"extract", "--no-proxy", "--max-retries", "2"},
+			wantDocuments: 2500,
+		},
+		{
+			name:       "Throttled without retries",
+			config:     cbfake.Config{Entities: 2500, ThrottleEvery: 1},
+			args:       []string{"extract", "--no-proxy", "--max-retries", "1"},
+			wantStatus: 1,
+		},
+		{
+			name:       "Wrong credentials",
+			config:     cbfake.Config{Entities: 10, Email: "analyst@uvc.com", Password: "other"},
+			args:       []string{"extract", "--no-proxy"},
+			wantStatus: 1,
+		},
+		{
+			name:          "Expired session is renewed",
+			config:        cbfake.Config{Entities: 2500, SessionRequests: 2},
+			args:          []string{"extract", "--no-proxy"},
+			wantDocuments: 2500,
+			wantLogins:    2,
+		},
+		{
+			// The run stops as incomplete, the partial results and the
+			// checkpoint are kept for --resume.
+			name:           "Rejected renewal keeps the partial results",
+			config:         cbfake.Config{Entities: 2500, SessionRequests: 2, MaxLogins: 1},
+			args:           []string{"extract", "--no-proxy"},
+			wantStatus:     3,
+			wantDocuments:  1000,
+			wantCheckpoint: true,
+		},
+		{
+			name:           "Throttled page keeps the partial results",
+			config:         cbfake.Config{Entities: 2500, ThrottleEvery: 3},
+			args:           []string{"extract", "--no-proxy", "--max-retries", "0"},
+			wantStatus:     3,
+			wantDocuments:  1000,
+			wantCheckpoint: true,
+		},
+		{
+			name:          "Resume reuses the stored session",
+			config:        cbfake.Config{Entities: 2500, ThrottleEvery: 3},
+			args:          []string{"extract", "--no-proxy", "--max-retries", "0"},
+			resumeArgs:    []string{"extract", "--no-proxy", "--max-retries", "0", "--resume"},
+			wantDocuments: 2500,
+			wantLogins:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The checkpoint, the output file and the report are stored in
+			// the working directory.
+			chdirTemp(t)
+
+			server := cbfake.NewServer(tt.config)
+			defer server.Close()
+
+			status := runFakeCLI(t, server, tt.args...)
+			if tt.resumeArgs != nil {
+				status = runFakeCLI(t, server, tt.resumeArgs...)
+			}
+			if status != tt.wantStatus {
+				t.Fatalf("exit status = %d, want %d", status, tt.wantStatus)
+			}
+			if tt.wantDocuments > 0 {
+				if got := extractedDocuments(t); got != tt.wantDocuments {
+					t.Errorf("output contains %d documents, want %d", got, tt.wantDocuments)
+				}
+			}
+			if _, err := os.Stat(checkpointFile); (err == nil) != tt.wantCheckpoint {
+				t.Errorf("checkpoint exists = %t, want %t", err == nil, tt.wantCheckpoint)
+			}
+			// A run which stopped early writes a report marked as
+			// incomplete.
+			if tt.wantDocuments > 0 {
+				report := runReport(t)
+				if report.Complete == tt.wantCheckpoint || report.Reconciled == tt.wantCheckpoint {
+					t.Errorf("report complete = %t, reconciled = %t, want %t", report.Complete, report.Reconciled, !tt.wantCheckpoint)
+				}
+				// The fake never returns an entity twice, a retried
+				// partition counts its entities again.
+				if wantFetched := tt.wantDocuments + tt.wantQuarantined; !tt.wantCheckpoint && (report.Fetched != wantFetched || report.Duplicates != 0) {
+					t.Errorf("report fetched = %d, duplicates = %d, want %d and 0", report.Fetched, report.Duplicates, wantFetched)
+				}
+			}
+			// Every received entity is archived, including the quarantined
+			// ones.
+			if tt.wantDocuments > 0 {
+				wantArchived := tt.wantArchived
+				if wantArchived == 0 {
+					wantArchived = tt.wantDocuments + tt.wantQuarantined
+				}
+				if got := archivedEntities(t); got != wantArchived {
+					t.Errorf("raw archive contains %d entities, want %d", got, wantArchived)
+				}
+			}
+			if got := quarantinedEntities(t); got != tt.wantQuarantined {
+				t.Errorf("quarantine file contains %d entities, want %d", got, tt.wantQuarantined)
+			}
+			if tt.wantLogins > 0 && server.Logins() != tt.wantLogins {
+				t.Errorf("logins = %d, want %d", server.Logins(), tt.wantLogins)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// knownProperties, returns the names of the Crunchbase properties modelled by
+// the properties type 'properties' (e.g. Properties{}), i.e. the names of the
+// JSON tags of its fields.
+func knownProperties(properties interface{}) map[string]bool {
+	known := map[string]bool{}
+	t := reflect.TypeOf(properties)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		known[name] = true
+	}
+	return known
+}
+
+// unknownProperties, returns the properties of the raw entity 'raw' which are
+// not modelled by the properties type 'properties', e.g. properties added by
+// Crunchbase after the type was written. The values are kept as decoded from
+// JSON. It returns nil if all properties are known.
+func unknownProperties(raw json.RawMessage, properties interface{}) (map[string]interface{}, error) {
+	entity := struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}{}
+	if err := json.Unmarshal(raw, &entity); err != nil {
+		return nil, fmt.Errorf("unable to decode properties of entity: %w", err)
+	}
+
+	known := knownProperties(properties)
+	var extras map[string]interface{}
+	for name, value := range entity.Properties {
+		if known[name] {
+			continue
+		}
+		var extra interface{}
+		if err := json.Unmarshal(value, &extra); err != nil {
+			return nil, fmt.Errorf("unable to decode unknown property %s: %w", name, err)
+		}
+		if extras == nil {
+			extras = map[string]interface{}{}
+		}
+		extras[name] = extra
+	}
+	return extras, nil
+}
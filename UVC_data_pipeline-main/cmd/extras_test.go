@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnknownProperties(t *testing.T) {
+	tests := []struct {
+		name    string
+		entity  string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:   "Known properties",
+			entity: `{"uuid":"1a","properties":{"identifier":{"value":"Blub.ai"},"num_founders":2}}`,
+		},
+		{
+			name:   "Without properties",
+			entity: `{"uuid":"1a"}`,
+		},
+		{
+			name:   "Unknown properties",
+			entity: `{"uuid":"1a","properties":{"num_founders":2,"rank_company":122925,"esg_scores":{"value":"A"},"exited":false}}`,
+			want:   map[string]interface{}{"rank_company": 122925.0, "esg_scores": map[string]interface{}{"value": "A"}, "exited": false},
+		},
+		{
+			name:    "Invalid entity",
+			entity:  `{"uuid":"1a","properties":[]}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := unknownProperties([]byte(tt.entity), Properties{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unknownProperties() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unknownProperties() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeExtras(t *testing.T) {
+	payload := `{"entities":[{"uuid":"1a","properties":{"identifier":{"value":"Blub.ai"},"esg_score":"A"}}]}`
+	tests := []struct {
+		collectionId string
+		wantExtras   func(d document) map[string]interface{}
+	}{
+		{"organization.companies", func(d document) map[string]interface{} { return d.(OrganizationDocument).Extras }},
+		{"people", func(d document) map[string]interface{} { return d.(PersonDocument).Extras }},
+		{"funding_rounds", func(d document) map[string]interface{} { return d.(FundingRoundDocument).Extras }},
+		{"principal.investors", func(d document) map[string]interface{} { return d.(InvestorDocument).Extras }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.collectionId, func(t *testing.T) {
+			documents, err := entityCollections[tt.collectionId].decode([]byte(payload))
+			if err != nil {
+				t.Fatalf("decode() error = %v", err)
+			}
+			want := map[string]interface{}{"esg_score": "A"}
+			if got := tt.wantExtras(documents[0]); !reflect.DeepEqual(got, want) {
+				t.Errorf("Extras = %v, want %v", got, want)
+			}
+		})
+	}
+}
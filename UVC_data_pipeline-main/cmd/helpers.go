@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -10,6 +12,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -56,6 +59,11 @@ func (app *application) setupApplication() error {
 	// Delays after requesting login credentials.
 	app.userConfigurations.maxDelayLogin = 120
 	app.userConfigurations.minDelayLogin = 60
+	// Retries of failed requests to the API.
+	app.userConfigurations.maxRetries = defaultMaxRetries
+	app.userConfigurations.baseBackoff = defaultBaseBackoff
+	app.userConfigurations.maxBackoff = defaultMaxBackoff
+	app.userConfigurations.maxPartitionSize = defaultMaxPartitionSize
 
 	return nil
 }
@@ -81,87 +89,95 @@ func (app *application) loadEnv() error {
 		return fmt.Errorf("name of collection for CB raw data in .env file is empty or not defined")
 	}
 
-	// Fetch the username and password of CB account.
+	// Fetch the username and password of CB account, or the key of the CB
+	// API. One of both credentials is required, depending on the backend.
 	app.cbUsername = os.Getenv("CB_USERNAME")
-	if app.cbUsername == "" {
-		return fmt.Errorf("username of CB account is empty or not defined.")
-	}
 	app.cbPassword = os.Getenv("CB_PASSWORD")
-	if app.cbPassword == "" {
-		return fmt.Errorf("password of CB account is empty or not defined.")
+	app.cbAPIKey = os.Getenv("CB_API_KEY")
+	// Optional base URL which replaces https://www.crunchbase.com, e.g. a
+	// local fake of Crunchbase.
+	app.cbBaseURL = os.Getenv("CB_BASE_URL")
+	if app.cbAPIKey == "" {
+		if app.cbUsername == "" {
+			return fmt.Errorf("username of CB account is empty or not defined.")
+		}
+		if app.cbPassword == "" {
+			return fmt.Errorf("password of CB account is empty or not defined.")
+		}
 	}
 
 	return nil
 }
 
-// loadCookies, loads cookies from external file into app.client's cookiejar.
-// trunk-ignore(golangci-lint/unused)
-func (app *application) loadCookies() error {
-	// Open the file with the previously stored cookies.
-	cookiesFile, err := os.Open("./cookies.json")
+// crunchbaseWebURL, base URL of the Crunchbase web API, which is replaced by
+// app.cbBaseURL if it is set.
+const crunchbaseWebURL = "https://www.crunchbase.com"
+
+// webURL, parses 'rawURL', a URL of the Crunchbase web API, and moves it to
+// the base URL app.cbBaseURL if it is set, e.g.
+// https://www.crunchbase.com/v4/cb/sessions becomes
+// http://127.0.0.1:8080/v4/cb/sessions.
+func (app *application) webURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
 	if err != nil {
-		return fmt.Errorf("unable to open cookies.json file: %w", err)
+		return nil, fmt.Errorf("invalid Crunchbase URL %s: %w", rawURL, err)
 	}
-	defer cookiesFile.Close()
-
-	// Store cookies from the file in a slice of *http.Cookie.
-	var cookies []*http.Cookie
-
-	// Read all the data out of the file, output is []byte.
-	fileByteValue, err := io.ReadAll(cookiesFile)
+	if app.cbBaseURL == "" {
+		return u, nil
+	}
+	base, err := url.Parse(app.cbBaseURL)
 	if err != nil {
-		return fmt.Errorf("unable to read cookies file: %w", err)
+		return nil, fmt.Errorf("invalid base URL %s: %w", app.cbBaseURL, err)
 	}
+	u.Scheme = base.Scheme
+	u.Host = base.Host
+	u.Path = strings.TrimSuffix(base.Path, "/") + u.Path
+	return u, nil
+}
 
-	// Decode json from data the cookies file into []*http.Cookie.
-	err = json.Unmarshal(fileByteValue, &cookies)
+// loadCookies, loads cookies from external file into app.client's cookiejar.
+// It fails if the file does not exist or if the stored session expired.
+func (app *application) loadCookies() error {
+	cookies, storedAt, err := readStoredCookies(cookiesFile)
 	if err != nil {
-		return fmt.Errorf("unable to unmarshal cookies json file: %w", err)
+		return err
+	}
+	if cookiesExpired(cookies, storedAt, time.Now()) {
+		return fmt.Errorf("the session stored in %s expired", cookiesFile)
 	}
 
 	// Transform Crunchbase's API URL into *url.URL.
-	urlObj, err := url.Parse("https://www.crunchbase.com/v4/")
+	urlObj, err := app.webURL(crunchbaseWebURL + "/v4/")
 	if err != nil {
 		return fmt.Errorf("unable to parse crunchbase.com string into url structure: %w", err)
 	}
 	// Add retrieved cookies to cookie jar from app.client object.
 	app.client.Jar.SetCookies(urlObj, cookies)
+	app.infoLog.Printf("Reusing the session stored in %s.", cookiesFile)
 
 	return nil
 
 }
 
-// unmarshalFile, decodes the JSON data from a file (par. fileData) and returns
-// the decoded data in an OrganizationDocument slice.
-func unmarshalFile(fileData []byte) ([]OrganizationDocument, error) {
-	organizationDocumentSlice := []OrganizationDocument{}
-
-	if err := json.Unmarshal(fileData, &organizationDocumentSlice); err != nil {
-		err = fmt.Errorf("unable to decode json data into organizationDocumentSlice: %w", err)
-		return nil, err
-	}
-
-	return organizationDocumentSlice, nil
-}
-
 // decodeBody, decodes the body received from the Crunchbase API and returns
-// a slice with all parsed entities from the payload.
+// a slice with all parsed entities from the payload. Unlike the extraction,
+// which quarantines entities which cannot be parsed, it fails if any entity of
+// the payload cannot be parsed.
 func decodeBody(payload []byte, organizationDocumentSlice *[]OrganizationDocument) error {
-	dataContainer := DataContainer{}
-	if err := json.Unmarshal(payload, &dataContainer); err != nil {
+	documents, err := decodeOrganizations(payload)
+	if err != nil {
 		return fmt.Errorf("unable to decode payload []byte into dataContainer type: %w", err)
 	}
 
-	organizationDocument := new(OrganizationDocument)
-
-	for _, entity := range dataContainer.Entities {
-		// Parse data from one entity into an organizationDocument object.
-		if err := organizationDocument.parseRawData(entity); err != nil {
-			return fmt.Errorf("unable to parse CB data into organizationDocument type: %w", err)
+	for _, d := range documents {
+		switch d := d.(type) {
+		case OrganizationDocument:
+			// Append the parsed organizationDocument into a slice with all
+			// entities contained in the payload from the Crunchbase API.
+			*organizationDocumentSlice = append(*organizationDocumentSlice, d)
+		case quarantinedEntity:
+			return errors.New(d.Reason)
 		}
-		// Append the parsed organizationDocument into a slice with all entities
-		// contained in the payload from the Crunchbase API.
-		*organizationDocumentSlice = append(*organizationDocumentSlice, *organizationDocument)
 	}
 
 	return nil
@@ -175,13 +191,15 @@ func (document *OrganizationDocument) parseRawData(entity Entity) error {
 	document.Uuid = entity.Uuid
 	document.Timestamp = time.Now()
 	document.EntityDefId = entity.Properties.Identifier["entity_def_id"]
+	document.Source = crunchbaseSource
 	document.OrganizationName = entity.Properties.Identifier["value"]
+	document.Permalink = entity.Properties.Identifier["permalink"]
 	document.Description = entity.Properties.Description
 	document.ShortDescription = entity.Properties.ShortDescription
 	document.FundingStage = entity.Properties.FundingStage
-	FoundedOnDate, err := time.Parse("2006-01-02", entity.Properties.FoundedOn["value"])
+	FoundedOnDate, err := parseCBDate(entity.Properties.FoundedOn["value"], entity.Properties.FoundedOn["precision"])
 	if err != nil {
-		return fmt.Errorf("unable to parse FoundedOn field string into time.Time value: %w", err)
+		return fmt.Errorf("unable to parse FoundedOn field string into Date value: %w", err)
 	}
 	document.FoundedOn = FoundedOnDate
 	document.OperatingStatus = entity.Properties.OperatingStatus
@@ -189,12 +207,8 @@ func (document *OrganizationDocument) parseRawData(entity Entity) error {
 	document.Linkedin = entity.Properties.Linkedin["value"]
 	document.Facebook = entity.Properties.Facebook["value"]
 	document.Industries = entity.Properties.Categories
-	document.City = FilterLocation(entity.Properties.Locations, func(location Location) bool {
-		return location.LocationType == "city"
-	})[0].Name
-	document.Country = FilterLocation(entity.Properties.Locations, func(location Location) bool {
-		return location.LocationType == "country"
-	})[0].Name
+	document.City = locationName(entity.Properties.Locations, "city")
+	document.Country = locationName(entity.Properties.Locations, "country")
 	document.ContactEmail = entity.Properties.ContactEmail
 	document.NumFounders = entity.Properties.NumFounders
 	document.FounderIdentifiers = entity.Properties.FounderIdentifiers
@@ -212,36 +226,72 @@ func (document *OrganizationDocument) parseRawData(entity Entity) error {
 	document.LastEquityFundingType = entity.Properties.LastEquityFundingType
 	document.LastFundingType = entity.Properties.LastFundingType
 	document.LastFundingTotal = entity.Properties.LastFundingTotal.ValueUSD
-	LastFundingAtDate, err := time.Parse("2006-01-02", entity.Properties.LastFundingAt)
+	LastFundingAtDate, err := parseCBDate(entity.Properties.LastFundingAt, "")
 	if err != nil {
-		return fmt.Errorf("unable to parse LastFundingAtDate field string into time.Time value: %w", err)
+		return fmt.Errorf("unable to parse LastFundingAtDate field string into Date value: %w", err)
 	}
 	document.LastFundingAt = LastFundingAtDate
 	document.InvestorIdentifiers = entity.Properties.InvestorIdentifiers
-	switch entity.Properties.NumEmployeesEnum {
-	case "c_00001_00010":
-		document.NumEmployeesEnum = "1-10"
-	case "c_00011_00050":
-		document.NumEmployeesEnum = "11-50"
-	case "c_00051_00100":
-		document.NumEmployeesEnum = "51-100"
-	case "c_00101_00250":
-		document.NumEmployeesEnum = "101-250"
-	case "c_00251_00500":
-		document.NumEmployeesEnum = "251-500"
-	case "c_00501_01000":
-		document.NumEmployeesEnum = "501-1000"
-	case "c_01001_05000":
-		document.NumEmployeesEnum = "1001-5000"
-	case "c_05001_10000":
-		document.NumEmployeesEnum = "5001-10000"
-	case "c_10001_max":
-		document.NumEmployeesEnum = "10001+"
+	document.NumEmployeesEnum = employeesRange(entity.Properties.NumEmployeesEnum)
+	document.IpoStatus = entity.Properties.IpoStatus
+	document.DiversitySpotlights = entity.Properties.DiversitySpotlights
+	document.InvestorType = entity.Properties.InvestorType
+	document.InvestorStage = entity.Properties.InvestorStage
+	document.NumLeadInvestors = entity.Properties.NumLeadInvestors
+	document.SemRush.VisitsLast6MonthsAvg = entity.Properties.VisitsLast6MonthsAvg
+	document.SemRush.VisitsMomPct = entity.Properties.VisitsMomPct
+	document.SemRush.VisitDurationMomPct = entity.Properties.VisitDurationMomPct
+	document.SemRush.VisitPageviewsMomPct = entity.Properties.VisitPageviewsMomPct
+	document.SemRush.BounceRateMomPct = entity.Properties.BounceRateMomPct
+	document.SemRush.GlobalRank = entity.Properties.GlobalRank
+	document.SemRush.GlobalRankMom = entity.Properties.GlobalRankMom
+	document.SemRush.GlobalRankMomPct = entity.Properties.GlobalRankMomPct
+	document.Apptopia.NumApps = entity.Properties.NumApps
+	document.Apptopia.NumDownloads = entity.Properties.NumAppDownloads
+	document.HubTags = entity.Properties.HubTags
+	document.Twitter = entity.Properties.Twitter["value"]
+	LastKeyEmployeeChangeDate, err := parseCBDate(entity.Properties.LastKeyEmployeeChange, "")
+	if err != nil {
+		return fmt.Errorf("unable to parse LastKeyEmployeeChange field string into Date value: %w", err)
 	}
+	document.LastKeyEmployeeChange = LastKeyEmployeeChangeDate
+	LastLayoffDate, err := parseCBDate(entity.Properties.LastLayoffDate, "")
+	if err != nil {
+		return fmt.Errorf("unable to parse LastLayoffDate field string into Date value: %w", err)
+	}
+	document.LastLayoffDate = LastLayoffDate
+	document.NumEventAppearances = entity.Properties.NumEventAppearances
+	document.RankOrgCompany = entity.Properties.RankOrgCompany
+	document.NumContacts = entity.Properties.NumContacts
+	document.NumPrivateContacts = entity.Properties.NumPrivateContacts
+	document.NumOfProducts = entity.Properties.NumOfProducts
+	document.PrivateTags = entity.Properties.PrivateTags
+	document.NumPrivateNotes = entity.Properties.NumPrivateNotes
 
 	return nil
 }
 
+// employeesRanges, ranges of employees of the num_employees_enum values of
+// the Crunchbase API.
+var employeesRanges = map[string]string{
+	"c_00001_00010": "1-10",
+	"c_00011_00050": "11-50",
+	"c_00051_00100": "51-100",
+	"c_00101_00250": "101-250",
+	"c_00251_00500": "251-500",
+	"c_00501_01000": "501-1000",
+	"c_01001_05000": "1001-5000",
+	"c_05001_10000": "5001-10000",
+	"c_10001_max":   "10001+",
+}
+
+// employeesRange, returns the range of employees of a num_employees_enum
+// value, e.g. '11-50' for 'c_00011_00050', or an empty string if the value
+// is unknown.
+func employeesRange(enum string) string {
+	return employeesRanges[enum]
+}
+
 func FilterLocation(vs []Location, f func(Location) bool) []Location {
 	filtered := make([]Location, 0)
 	for _, v := range vs {
@@ -263,9 +313,9 @@ func (app *application) storeCookies(res *http.Response) error {
 	}
 	app.infoLog.Println("Storing cookies in external file...")
 	// Write JSON data into external file, no permissions at all for others.
-	err = ioutil.WriteFile("./cookies.json", data, 0640)
+	err = ioutil.WriteFile(cookiesFile, data, 0640)
 	if err != nil {
-		return fmt.Errorf("unable to write data into cookies.json file: %w", err)
+		return fmt.Errorf("unable to write data into %s file: %w", cookiesFile, err)
 	}
 
 	return nil
@@ -299,6 +349,7 @@ func (app *application) initCBCustomHeaders() error {
 	}
 	// Based on cbCustomConfigHeaders type, it randomly selects HTTP headers
 	// further used by the app for all CB API requests.
+	app.cbCustomConfigHeaders = cbCustomConfigHeaders
 	app.randomizeCBCustomHeaders(cbCustomConfigHeaders)
 
 	return nil
@@ -312,6 +363,23 @@ func (app *application) randomizeCBCustomHeaders(cbCustomConfigHeaders CBCustomC
 	app.cbCustomHeader.UserAgent = cbCustomConfigHeaders.UserAgents[app.seededRand.Intn(len(cbCustomConfigHeaders.UserAgents))]
 }
 
+// fixCBCustomHeaders, picks the first referer, accept-language and user-agent
+// HTTP headers parsed from the external file instead of random ones, so that
+// the requests of recorded fixtures (e.g. the organizations search, which is
+// sent to the referer URL) are the same when the fixtures are replayed.
+func (app *application) fixCBCustomHeaders() {
+	config := app.cbCustomConfigHeaders
+	if len(config.UrlReferers) > 0 {
+		app.cbCustomHeader.UrlReferer = config.UrlReferers[0]
+	}
+	if len(config.AcceptLanguages) > 0 {
+		app.cbCustomHeader.AcceptLanguage = config.AcceptLanguages[0]
+	}
+	if len(config.UserAgents) > 0 {
+		app.cbCustomHeader.UserAgent = config.UserAgents[0]
+	}
+}
+
 // calculateRandomDelay, returns a random delay value in seconds. It uses a
 // pre-seeded random number generator (app.seededRand) to generate different
 // random numbers in each program execution.
@@ -329,14 +397,16 @@ func (app *application) calculateRandomDelay(minDelay, maxDelay int) (int, error
 	return app.seededRand.Intn(maxDelay-minDelay) + minDelay, nil
 }
 
-// createTempFile, creates a temp file with a unique and random name each time
-// the function is called.
-func createTempFile() (*os.File, error) {
-	// Create temp file in current directory.
-	f, err := os.CreateTemp(".", "CBData_*.json")
-	if err != nil {
-		err = fmt.Errorf("unable to create a temporary file: %w", err)
-		return nil, err
+// sleepContext, pauses the current goroutine for the duration 'd' or until the
+// context 'ctx' is cancelled. It returns the context's error if the sleep was
+// interrupted, e.g. by Ctrl+C.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
-	return f, nil
 }
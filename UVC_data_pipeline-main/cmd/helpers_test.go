@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"math/rand"
 	"reflect"
@@ -116,16 +117,36 @@ func TestParseRawData(t *testing.T) {
 				NumVisitPerPageviews:  1.4,
 				BounceRate:            1.2,
 				VisitDuration:         420,
+				IpoStatus:             "private",
+				DiversitySpotlights:   []Category{{Uuid: "3a", EntityDefId: "diversity_spotlight", Name: "Women Founded"}},
+				InvestorType:          []string{"accelerator"},
+				InvestorStage:         []string{"seed"},
+				NumLeadInvestors:      1,
+				VisitsLast6MonthsAvg:  58,
+				VisitsMomPct:          -0.5,
+				GlobalRank:            7670511,
+				GlobalRankMom:         7092769,
+				GlobalRankMomPct:      12.25,
+				NumApps:               2,
+				NumAppDownloads:       1500,
+				HubTags:               []Category{{Uuid: "4a", EntityDefId: "hub", Name: "Swiss AI Companies"}},
+				Twitter:               map[string]string{"value": "https://twitter.com/blub"},
+				LastKeyEmployeeChange: "2022-06-01",
+				NumEventAppearances:   3,
+				RankOrgCompany:        122925,
+				NumContacts:           5,
+				NumOfProducts:         6,
 			}},
 			want: &OrganizationDocument{
 				Uuid:                  "1",
 				Timestamp:             time.Now(),
 				EntityDefId:           "organization",
+				Source:                crunchbaseSource,
 				OrganizationName:      "Blub.ai",
 				Description:           "long - blub1 does blub2",
 				ShortDescription:      "short - blub1 does blub2",
 				FundingStage:          "seed",
-				FoundedOn:             time.Date(2022, time.Month(8), 3, 0, 0, 0, 0, time.UTC),
+				FoundedOn:             NewDate(time.Date(2022, time.Month(8), 3, 0, 0, 0, 0, time.UTC), PrecisionDay),
 				OperatingStatus:       "active",
 				Website:               "www.blub1.ch",
 				Linkedin:              "https://www.linkedin.com/company/blub/",
@@ -141,15 +162,28 @@ func TestParseRawData(t *testing.T) {
 				NumPatentGrant:        2,
 				NumOfTechUsed:         3,
 				NumOfArticles:         4,
-				SemRush:               SemRush{NumVisitsLastMonth: 69, VisitDuration: 420, NumVisitPerPageviews: 1.4, BounceRate: 1.2},
+				SemRush:               SemRush{NumVisitsLastMonth: 69, VisitDuration: 420, NumVisitPerPageviews: 1.4, BounceRate: 1.2, VisitsLast6MonthsAvg: 58, VisitsMomPct: -0.5, GlobalRank: 7670511, GlobalRankMom: 7092769, GlobalRankMomPct: 12.25},
 				NumInvestors:          1,
 				FundingTotal:          1000,
 				NumFundingRounds:      1,
 				LastEquityFundingType: "seed",
 				LastFundingType:       "seed",
 				LastFundingTotal:      1000,
-				LastFundingAt:         time.Date(2022, time.Month(1), 21, 0, 0, 0, 0, time.UTC),
+				LastFundingAt:         NewDate(time.Date(2022, time.Month(1), 21, 0, 0, 0, 0, time.UTC), PrecisionDay),
 				InvestorIdentifiers:   []Person{{Uuid: "1a", EntityDefId: "person", Permalink: "bill-gates", Name: "Bill Gates"}, {Uuid: "2a", EntityDefId: "person", Permalink: "steve-jobs", Name: "Steve Jobs"}},
+				IpoStatus:             "private",
+				DiversitySpotlights:   []Category{{Uuid: "3a", EntityDefId: "diversity_spotlight", Name: "Women Founded"}},
+				InvestorType:          []string{"accelerator"},
+				InvestorStage:         []string{"seed"},
+				NumLeadInvestors:      1,
+				Apptopia:              Apptopia{NumApps: 2, NumDownloads: 1500},
+				HubTags:               []Category{{Uuid: "4a", EntityDefId: "hub", Name: "Swiss AI Companies"}},
+				Twitter:               "https://twitter.com/blub",
+				LastKeyEmployeeChange: NewDate(time.Date(2022, time.Month(6), 1, 0, 0, 0, 0, time.UTC), PrecisionDay),
+				NumEventAppearances:   3,
+				RankOrgCompany:        122925,
+				NumContacts:           5,
+				NumOfProducts:         6,
 			},
 		},
 	}
@@ -172,6 +206,17 @@ func TestParseRawData(t *testing.T) {
 	}
 }
 
+func TestPropertiesModelDefaultFields(t *testing.T) {
+	// Every field requested by the default query must be stored, fields
+	// which are requested but not modelled in Properties are dropped.
+	modelled := knownProperties(Properties{})
+	for _, field := range defaultFieldIds {
+		if !modelled[field] {
+			t.Errorf("requested field %s is not modelled in Properties", field)
+		}
+	}
+}
+
 func TestDecodeBody(t *testing.T) {
 
 	// creates test output of API request
@@ -261,11 +306,12 @@ func TestDecodeBody(t *testing.T) {
 			Uuid:                  "1a",
 			Timestamp:             time.Now(),
 			EntityDefId:           "organization",
+			Source:                crunchbaseSource,
 			OrganizationName:      "Blub.ai",
 			Description:           "long - blub1 does blub2",
 			ShortDescription:      "short - blub1 does blub2",
 			FundingStage:          "seed",
-			FoundedOn:             time.Date(2022, time.Month(8), 3, 0, 0, 0, 0, time.UTC),
+			FoundedOn:             NewDate(time.Date(2022, time.Month(8), 3, 0, 0, 0, 0, time.UTC), PrecisionDay),
 			OperatingStatus:       "active",
 			Website:               "www.blub1.ch",
 			Linkedin:              "https://www.linkedin.com/company/blub/",
@@ -288,18 +334,19 @@ func TestDecodeBody(t *testing.T) {
 			LastEquityFundingType: "seed",
 			LastFundingType:       "seed",
 			LastFundingTotal:      1000,
-			LastFundingAt:         time.Date(2022, time.Month(1), 21, 0, 0, 0, 0, time.UTC),
+			LastFundingAt:         NewDate(time.Date(2022, time.Month(1), 21, 0, 0, 0, 0, time.UTC), PrecisionDay),
 			InvestorIdentifiers:   []Person{{Uuid: "1a", EntityDefId: "person", Permalink: "bill-gates", Name: "Bill Gates"}, {Uuid: "2a", EntityDefId: "person", Permalink: "steve-jobs", Name: "Steve Jobs"}},
 		},
 			{
 				Uuid:                  "2a",
 				Timestamp:             time.Now(),
 				EntityDefId:           "organization",
+				Source:                crunchbaseSource,
 				OrganizationName:      "Blub.ai",
 				Description:           "long - blub1 does blub2",
 				ShortDescription:      "short - blub1 does blub2",
 				FundingStage:          "seed",
-				FoundedOn:             time.Date(2022, time.Month(8), 3, 0, 0, 0, 0, time.UTC),
+				FoundedOn:             NewDate(time.Date(2022, time.Month(8), 3, 0, 0, 0, 0, time.UTC), PrecisionDay),
 				OperatingStatus:       "active",
 				Website:               "www.blub1.ch",
 				Linkedin:              "https://www.linkedin.com/company/blub/",
@@ -322,7 +369,7 @@ func TestDecodeBody(t *testing.T) {
 				LastEquityFundingType: "seed",
 				LastFundingType:       "seed",
 				LastFundingTotal:      1000,
-				LastFundingAt:         time.Date(2022, time.Month(1), 21, 0, 0, 0, 0, time.UTC),
+				LastFundingAt:         NewDate(time.Date(2022, time.Month(1), 21, 0, 0, 0, 0, time.UTC), PrecisionDay),
 				InvestorIdentifiers:   []Person{{Uuid: "1a", EntityDefId: "person", Permalink: "bill-gates", Name: "Bill Gates"}, {Uuid: "2a", EntityDefId: "person", Permalink: "steve-jobs", Name: "Steve Jobs"}},
 			},
 		},
@@ -346,3 +393,41 @@ func TestDecodeBody(t *testing.T) {
 
 	}
 }
+
+func TestSleepContext(t *testing.T) {
+	t.Run("Sleep is not interrupted", func(t *testing.T) {
+		if err := sleepContext(context.Background(), time.Millisecond); err != nil {
+			t.Errorf("sleepContext() error = %v, want nil", err)
+		}
+	})
+	t.Run("Sleep is interrupted by a cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		start := time.Now()
+		if err := sleepContext(ctx, time.Hour); err != context.Canceled {
+			t.Errorf("sleepContext() error = %v, want %v", err, context.Canceled)
+		}
+		if time.Since(start) > time.Second {
+			t.Errorf("sleepContext() did not return right after the context was cancelled")
+		}
+	})
+}
+
+func TestFixCBCustomHeaders(t *testing.T) {
+	app := newFixtureTestApplication()
+	app.cbCustomConfigHeaders = CBCustomConfigHeaders{
+		UrlReferers:     []string{"https://www.crunchbase.com/v4/data/lists/organization.companies/1a", "https://www.crunchbase.com/v4/data/lists/organization.companies/2a"},
+		AcceptLanguages: []string{"en-US,en;q=0.5", "en-GB,en-US;q=0.9,en;q=0.8"},
+		UserAgents:      []string{"Edge", "Safari"},
+	}
+	// Recorded and replayed fixtures use the same headers, whichever were
+	// picked randomly before.
+	for i := 0; i < 10; i++ {
+		app.randomizeCBCustomHeaders(app.cbCustomConfigHeaders)
+		app.fixCBCustomHeaders()
+		want := CBCustomHeader{UrlReferer: "https://www.crunchbase.com/v4/data/lists/organization.companies/1a", AcceptLanguage: "en-US,en;q=0.5", UserAgent: "Edge"}
+		if *app.cbCustomHeader != want {
+			t.Fatalf("cbCustomHeader = %+v, want %+v", *app.cbCustomHeader, want)
+		}
+	}
+}
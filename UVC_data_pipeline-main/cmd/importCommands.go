@@ -0,0 +1,523 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+const (
+	// crunchbaseSource, source of the documents extracted from Crunchbase.
+	crunchbaseSource = "crunchbase"
+	// crunchbaseCSVSource, source of the documents imported from CSV exports
+	// of the Crunchbase UI.
+	crunchbaseCSVSource = "crunchbase-csv"
+)
+
+// fieldSetter, parses the value of a column of an imported file and stores
+// it in a field of an OrganizationDocument.
+type fieldSetter func(document *OrganizationDocument, value string) error
+
+// documentFields, fields of an OrganizationDocument which can be imported,
+// accessible by their JSON name. Each field converts the imported text, e.g.
+// a date or an amount in USD, into the type of the field.
+var documentFields = map[string]fieldSetter{
+	"uuid":                  stringField(func(d *OrganizationDocument) *string { return &d.Uuid }),
+	"permalink":             permalinkField,
+	"organizationName":      stringField(func(d *OrganizationDocument) *string { return &d.OrganizationName }),
+	"description":           stringField(func(d *OrganizationDocument) *string { return &d.Description }),
+	"shortDescription":      stringField(func(d *OrganizationDocument) *string { return &d.ShortDescription }),
+	"fundingStage":          enumField(func(d *OrganizationDocument) *string { return &d.FundingStage }),
+	"foundedOn":             dateField(func(d *OrganizationDocument) *Date { return &d.FoundedOn }),
+	"operatingStatus":       enumField(func(d *OrganizationDocument) *string { return &d.OperatingStatus }),
+	"website":               stringField(func(d *OrganizationDocument) *string { return &d.Website }),
+	"linkedin":              stringField(func(d *OrganizationDocument) *string { return &d.Linkedin }),
+	"facebook":              stringField(func(d *OrganizationDocument) *string { return &d.Facebook }),
+	"industries":            industriesField,
+	"headquarters":          headquartersField,
+	"city":                  stringField(func(d *OrganizationDocument) *string { return &d.City }),
+	"country":               stringField(func(d *OrganizationDocument) *string { return &d.Country }),
+	"contactEmail":          stringField(func(d *OrganizationDocument) *string { return &d.ContactEmail }),
+	"numFounders":           intField(func(d *OrganizationDocument) *int { return &d.NumFounders }),
+	"num_employees_enum":    employeesField,
+	"founderIdentifiers":    personsField(func(d *OrganizationDocument) *[]Person { return &d.FounderIdentifiers }),
+	"numOfTechUsed":         intField(func(d *OrganizationDocument) *int { return &d.NumOfTechUsed }),
+	"numArticles":           intField(func(d *OrganizationDocument) *int { return &d.NumOfArticles }),
+	"numTrademarkReg":       intField(func(d *OrganizationDocument) *int { return &d.NumTrademarkReg }),
+	"numPatentGrant":        intField(func(d *OrganizationDocument) *int { return &d.NumPatentGrant }),
+	"numInvestors":          intField(func(d *OrganizationDocument) *int { return &d.NumInvestors }),
+	"fundingTotal":          usdField(func(d *OrganizationDocument) *int { return &d.FundingTotal }),
+	"numFundingRounds":      intField(func(d *OrganizationDocument) *int { return &d.NumFundingRounds }),
+	"lastEquityFundingType": enumField(func(d *OrganizationDocument) *string { return &d.LastEquityFundingType }),
+	"lastFundingType":       enumField(func(d *OrganizationDocument) *string { return &d.LastFundingType }),
+	"lastFundingTotal":      usdField(func(d *OrganizationDocument) *int { return &d.LastFundingTotal }),
+	"lastFundingAt":         dateField(func(d *OrganizationDocument) *Date { return &d.LastFundingAt }),
+	"investorIdentifiers":   personsField(func(d *OrganizationDocument) *[]Person { return &d.InvestorIdentifiers }),
+}
+
+// crunchbaseCSVColumns, columns of a CSV export of the Crunchbase UI (lower
+// case) and the fields of an OrganizationDocument in which they are stored.
+// Columns which are not listed are ignored.
+var crunchbaseCSVColumns = map[string]string{
+	"organization name":                      "organizationName",
+	"organization name url":                  "permalink",
+	"full description":                       "description",
+	"description":                            "shortDescription",
+	"funding status":                         "fundingStage",
+	"founded date":                           "foundedOn",
+	"operating status":                       "operatingStatus",
+	"website":                                "website",
+	"linkedin":                               "linkedin",
+	"facebook":                               "facebook",
+	"industries":                             "industries",
+	"headquarters location":                  "headquarters",
+	"contact email":                          "contactEmail",
+	"number of founders":                     "numFounders",
+	"number of employees":                    "num_employees_enum",
+	"founders":                               "founderIdentifiers",
+	"builtwith - active tech count":          "numOfTechUsed",
+	"number of articles":                     "numArticles",
+	"ipqwery - trademarks registered":        "numTrademarkReg",
+	"ipqwery - patents granted":              "numPatentGrant",
+	"number of investors":                    "numInvestors",
+	"total funding amount currency (in usd)": "fundingTotal",
+	"number of funding rounds":               "numFundingRounds",
+	"last equity funding type":               "lastEquityFundingType",
+	"last funding type":                      "lastFundingType",
+	"last funding amount currency (in usd)":  "lastFundingTotal",
+	"last funding date":                      "lastFundingAt",
+	"top 5 investors":                        "investorIdentifiers",
+}
+
+// crunchbaseCSVMapping, returns the mapping of the columns of a CSV export of
+// the Crunchbase UI, see crunchbaseCSVColumns.
+func crunchbaseCSVMapping() *importMapping {
+	mapping := &importMapping{
+		Source:    crunchbaseCSVSource,
+		UpsertKey: defaultUpsertKey,
+	}
+	for column, field := range crunchbaseCSVColumns {
+		mapping.Columns = append(mapping.Columns, columnMapping{Column: column, Field: field})
+	}
+	return mapping
+}
+
+// parseCSV, parses the CSV data of 'r' into OrganizationDocuments. The first
+// row of the data is the header, 'mapping' maps the columns onto the fields
+// of documentFields.
+func parseCSV(r io.Reader, mapping *importMapping) ([]OrganizationDocument, error) {
+	reader := csv.NewReader(r)
+	// Exports do not always have the same number of fields in every row.
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the header of the CSV data: %w", err)
+	}
+	columnSetters := mapping.setters()
+	// setters, setter of every column of the header, nil if the column is
+	// not imported.
+	setters := make([]fieldSetter, len(header))
+	imported := 0
+	for i, column := range header {
+		// Excel adds a byte order mark at the beginning of the file.
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		setter, ok := columnSetters[column]
+		if !ok {
+			continue
+		}
+		setters[i] = setter
+		imported++
+	}
+	if imported == 0 {
+		return nil, fmt.Errorf("none of the columns of the CSV data can be imported")
+	}
+
+	documents := []OrganizationDocument{}
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read row %d of the CSV data: %w", row, err)
+		}
+
+		document := mapping.newDocument()
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			if i >= len(setters) || setters[i] == nil || value == "" {
+				continue
+			}
+			if err := setters[i](&document, value); err != nil {
+				return nil, fmt.Errorf("unable to import column '%s' of row %d: %w", header[i], row, err)
+			}
+		}
+		documents = append(documents, document)
+	}
+
+	return documents, nil
+}
+
+// stringField, returns a setter which stores the value as it is.
+func stringField(field func(d *OrganizationDocument) *string) fieldSetter {
+	return func(document *OrganizationDocument, value string) error {
+		*field(document) = value
+		return nil
+	}
+}
+
+// enumField, returns a setter which stores the value as an enum of the
+// Crunchbase API, e.g. 'Early Stage Venture' is stored as
+// 'early_stage_venture'.
+func enumField(field func(d *OrganizationDocument) *string) fieldSetter {
+	return func(document *OrganizationDocument, value string) error {
+		*field(document) = toEnum(value)
+		return nil
+	}
+}
+
+// toEnum, converts a label of the Crunchbase UI into an enum value of the
+// Crunchbase API.
+func toEnum(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	value = strings.NewReplacer(" - ", "_", " ", "_", "-", "_", "&", "_and_").Replace(value)
+	return value
+}
+
+// intField, returns a setter which parses the value as an integer, e.g.
+// '1,250'.
+func intField(field func(d *OrganizationDocument) *int) fieldSetter {
+	return func(document *OrganizationDocument, value string) error {
+		number, err := strconv.Atoi(strings.ReplaceAll(value, ",", ""))
+		if err != nil {
+			return fmt.Errorf("unable to parse '%s' as an integer: %w", value, err)
+		}
+		*field(document) = number
+		return nil
+	}
+}
+
+// usdField, returns a setter which parses the value as an amount in USD,
+// see parseUSD.
+func usdField(field func(d *OrganizationDocument) *int) fieldSetter {
+	return func(document *OrganizationDocument, value string) error {
+		amount, err := parseUSD(value)
+		if err != nil {
+			return err
+		}
+		*field(document) = amount
+		return nil
+	}
+}
+
+// parseUSD, parses an amount in USD, e.g. '1500000', '$1,500,000' or
+// '$1.5M', and rounds it to whole dollars.
+func parseUSD(value string) (int, error) {
+	amount := strings.ToUpper(strings.TrimSpace(value))
+	amount = strings.TrimPrefix(amount, "US")
+	amount = strings.TrimPrefix(amount, "$")
+	amount = strings.ReplaceAll(amount, ",", "")
+
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(amount, "K"):
+		multiplier = 1e3
+	case strings.HasSuffix(amount, "M"):
+		multiplier = 1e6
+	case strings.HasSuffix(amount, "B"):
+		multiplier = 1e9
+	}
+	if multiplier != 1 {
+		amount = amount[:len(amount)-1]
+	}
+
+	number, err := strconv.ParseFloat(strings.TrimSpace(amount), 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("unable to parse '%s' as an amount in USD", value)
+	}
+	return int(number*multiplier + 0.5), nil
+}
+
+// importDateLayouts, date formats found in exports, e.g. of the Crunchbase
+// UI. A date in a layout without day or month keeps the precision of its
+// layout, see layoutPrecision.
+var importDateLayouts = []string{"2006-01-02", "Jan 2, 2006", "January 2, 2006", "01/02/2006", "2006-01", "Jan 2006", "January 2006", "2006"}
+
+// dateField, returns a setter which parses the value as a date, see
+// parseImportDate.
+func dateField(field func(d *OrganizationDocument) *Date) fieldSetter {
+	return func(document *OrganizationDocument, value string) error {
+		date, err := parseImportDate(value)
+		if err != nil {
+			return err
+		}
+		*field(document) = date
+		return nil
+	}
+}
+
+// parseImportDate, parses a date in one of the importDateLayouts.
+func parseImportDate(value string) (Date, error) {
+	for _, layout := range importDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return NewDate(date, layoutPrecision(layout)), nil
+		}
+	}
+	return Date{}, fmt.Errorf("unable to parse '%s' as a date", value)
+}
+
+// splitList, splits a comma-separated list of the Crunchbase UI.
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// industriesField, stores a comma-separated list of industries.
+func industriesField(document *OrganizationDocument, value string) error {
+	document.Industries = []Category{}
+	for _, industry := range splitList(value) {
+		document.Industries = append(document.Industries, Category{EntityDefId: "category", Name: industry})
+	}
+	return nil
+}
+
+// personsField, returns a setter which stores a comma-separated list of
+// names, e.g. founders or investors.
+func personsField(field func(d *OrganizationDocument) *[]Person) fieldSetter {
+	return func(document *OrganizationDocument, value string) error {
+		persons := []Person{}
+		for _, name := range splitList(value) {
+			persons = append(persons, Person{Name: name})
+		}
+		*field(document) = persons
+		return nil
+	}
+}
+
+// headquartersField, stores a location of the Crunchbase UI, e.g. 'Berlin,
+// Berlin, Germany', as city and country.
+func headquartersField(document *OrganizationDocument, value string) error {
+	parts := splitList(value)
+	if len(parts) == 0 {
+		return nil
+	}
+	document.City = parts[0]
+	document.Country = parts[len(parts)-1]
+	return nil
+}
+
+// permalinkField, stores the permalink of an organization, given either as
+// the URL of the organization in the Crunchbase UI or as permalink.
+func permalinkField(document *OrganizationDocument, value string) error {
+	permalink := value
+	if strings.Contains(value, "/") {
+		u, err := url.Parse(value)
+		if err != nil {
+			return fmt.Errorf("unable to parse the URL '%s': %w", value, err)
+		}
+		permalink = path.Base(strings.TrimSuffix(u.Path, "/"))
+	}
+	document.Permalink = permalink
+	return nil
+}
+
+// employeesField, stores the number of employees as a range, either given
+// as a range of the Crunchbase UI (e.g. '11-50') or as an enum of the
+// Crunchbase API (e.g. 'c_00011_00050').
+func employeesField(document *OrganizationDocument, value string) error {
+	if employees := employeesRange(value); employees != "" {
+		document.NumEmployeesEnum = employees
+		return nil
+	}
+	for _, employees := range employeesRanges {
+		if value == employees {
+			document.NumEmployeesEnum = employees
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown number of employees '%s'", value)
+}
+
+// importFile, parses the CSV or JSON lines file at 'file' with 'mapping' and
+// stores its documents in the CB collection. The documents are merged into
+// the documents with the same upsert key of the mapping if 'upsert' is true
+// (see importUpdate), otherwise they are inserted.
+func (app *application) importFile(file string, mapping *importMapping, upsert bool) error {
+	organizationDocuments, err := parseImportFile(file, mapping)
+	if err != nil {
+		return fmt.Errorf("unable to parse file %s: %w", file, err)
+	}
+	return app.importDocuments(organizationDocuments, mapping.UpsertKey, upsert)
+}
+
+// importDocuments, stores imported documents in the CB collection, see
+// importFile.
+func (app *application) importDocuments(organizationDocuments []OrganizationDocument, upsertKey string, upsert bool) error {
+	if len(organizationDocuments) == 0 {
+		return errors.New("the file does not contain any organization")
+	}
+	keyValue, ok := upsertKeys[upsertKey]
+	if upsert && !ok {
+		return fmt.Errorf("the documents cannot be upserted on the field '%s'", upsertKey)
+	}
+
+	// Documents to be stored in the db. Create an interface{} slice of the
+	// correct size.
+	docs := make([]interface{}, len(organizationDocuments))
+	filters := make([]interface{}, len(organizationDocuments))
+	for i := range organizationDocuments {
+		docs[i] = organizationDocuments[i]
+		if !upsert {
+			continue
+		}
+		// An empty key would make all documents replace each other.
+		if keyValue(&organizationDocuments[i]) == "" {
+			return fmt.Errorf("organization %d (%s) has no %s, it cannot be upserted", i+1, organizationDocuments[i].OrganizationName, upsertKey)
+		}
+		filters[i] = upsertFilter(&organizationDocuments[i], upsertKey)
+		update, err := importUpdate(organizationDocuments[i])
+		if err != nil {
+			return fmt.Errorf("organization %d (%s) cannot be upserted: %w", i+1, organizationDocuments[i].OrganizationName, err)
+		}
+		docs[i] = update
+	}
+
+	if upsert {
+		if err := app.mongoDB.MergeMultipleDocuments(filters, docs, app.dbName, app.collCB); err != nil {
+			return fmt.Errorf("failed to upsert imported documents into DB: %w", err)
+		}
+	} else {
+		if err := app.mongoDB.InsertMultipleDocuments(docs, app.dbName, app.collCB); err != nil {
+			return fmt.Errorf("failed to insert imported documents into DB: %w", err)
+		}
+	}
+	app.infoLog.Printf("%d imported organizations were stored in the collection %s.%s.", len(docs), app.dbName, app.collCB)
+	return nil
+}
+
+// upsertFilter, returns the filter which matches the stored organization of
+// the imported 'document' on the field 'upsertKey'.
+// A website or a name does not identify an organization across sources, e.g.
+// two companies of different sources can share a name, so these keys only
+// match organizations of the same source as 'document'. Websites are compared
+// without their scheme, 'www.' and trailing slash, see websitePattern.
+// Organizations extracted from Crunchbase before their permalink was stored
+// have no permalink, they are matched on their name instead.
+func upsertFilter(document *OrganizationDocument, upsertKey string) bson.D {
+	switch upsertKey {
+	case "website":
+		pattern := bson.D{{Key: "$regex", Value: websitePattern(document.Website)}, {Key: "$options", Value: "i"}}
+		return bson.D{{Key: "source", Value: document.Source}, {Key: "website", Value: pattern}}
+	case "organizationName":
+		return bson.D{{Key: "source", Value: document.Source}, {Key: "organizationName", Value: document.OrganizationName}}
+	}
+	filter := bson.D{{Key: upsertKey, Value: upsertKeys[upsertKey](document)}}
+	if upsertKey != "permalink" || document.OrganizationName == "" {
+		return filter
+	}
+	withoutPermalink := bson.D{
+		{Key: "permalink", Value: bson.D{{Key: "$in", Value: bson.A{nil, ""}}}},
+		{Key: "source", Value: bson.D{{Key: "$in", Value: bson.A{nil, "", crunchbaseSource}}}},
+		{Key: "organizationName", Value: document.OrganizationName},
+	}
+	return bson.D{{Key: "$or", Value: bson.A{filter, withoutPermalink}}}
+}
+
+// normalizeWebsite, returns the website 'website' without its scheme,
+// 'www.' and trailing slash in lower case, e.g. 'blub.ai/en' for
+// 'https://www.Blub.ai/en/'.
+func normalizeWebsite(website string) string {
+	website = strings.ToLower(strings.TrimSpace(website))
+	for _, prefix := range []string{"https://", "http://", "www."} {
+		website = strings.TrimPrefix(website, prefix)
+	}
+	return strings.TrimRight(website, "/")
+}
+
+// websitePattern, returns the regular expression which matches all spellings
+// of the website 'website' (case-insensitive), see normalizeWebsite.
+func websitePattern(website string) string {
+	return `^(https?://)?(www\.)?` + regexp.QuoteMeta(normalizeWebsite(website)) + `/*$`
+}
+
+// importUpdate, returns the update of the stored organization matched by the
+// imported 'document'. Only the fields which have a value in the imported
+// document are set, all other fields keep their stored value, e.g. the fields
+// of an organization extracted from Crunchbase which are not part of the
+// import. The source and the entity type are only set if the organization is
+// inserted.
+func importUpdate(document OrganizationDocument) (bson.D, error) {
+	data, err := bson.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode document as BSON: %w", err)
+	}
+	elements, err := bson.Raw(data).Elements()
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode document as BSON: %w", err)
+	}
+
+	set, setOnInsert := bson.D{}, bson.D{}
+	for _, element := range elements {
+		switch key, value := element.Key(), element.Value(); {
+		case key == "source" || key == "entityDefId":
+			setOnInsert = append(setOnInsert, bson.E{Key: key, Value: value})
+		case !isEmptyBSONValue(value):
+			set = append(set, bson.E{Key: key, Value: value})
+		}
+	}
+	return bson.D{{Key: "$set", Value: set}, {Key: "$setOnInsert", Value: setOnInsert}}, nil
+}
+
+// isEmptyBSONValue, returns true if 'value' is the encoding of a zero value,
+// e.g. an empty string, 0, an empty array, an unknown date or a document
+// whose fields are all empty.
+func isEmptyBSONValue(value bson.RawValue) bool {
+	switch value.Type {
+	case bsontype.Null, bsontype.Undefined:
+		return true
+	case bsontype.String:
+		return value.StringValue() == ""
+	case bsontype.Int32:
+		return value.Int32() == 0
+	case bsontype.Int64:
+		return value.Int64() == 0
+	case bsontype.Double:
+		return value.Double() == 0
+	case bsontype.Boolean:
+		return !value.Boolean()
+	case bsontype.DateTime:
+		return value.Time().IsZero()
+	case bsontype.Array:
+		values, err := value.Array().Values()
+		return err == nil && len(values) == 0
+	case bsontype.EmbeddedDocument:
+		elements, err := value.Document().Elements()
+		if err != nil {
+			return false
+		}
+		for _, element := range elements {
+			if !isEmptyBSONValue(element.Value()) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
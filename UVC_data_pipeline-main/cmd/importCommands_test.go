@@ -0,0 +1,302 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseUSD(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "1500000", want: 1500000},
+		{value: "$1,500,000", want: 1500000},
+		{value: "$1.5M", want: 1500000},
+		{value: "US$250K", want: 250000},
+		{value: "2B", want: 2000000000},
+		{value: "unknown", wantErr: true},
+		{value: "-5", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseUSD(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseUSD() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseUSD() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCrunchbaseCSV(t *testing.T) {
+	data := "\ufeffOrganization Name,Organization Name URL,Founded Date,Headquarters Location,Number of Employees,Funding Status,Total Funding Amount Currency (in USD),Industries,CB Rank (Company)\n" +
+		"Blub.ai,https://www.crunchbase.com/organization/blub-ai,2021-03-01,\"Berlin, Berlin, Germany\",11-50,Early Stage Venture,\"$1,500,000\",\"Artificial Intelligence, SaaS\",1234\n" +
+		"Thinkgate,https://www.crunchbase.com/organization/thinkgate,Jan 2020,\"Zurich, Zurich, Switzerland\",c_00001_00010,Seed,,,\n"
+
+	documents, err := parseCSV(strings.NewReader(data), crunchbaseCSVMapping())
+	if err != nil {
+		t.Fatalf("parseCSV() error = %v", err)
+	}
+	if len(documents) != 2 {
+		t.Fatalf("parseCSV() returned %d documents, want 2", len(documents))
+	}
+
+	blub := documents[0]
+	if blub.OrganizationName != "Blub.ai" || blub.Permalink != "blub-ai" {
+		t.Errorf("name, permalink = %s, %s, want Blub.ai, blub-ai", blub.OrganizationName, blub.Permalink)
+	}
+	if want := NewDate(time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC), PrecisionDay); blub.FoundedOn != want {
+		t.Errorf("FoundedOn = %v, want %v", blub.FoundedOn, want)
+	}
+	if blub.City != "Berlin" || blub.Country != "Germany" {
+		t.Errorf("City, Country = %s, %s, want Berlin, Germany", blub.City, blub.Country)
+	}
+	if blub.NumEmployeesEnum != "11-50" || blub.FundingStage != "early_stage_venture" || blub.FundingTotal != 1500000 {
+		t.Errorf("employees, stage, funding = %s, %s, %d", blub.NumEmployeesEnum, blub.FundingStage, blub.FundingTotal)
+	}
+	if len(blub.Industries) != 2 || blub.Industries[1].Name != "SaaS" {
+		t.Errorf("Industries = %v, want Artificial Intelligence and SaaS", blub.Industries)
+	}
+
+	thinkgate := documents[1]
+	// The export only contains the month in which Thinkgate was founded.
+	if want := NewDate(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC), PrecisionMonth); thinkgate.FoundedOn != want {
+		t.Errorf("FoundedOn = %v, want %v", thinkgate.FoundedOn, want)
+	}
+	if thinkgate.NumEmployeesEnum != "1-10" {
+		t.Errorf("NumEmployeesEnum = %s, want 1-10", thinkgate.NumEmployeesEnum)
+	}
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{
+			name: "No importable column",
+			data: "Name,City\nBlub.ai,Berlin\n",
+		},
+		{
+			name: "Invalid date",
+			data: "Organization Name,Founded Date\nBlub.ai,sometime\n",
+		},
+		{
+			name: "Unknown number of employees",
+			data: "Organization Name,Number of Employees\nBlub.ai,a few\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseCSV(strings.NewReader(tt.data), crunchbaseCSVMapping()); err == nil {
+				t.Errorf("parseCSV() error = nil, want error")
+			}
+		})
+	}
+}
+
+// applyImportUpdate, returns the document 'stored' after the update of the
+// imported 'document' (see importUpdate) was applied by the db, like an
+// upsert which matched 'stored'.
+func applyImportUpdate(t *testing.T, stored, document OrganizationDocument) OrganizationDocument {
+	t.Helper()
+	update, err := importUpdate(document)
+	if err != nil {
+		t.Fatalf("importUpdate() error = %v", err)
+	}
+	data, err := bson.Marshal(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := bson.M{}
+	if err := bson.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, operator := range update {
+		if operator.Key != "$set" {
+			continue
+		}
+		for _, field := range operator.Value.(bson.D) {
+			fields[field.Key] = field.Value
+		}
+	}
+	if data, err = bson.Marshal(fields); err != nil {
+		t.Fatal(err)
+	}
+	merged := OrganizationDocument{}
+	if err := bson.Unmarshal(data, &merged); err != nil {
+		t.Fatal(err)
+	}
+	return merged
+}
+
+func TestImportUpdate(t *testing.T) {
+	extracted := OrganizationDocument{
+		Uuid:             "1a",
+		EntityDefId:      "organization",
+		Source:           crunchbaseSource,
+		OrganizationName: "Blub.ai",
+		Description:      "Blub.ai builds AI for whales.",
+		Website:          "https://www.blub.ai",
+		FundingTotal:     1000000,
+		SemRush:          SemRush{NumVisitsLastMonth: 1200},
+		Industries:       []Category{{EntityDefId: "category", Name: "Artificial Intelligence"}},
+	}
+	data := "Organization Name,Organization Name URL,Total Funding Amount Currency (in USD),Number of Founders\n" +
+		"Blub.ai,https://www.crunchbase.com/organization/blub-ai,\"$1,500,000\",2\n"
+	documents, err := parseCSV(strings.NewReader(data), crunchbaseCSVMapping())
+	if err != nil {
+		t.Fatalf("parseCSV() error = %v", err)
+	}
+
+	got := applyImportUpdate(t, extracted, documents[0])
+	want := extracted
+	want.Permalink = "blub-ai"
+	want.FundingTotal = 1500000
+	want.NumFounders = 2
+	want.Timestamp = got.Timestamp
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merged document = %+v, want %+v", got, want)
+	}
+}
+
+// matchesFilter, returns true if the top-level fields of 'document' match
+// 'filter' like a find of the db, it supports the filters of upsertFilter.
+func matchesFilter(t *testing.T, document OrganizationDocument, filter bson.D) bool {
+	t.Helper()
+	data, err := bson.Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := bson.M{}
+	if err := bson.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	var matches func(filter bson.D) bool
+	matches = func(filter bson.D) bool {
+		for _, condition := range filter {
+			if condition.Key == "$or" {
+				matched := false
+				for _, alternative := range condition.Value.(bson.A) {
+					matched = matched || matches(alternative.(bson.D))
+				}
+				if !matched {
+					return false
+				}
+				continue
+			}
+			value := fields[condition.Key]
+			operators, ok := condition.Value.(bson.D)
+			if !ok {
+				if value != condition.Value {
+					return false
+				}
+				continue
+			}
+			switch operators[0].Key {
+			case "$in":
+				in := false
+				for _, v := range operators[0].Value.(bson.A) {
+					in = in || value == v
+				}
+				if !in {
+					return false
+				}
+			case "$regex":
+				text, _ := value.(string)
+				if !regexp.MustCompile("(?i)" + operators[0].Value.(string)).MatchString(text) {
+					return false
+				}
+			default:
+				t.Fatalf("unsupported operator %s", operators[0].Key)
+			}
+		}
+		return true
+	}
+	return matches(filter)
+}
+
+func TestUpsertFilter(t *testing.T) {
+	extracted := OrganizationDocument{Uuid: "1a", Source: crunchbaseSource, OrganizationName: "Blub.ai", Website: "https://www.Blub.ai/"}
+	legacy := OrganizationDocument{Uuid: "2a", OrganizationName: "Thinkgate", Website: "https://thinkgate.ch"}
+	imported := OrganizationDocument{Source: "accelerator-demo-day", OrganizationName: "Blub.ai", Website: "http://blub.ai"}
+
+	tests := []struct {
+		name      string
+		document  OrganizationDocument
+		upsertKey string
+		stored    OrganizationDocument
+		want      bool
+	}{
+		{"Same permalink", OrganizationDocument{Source: crunchbaseCSVSource, OrganizationName: "Blub.ai", Permalink: "blub-ai"}, "permalink", OrganizationDocument{Source: crunchbaseSource, OrganizationName: "Blub", Permalink: "blub-ai"}, true},
+		{"Extracted without permalink", OrganizationDocument{Source: crunchbaseCSVSource, OrganizationName: "Thinkgate", Permalink: "thinkgate"}, "permalink", legacy, true},
+		{"Other permalink", OrganizationDocument{Source: crunchbaseCSVSource, OrganizationName: "Blub.ai", Permalink: "blub-ai"}, "permalink", OrganizationDocument{Source: crunchbaseSource, OrganizationName: "Blub.ai", Permalink: "blub-ai-2"}, false},
+		{"Website of another source", imported, "website", extracted, false},
+		{"Website of the same source", imported, "website", OrganizationDocument{Source: "accelerator-demo-day", Website: "https://www.Blub.ai/"}, true},
+		{"Other website", imported, "website", OrganizationDocument{Source: "accelerator-demo-day", Website: "https://blub.ai.example.com"}, false},
+		{"Name of another source", imported, "organizationName", extracted, false},
+		{"Name of the same source", imported, "organizationName", OrganizationDocument{Source: "accelerator-demo-day", OrganizationName: "Blub.ai"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesFilter(t, tt.stored, upsertFilter(&tt.document, tt.upsertKey)); got != tt.want {
+				t.Errorf("upsertFilter() matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpsertOverExtractedOrganization(t *testing.T) {
+	extracted := OrganizationDocument{
+		Uuid:             "1a",
+		EntityDefId:      "organization",
+		Source:           crunchbaseSource,
+		OrganizationName: "Blub.ai",
+		Description:      "Blub.ai builds AI for whales.",
+		Website:          "https://www.blub.ai/",
+		FundingTotal:     1000000,
+		Industries:       []Category{{EntityDefId: "category", Name: "Artificial Intelligence"}},
+	}
+	mapping, err := loadImportMapping("../mappings/accelerator-demo-day.yaml")
+	if err != nil {
+		t.Fatalf("loadImportMapping() error = %v", err)
+	}
+	csvData := "Company,Website,Founded,Stage,Raised (USD),Sector,Notes\n" +
+		"Blub.ai,https://blub.ai,01.03.2021,Pre-Seed,\"$250,000\",\"AI, SaaS\",met at demo day\n"
+	documents, err := parseCSV(strings.NewReader(csvData), mapping)
+	if err != nil {
+		t.Fatalf("parseCSV() error = %v", err)
+	}
+
+	// upsert, stores the imported document in the collection like the db:
+	// it updates the first matching document or inserts a new one.
+	collection := []OrganizationDocument{extracted}
+	upsert := func(document OrganizationDocument, upsertKey string) {
+		for i, stored := range collection {
+			if matchesFilter(t, stored, upsertFilter(&document, upsertKey)) {
+				collection[i] = applyImportUpdate(t, stored, document)
+				return
+			}
+		}
+		collection = append(collection, document)
+	}
+	for _, upsertKey := range []string{"website", "organizationName"} {
+		upsert(documents[0], upsertKey)
+	}
+
+	if !reflect.DeepEqual(collection[0], extracted) {
+		t.Errorf("extracted organization = %+v, want %+v", collection[0], extracted)
+	}
+	if len(collection) != 2 || collection[1].Source != mapping.Source {
+		t.Errorf("collection = %+v, want the extracted and the imported organization", collection)
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultUpsertKey, field on which imported documents are upserted if the
+// mapping does not define another one. Exports of the Crunchbase UI contain
+// the URL of every organization, but not its UUID.
+const defaultUpsertKey = "permalink"
+
+// upsertKeys, fields of an OrganizationDocument on which imported documents
+// can be upserted, accessible by their JSON (and BSON) name.
+var upsertKeys = map[string]func(d *OrganizationDocument) string{
+	"uuid":             func(d *OrganizationDocument) string { return d.Uuid },
+	"permalink":        func(d *OrganizationDocument) string { return d.Permalink },
+	"website":          func(d *OrganizationDocument) string { return normalizeWebsite(d.Website) },
+	"organizationName": func(d *OrganizationDocument) string { return d.OrganizationName },
+}
+
+// importDateFields, fields of documentFields which are dates and accept a
+// date format in a column mapping.
+var importDateFields = map[string]bool{
+	"foundedOn":     true,
+	"lastFundingAt": true,
+}
+
+// importMapping, declarative mapping of the columns of an imported CSV or
+// JSON lines file onto the fields of an OrganizationDocument.
+type importMapping struct {
+	// Source, name of the source of the file, e.g. an accelerator or a
+	// database, which is stored in every imported document.
+	Source string `json:"source" yaml:"source"`
+	// UpsertKey, field on which the documents are upserted, see upsertKeys.
+	UpsertKey string `json:"upsert_key,omitempty" yaml:"upsert_key,omitempty"`
+	// Columns, columns of the file which are imported, all other columns are
+	// ignored.
+	Columns []columnMapping `json:"columns" yaml:"columns"`
+}
+
+// columnMapping, maps a column of an imported file onto a field of
+// documentFields.
+type columnMapping struct {
+	// Column, name of the column (CSV) or key (JSON lines), the case is
+	// ignored.
+	Column string `json:"column" yaml:"column"`
+	// Field, JSON name of the field of documentFields.
+	Field string `json:"field" yaml:"field"`
+	// Format, layout of a date column in the notation of the time package,
+	// e.g. '02.01.2006'. The layouts of importDateLayouts are tried if it is
+	// empty.
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	// Values, replacements of values of the column before they are
+	// converted, e.g. 'Pre-Seed: seed'. A value replaced with an empty
+	// string is not imported.
+	Values map[string]string `json:"values,omitempty" yaml:"values,omitempty"`
+}
+
+// loadImportMapping, reads and validates the mapping file at 'path'.
+func loadImportMapping(path string) (*importMapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read mapping file %s: %w", path, err)
+	}
+
+	mapping, err := parseImportMapping(data, filepath.Ext(path))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse mapping file %s: %w", path, err)
+	}
+
+	return mapping, nil
+}
+
+// parseImportMapping, decodes an importMapping from data, the format of data
+// is defined by the file extension ext. It fills in defaults and validates
+// the decoded mapping.
+func parseImportMapping(data []byte, ext string) (*importMapping, error) {
+	mapping := new(importMapping)
+
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, mapping); err != nil {
+			return nil, fmt.Errorf("unable to decode YAML mapping: %w", err)
+		}
+	case ".json":
+		if err := json.Unmarshal(data, mapping); err != nil {
+			return nil, fmt.Errorf("unable to decode JSON mapping: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported mapping file extension '%s', use .yaml, .yml or .json", ext)
+	}
+
+	if mapping.UpsertKey == "" {
+		mapping.UpsertKey = defaultUpsertKey
+	}
+	if err := mapping.validate(); err != nil {
+		return nil, fmt.Errorf("invalid mapping: %w", err)
+	}
+	return mapping, nil
+}
+
+// validate, checks that the mapping has a source and that all its columns
+// are mapped onto known fields.
+func (m *importMapping) validate() error {
+	if m.Source == "" {
+		return errors.New("the source is missing")
+	}
+	if _, ok := upsertKeys[m.UpsertKey]; !ok {
+		return fmt.Errorf("the documents cannot be upserted on the field '%s'", m.UpsertKey)
+	}
+	if len(m.Columns) == 0 {
+		return errors.New("no column is mapped")
+	}
+	for _, column := range m.Columns {
+		if column.Column == "" {
+			return fmt.Errorf("the column of the field '%s' is missing", column.Field)
+		}
+		if _, ok := documentFields[column.Field]; !ok {
+			return fmt.Errorf("column '%s' is mapped onto the unknown field '%s'", column.Column, column.Field)
+		}
+		if column.Format != "" && !importDateFields[column.Field] {
+			return fmt.Errorf("column '%s' has a date format, but the field '%s' is not a date", column.Column, column.Field)
+		}
+	}
+	return nil
+}
+
+// setters, returns the setter of every mapped column, accessible by the
+// lower case name of the column.
+func (m *importMapping) setters() map[string]fieldSetter {
+	setters := make(map[string]fieldSetter, len(m.Columns))
+	for _, column := range m.Columns {
+		setters[strings.ToLower(strings.TrimSpace(column.Column))] = column.setter()
+	}
+	return setters
+}
+
+// setter, returns the setter of the field of the column, which applies the
+// replacements and the date format of the column before the conversion of
+// the field.
+func (c columnMapping) setter() fieldSetter {
+	setField := documentFields[c.Field]
+	return func(document *OrganizationDocument, value string) error {
+		if replacement, ok := c.Values[value]; ok {
+			value = replacement
+		}
+		if value == "" {
+			return nil
+		}
+		if c.Format != "" {
+			date, err := time.Parse(c.Format, value)
+			if err != nil {
+				return fmt.Errorf("invalid date '%s', expected the format '%s'", value, c.Format)
+			}
+			// The date keeps the precision of the format, e.g. 'year'
+			// for '2006'.
+			value = NewDate(date, layoutPrecision(c.Format)).String()
+		}
+		return setField(document, value)
+	}
+}
+
+// newDocument, returns an empty organization imported from the source of
+// the mapping.
+func (m *importMapping) newDocument() OrganizationDocument {
+	return OrganizationDocument{
+		EntityDefId: "organization",
+		Source:      m.Source,
+		Timestamp:   time.Now(),
+	}
+}
+
+// parseImportFile, parses the CSV or JSON lines file at 'file' into
+// OrganizationDocuments, the format is defined by the file extension.
+func parseImportFile(file string, mapping *importMapping) ([]OrganizationDocument, error) {
+	var parse func(r io.Reader, mapping *importMapping) ([]OrganizationDocument, error)
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case ".csv":
+		parse = parseCSV
+	case ".jsonl", ".ndjson", ".json":
+		parse = parseJSONLines
+	default:
+		return nil, fmt.Errorf("unsupported file extension '%s', use .csv, .jsonl, .ndjson or .json", ext)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file %s: %w", file, err)
+	}
+	defer f.Close()
+
+	return parse(f, mapping)
+}
+
+// parseJSONLines, parses the JSON lines data of 'r' into
+// OrganizationDocuments. Every line is an object, whose keys are the columns
+// of the mapping.
+func parseJSONLines(r io.Reader, mapping *importMapping) ([]OrganizationDocument, error) {
+	setters := mapping.setters()
+	decoder := json.NewDecoder(r)
+	// Keep large numbers, e.g. funding amounts, exact.
+	decoder.UseNumber()
+
+	documents := []OrganizationDocument{}
+	imported := false
+	for line := 1; ; line++ {
+		record := map[string]interface{}{}
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read record %d of the JSON lines data: %w", line, err)
+		}
+
+		document := mapping.newDocument()
+		for key, raw := range record {
+			setter, ok := setters[strings.ToLower(strings.TrimSpace(key))]
+			if !ok {
+				continue
+			}
+			imported = true
+			value, err := jsonValueString(raw)
+			if err != nil {
+				return nil, fmt.Errorf("unable to import key '%s' of record %d: %w", key, line, err)
+			}
+			if value == "" {
+				continue
+			}
+			if err := setter(&document, value); err != nil {
+				return nil, fmt.Errorf("unable to import key '%s' of record %d: %w", key, line, err)
+			}
+		}
+		documents = append(documents, document)
+	}
+	if len(documents) > 0 && !imported {
+		return nil, errors.New("none of the keys of the JSON lines data can be imported")
+	}
+
+	return documents, nil
+}
+
+// jsonValueString, returns a decoded JSON value as the text of a column. The
+// elements of an array are joined by commas, like the lists of a CSV file.
+func jsonValueString(raw interface{}) (string, error) {
+	switch value := raw.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return strings.TrimSpace(value), nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case []interface{}:
+		elements := make([]string, 0, len(value))
+		for _, element := range value {
+			text, err := jsonValueString(element)
+			if err != nil {
+				return "", err
+			}
+			if text != "" {
+				elements = append(elements, text)
+			}
+		}
+		return strings.Join(elements, ", "), nil
+	default:
+		return "", fmt.Errorf("unsupported value of type %T", raw)
+	}
+}
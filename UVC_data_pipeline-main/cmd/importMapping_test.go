@@ -0,0 +1,159 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseImportMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		ext     string
+		wantErr bool
+	}{
+		{
+			name: "YAML mapping",
+			data: "source: demo-day\ncolumns:\n  - column: Company\n    field: organizationName\n",
+			ext:  ".yaml",
+		},
+		{
+			name: "JSON mapping",
+			data: `{"source": "demo-day", "upsert_key": "website", "columns": [{"column": "Company", "field": "organizationName"}]}`,
+			ext:  ".json",
+		},
+		{
+			name:    "Missing source",
+			data:    "columns:\n  - column: Company\n    field: organizationName\n",
+			ext:     ".yaml",
+			wantErr: true,
+		},
+		{
+			name:    "Unknown field",
+			data:    "source: demo-day\ncolumns:\n  - column: Company\n    field: companyName\n",
+			ext:     ".yaml",
+			wantErr: true,
+		},
+		{
+			name:    "Date format of a text field",
+			data:    "source: demo-day\ncolumns:\n  - column: Company\n    field: organizationName\n    format: '2006'\n",
+			ext:     ".yaml",
+			wantErr: true,
+		},
+		{
+			name:    "Unsupported upsert key",
+			data:    "source: demo-day\nupsert_key: city\ncolumns:\n  - column: Company\n    field: organizationName\n",
+			ext:     ".yaml",
+			wantErr: true,
+		},
+		{
+			name:    "Unsupported extension",
+			data:    "source: demo-day",
+			ext:     ".toml",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapping, err := parseImportMapping([]byte(tt.data), tt.ext)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseImportMapping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && mapping.UpsertKey == "" {
+				t.Errorf("parseImportMapping() did not set a default upsert key")
+			}
+		})
+	}
+}
+
+func TestLoadExampleImportMapping(t *testing.T) {
+	mapping, err := loadImportMapping("../mappings/accelerator-demo-day.yaml")
+	if err != nil {
+		t.Fatalf("loadImportMapping() error = %v", err)
+	}
+	if mapping.Source != "accelerator-demo-day" || mapping.UpsertKey != "website" {
+		t.Errorf("source, upsert key = %s, %s, want accelerator-demo-day, website", mapping.Source, mapping.UpsertKey)
+	}
+}
+
+func TestParseMappedFiles(t *testing.T) {
+	mapping, err := loadImportMapping("../mappings/accelerator-demo-day.yaml")
+	if err != nil {
+		t.Fatalf("loadImportMapping() error = %v", err)
+	}
+
+	csvData := "Company,Website,Founded,Stage,Raised (USD),Sector,Notes\n" +
+		"Blub.ai,https://blub.ai,01.03.2021,Pre-Seed,\"$250,000\",\"AI, SaaS\",met at demo day\n" +
+		"Thinkgate,https://thinkgate.ch,,Idea,,,\n"
+	jsonData := `{"Company": "Blub.ai", "website": "https://blub.ai", "Founded": "01.03.2021", "Stage": "Pre-Seed", "Raised (USD)": 250000, "Sector": ["AI", "SaaS"], "Notes": {"met": true}}` + "\n" +
+		`{"Company": "Thinkgate", "Website": "https://thinkgate.ch", "Founded": null, "Stage": "Idea"}` + "\n"
+
+	tests := []struct {
+		name  string
+		parse func() ([]OrganizationDocument, error)
+	}{
+		{
+			name:  "CSV",
+			parse: func() ([]OrganizationDocument, error) { return parseCSV(strings.NewReader(csvData), mapping) },
+		},
+		{
+			name:  "JSON lines",
+			parse: func() ([]OrganizationDocument, error) { return parseJSONLines(strings.NewReader(jsonData), mapping) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			documents, err := tt.parse()
+			if err != nil {
+				t.Fatalf("parse error = %v", err)
+			}
+			if len(documents) != 2 {
+				t.Fatalf("parse returned %d documents, want 2", len(documents))
+			}
+
+			blub := documents[0]
+			if blub.Source != "accelerator-demo-day" || blub.OrganizationName != "Blub.ai" || blub.Website != "https://blub.ai" {
+				t.Errorf("source, name, website = %s, %s, %s", blub.Source, blub.OrganizationName, blub.Website)
+			}
+			if want := NewDate(time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC), PrecisionDay); blub.FoundedOn != want {
+				t.Errorf("FoundedOn = %v, want %v", blub.FoundedOn, want)
+			}
+			if blub.FundingStage != "pre_seed" || blub.FundingTotal != 250000 {
+				t.Errorf("stage, funding = %s, %d, want pre_seed, 250000", blub.FundingStage, blub.FundingTotal)
+			}
+			if len(blub.Industries) != 2 || blub.Industries[0].Name != "AI" {
+				t.Errorf("Industries = %v, want AI and SaaS", blub.Industries)
+			}
+
+			thinkgate := documents[1]
+			if thinkgate.FundingStage != "" || !thinkgate.FoundedOn.IsZero() {
+				t.Errorf("stage, founded = %s, %v, want no values", thinkgate.FundingStage, thinkgate.FoundedOn)
+			}
+		})
+	}
+}
+
+func TestParseJSONLinesErrors(t *testing.T) {
+	mapping, err := parseImportMapping([]byte("source: demo-day\ncolumns:\n  - column: Company\n    field: organizationName\n  - column: Founded\n    field: foundedOn\n    format: '02.01.2006'\n"), ".yaml")
+	if err != nil {
+		t.Fatalf("parseImportMapping() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "Invalid JSON", data: `{"Company": "Blub.ai"`},
+		{name: "No importable key", data: `{"Name": "Blub.ai"}`},
+		{name: "Date in another format", data: `{"Company": "Blub.ai", "Founded": "2021-03-01"}`},
+		{name: "Object value", data: `{"Company": {"name": "Blub.ai"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseJSONLines(strings.NewReader(tt.data), mapping); err == nil {
+				t.Errorf("parseJSONLines() error = nil, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// extractStateFile, path to the file in which the last successful run (full
+// or incremental) of every query is stored, used by incremental extractions.
+const extractStateFile = "./extract-state.json"
+
+// incrementalDateLayout, layout of the date sent to the Crunchbase API in the
+// predicate of an incremental extraction.
+const incrementalDateLayout = "2006-01-02"
+
+// extractState, last successful runs of all extracted queries.
+type extractState struct {
+	// Runs, last successful run of a query, accessible by the hash of the
+	// query (without the incremental predicate).
+	Runs map[string]successfulRun `json:"runs"`
+}
+
+// successfulRun, summary of a complete extraction run.
+type successfulRun struct {
+	RunId string `json:"run_id"`
+	// Description, description of the query, to recognize the query when
+	// reading the state file.
+	Description  string `json:"description,omitempty"`
+	CollectionId string `json:"collection_id"`
+	// StartedAt, time at which the run was started. Entities changed while
+	// the run was ongoing are requested again by the next run.
+	StartedAt       time.Time `json:"started_at"`
+	CompletedAt     time.Time `json:"completed_at"`
+	EntitiesWritten int       `json:"entities_written"`
+}
+
+// loadExtractState, loads the extract state stored at 'path'. A missing file
+// returns an empty state, e.g. before the first incremental run.
+func loadExtractState(path string) (*extractState, error) {
+	state := &extractState{Runs: map[string]successfulRun{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read extract state file %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("unable to decode extract state file %s: %w", path, err)
+	}
+	if state.Runs == nil {
+		state.Runs = map[string]successfulRun{}
+	}
+	return state, nil
+}
+
+// save, stores the extract state at 'path'.
+func (state *extractState) save(path string) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode extract state as JSON: %w", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("unable to store extract state: %w", err)
+	}
+	return nil
+}
+
+// since, returns a copy of the query which only matches entities whose date
+// field 'field' is on or after the day of 't'.
+func (q *Query) since(field string, t time.Time) *Query {
+	return q.withPredicates(QueryPredicate{
+		FieldId:    field,
+		OperatorId: "gte",
+		Values:     []interface{}{t.UTC().Format(incrementalDateLayout)},
+	})
+}
+
+// configureIncremental, restricts app.query to the entities changed since the
+// last successful run of the query stored in the extract state file 'path'.
+// If the query was never extracted successfully, the full result set is
+// extracted. It fails if the collection has no field which records changes.
+func (app *application) configureIncremental(path string) error {
+	field := app.query.IncrementalField
+	if field == "" {
+		field = app.collection.updatedField
+	}
+	if field == "" {
+		return fmt.Errorf("the collection %s has no field which records changes of its entities, it cannot be extracted incrementally (set incremental_field in the query file)", app.collection.id)
+	}
+
+	key, err := app.query.hash()
+	if err != nil {
+		return err
+	}
+	app.incrementalKey = key
+
+	state, err := loadExtractState(path)
+	if err != nil {
+		return err
+	}
+	lastRun, ok := state.Runs[key]
+	if !ok {
+		app.infoLog.Print("[INCREMENTAL] No previous successful run of this query, extracting all entities.")
+		return nil
+	}
+
+	app.query = app.query.since(field, lastRun.StartedAt)
+	app.incrementalSince = lastRun.StartedAt
+	app.infoLog.Printf("[INCREMENTAL] Extracting entities with %s on or after %s (last successful run: %s).", field, lastRun.StartedAt.UTC().Format(incrementalDateLayout), lastRun.RunId)
+	return nil
+}
+
+// recordSuccessfulRun, stores the complete run of the checkpoint 'cp' as the
+// last successful run of the query in the extract state file 'path'. A full
+// run is recorded under the hash of its query, so that it is the baseline of
+// the next incremental run of the same query.
+func (app *application) recordSuccessfulRun(path string, cp *checkpoint) error {
+	key := app.incrementalKey
+	if key == "" {
+		var err error
+		if key, err = app.query.hash(); err != nil {
+			return err
+		}
+	}
+	state, err := loadExtractState(path)
+	if err != nil {
+		return err
+	}
+	state.Runs[key] = successfulRun{
+		RunId:           cp.RunId,
+		Description:     app.query.Description,
+		CollectionId:    app.query.CollectionId,
+		StartedAt:       cp.StartedAt,
+		CompletedAt:     time.Now(),
+		EntitiesWritten: cp.EntitiesWritten,
+	}
+	if err := state.save(path); err != nil {
+		return fmt.Errorf("run %s was successful but it could not be recorded for incremental extractions: %w", cp.RunId, err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQuerySince(t *testing.T) {
+	query := defaultQuery()
+	predicates := len(query.Predicates)
+
+	got := query.since("updated_at", time.Date(2023, time.February, 14, 23, 30, 0, 0, time.UTC))
+	if len(query.Predicates) != predicates {
+		t.Errorf("since() modified the predicates of the original query")
+	}
+	want := QueryPredicate{FieldId: "updated_at", OperatorId: "gte", Values: []interface{}{"2023-02-14"}}
+	if last := got.Predicates[len(got.Predicates)-1]; !reflect.DeepEqual(last, want) {
+		t.Errorf("since() added predicate %v, want %v", last, want)
+	}
+}
+
+func TestConfigureIncremental(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "extract-state.json")
+	app := newRetryTestApplication(0)
+	app.query = defaultQuery()
+	app.collection = entityCollections[defaultCollectionId]
+	fullQueryHash, _ := app.query.hash()
+
+	// The first run extracts all entities.
+	if err := app.configureIncremental(path); err != nil {
+		t.Fatalf("configureIncremental() error = %v", err)
+	}
+	if queryHash, _ := app.query.hash(); queryHash != fullQueryHash {
+		t.Errorf("first incremental run changed the query")
+	}
+	startedAt := time.Date(2023, time.February, 14, 10, 15, 2, 0, time.UTC)
+	if err := app.recordSuccessfulRun(path, &checkpoint{RunId: "run1", StartedAt: startedAt, EntitiesWritten: 42}); err != nil {
+		t.Fatalf("recordSuccessfulRun() error = %v", err)
+	}
+
+	// The second run only extracts the entities changed since the first run.
+	app.query = defaultQuery()
+	if err := app.configureIncremental(path); err != nil {
+		t.Fatalf("configureIncremental() error = %v", err)
+	}
+	if app.incrementalKey != fullQueryHash {
+		t.Errorf("incrementalKey = %s, want the hash of the full query %s", app.incrementalKey, fullQueryHash)
+	}
+	last := app.query.Predicates[len(app.query.Predicates)-1]
+	if last.FieldId != "updated_at" || !reflect.DeepEqual(last.Values, []interface{}{"2023-02-14"}) {
+		t.Errorf("incremental predicate = %v, want updated_at >= 2023-02-14", last)
+	}
+}
+
+func TestConfigureIncrementalWithoutUpdatedField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "extract-state.json")
+	app := newRetryTestApplication(0)
+	app.query = defaultQuery()
+	app.collection = &entityCollection{id: "events"}
+	if err := app.configureIncremental(path); err == nil {
+		t.Errorf("configureIncremental() of a collection without updated field returned no error")
+	}
+
+	// A query can name the field which records changes itself.
+	app.query.IncrementalField = "updated_at"
+	if err := app.configureIncremental(path); err != nil {
+		t.Errorf("configureIncremental() error = %v", err)
+	}
+}
+
+func TestFullRunIsIncrementalBaseline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "extract-state.json")
+	app := newRetryTestApplication(0)
+	app.query = defaultQuery()
+	app.collection = entityCollections[defaultCollectionId]
+
+	// A full run records its query without being configured as incremental.
+	startedAt := time.Date(2023, time.February, 14, 10, 15, 2, 0, time.UTC)
+	if err := app.recordSuccessfulRun(path, &checkpoint{RunId: "full", StartedAt: startedAt}); err != nil {
+		t.Fatalf("recordSuccessfulRun() error = %v", err)
+	}
+
+	if err := app.configureIncremental(path); err != nil {
+		t.Fatalf("configureIncremental() error = %v", err)
+	}
+	last := app.query.Predicates[len(app.query.Predicates)-1]
+	if last.FieldId != "updated_at" || !reflect.DeepEqual(last.Values, []interface{}{"2023-02-14"}) {
+		t.Errorf("incremental predicate after a full run = %v, want updated_at >= 2023-02-14", last)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/erodrigufer/UVC_data_pipeline/internal/source"
+)
+
+// partialSuffix, suffix of an output file while the extraction is still
+// running. The suffix is removed by an atomic rename once the run finished.
+const partialSuffix = ".partial"
+
+// ndjsonWriter, writes documents as newline-delimited JSON (one
+// document per line) into a partial output file. Every page is synced to disk
+// right after it was written, so that a crash never loses already extracted
+// pages.
+type ndjsonWriter struct {
+	f *os.File
+	// path, final path of the output file, the partial file is stored at
+	// path + partialSuffix.
+	path string
+	// offset, size in bytes of the synced output, i.e. of all completely
+	// written pages.
+	offset int64
+	// count, number of documents written into the output file.
+	count int
+	// committed, true once the partial file was renamed to 'path'.
+	committed bool
+}
+
+// outputFileName, returns the name of the output file of a run.
+func outputFileName(runId string) string {
+	return fmt.Sprintf("./CBData_%s.ndjson", runId)
+}
+
+// createNDJSONWriter, creates a new partial output file for the final output
+// file 'path'. It fails if the partial file already exists.
+func createNDJSONWriter(path string) (*ndjsonWriter, error) {
+	f, err := os.OpenFile(path+partialSuffix, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create output file: %w", err)
+	}
+	return &ndjsonWriter{f: f, path: path}, nil
+}
+
+// openNDJSONWriter, reopens the partial output file of the final output file
+// 'path' to append further pages. The file is truncated to 'offset' bytes,
+// in order to drop a page which was written but never recorded in a
+// checkpoint, e.g. because the program crashed between both operations. The
+// output of an interrupted run, which was committed to 'path', becomes the
+// partial output file again.
+func openNDJSONWriter(path string, offset int64, count int) (*ndjsonWriter, error) {
+	if _, err := os.Stat(path + partialSuffix); errors.Is(err, os.ErrNotExist) {
+		if err := os.Rename(path, path+partialSuffix); err != nil {
+			return nil, fmt.Errorf("unable to reopen the output file of the interrupted run: %w", err)
+		}
+	}
+	f, err := os.OpenFile(path+partialSuffix, os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open output file: %w", err)
+	}
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to truncate output file to %d bytes: %w", offset, err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to seek to the end of the output file: %w", err)
+	}
+	return &ndjsonWriter{f: f, path: path, offset: offset, count: count}, nil
+}
+
+// writePage, appends all documents of a page to the output file and syncs
+// the file to disk.
+func (w *ndjsonWriter) writePage(documents []document) error {
+	buf := new(bytes.Buffer)
+	encoder := json.NewEncoder(buf)
+	for _, document := range documents {
+		// Encode appends a newline after each document.
+		if err := encoder.Encode(document); err != nil {
+			return fmt.Errorf("unable to encode document %s as JSON: %w", document.entityUuid(), err)
+		}
+	}
+
+	n, err := w.f.Write(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("unable to write page to output file: %w", err)
+	}
+	if err := w.f.Sync(); err != nil {
+		return fmt.Errorf("unable to sync output file to disk: %w", err)
+	}
+	w.offset += int64(n)
+	w.count += len(documents)
+
+	return nil
+}
+
+// partialPath, returns the path of the partial output file.
+func (w *ndjsonWriter) partialPath() string {
+	return w.path + partialSuffix
+}
+
+// entitiesWritten, returns the number of documents written into the output
+// file.
+func (w *ndjsonWriter) entitiesWritten() int {
+	return w.count
+}
+
+// storedUUIDs, reads the UUIDs of all documents written into the partial
+// output file.
+func (w *ndjsonWriter) storedUUIDs() (map[string]bool, error) {
+	uuids := map[string]bool{}
+	f, err := os.Open(w.partialPath())
+	if err != nil {
+		return nil, fmt.Errorf("unable to open output file: %w", err)
+	}
+	defer f.Close()
+
+	// Only the UUID of the documents is decoded.
+	decoder := json.NewDecoder(io.LimitReader(f, w.offset))
+	for {
+		var document struct {
+			Uuid string `json:"uuid"`
+		}
+		if err := decoder.Decode(&document); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to decode document %d of the output file: %w", len(uuids)+1, err)
+		}
+		uuids[document.Uuid] = true
+	}
+	return uuids, nil
+}
+
+// updateCheckpoint, records the size of the output file and the number of
+// written documents in a checkpoint.
+func (w *ndjsonWriter) updateCheckpoint(cp *checkpoint) {
+	cp.OutputOffset = w.offset
+	cp.EntitiesWritten = w.count
+}
+
+// location, returns the path of the partial output file, or the final path
+// once the output was committed.
+func (w *ndjsonWriter) location() string {
+	if w.committed {
+		return fmt.Sprintf("the file %s", w.path)
+	}
+	return fmt.Sprintf("the file %s", w.partialPath())
+}
+
+// close, closes the partial output file without renaming it, e.g. if the run
+// has to be resumed later on.
+func (w *ndjsonWriter) close() error {
+	if err := w.f.Close(); err != nil {
+		return fmt.Errorf("unable to close output file: %w", err)
+	}
+	return nil
+}
+
+// commit, closes the partial output file and atomically renames it to its
+// final path.
+func (w *ndjsonWriter) commit() error {
+	if err := w.close(); err != nil {
+		return err
+	}
+	if err := os.Rename(w.partialPath(), w.path); err != nil {
+		return fmt.Errorf("unable to rename output file to %s: %w", w.path, err)
+	}
+	w.committed = true
+	return nil
+}
+
+// unmarshalFile, decodes the JSON data from a file (par. fileData) and returns
+// the decoded documents, each document is created with 'newDocument', e.g. an
+// *OrganizationDocument. The file is either a JSON array of documents or
+// newline-delimited JSON (one document per line).
+func unmarshalFile(fileData []byte, newDocument func() document) ([]document, error) {
+	src, err := source.NewFile(fileData, pageSize, storedDocumentDecoder(newDocument))
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	documents := []document{}
+	for {
+		page, err := src.NextPage(context.Background())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, page...)
+	}
+	return documents, nil
+}
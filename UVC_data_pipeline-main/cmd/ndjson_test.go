@@ -0,0 +1,171 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalFile(t *testing.T) {
+	want := []document{&OrganizationDocument{Uuid: "1a", OrganizationName: "Blub.ai"}, &OrganizationDocument{Uuid: "2a", OrganizationName: "Thinkgate"}}
+
+	tests := []struct {
+		name         string
+		fileData     string
+		collectionId string
+		want         []document
+		wantErr      bool
+	}{
+		{
+			name:     "JSON array",
+			fileData: `[{"uuid":"1a","organizationName":"Blub.ai"},{"uuid":"2a","organizationName":"Thinkgate"}]`,
+			want:     want,
+			wantErr:  false,
+		},
+		{
+			name:     "NDJSON",
+			fileData: "{\"uuid\":\"1a\",\"organizationName\":\"Blub.ai\"}\n{\"uuid\":\"2a\",\"organizationName\":\"Thinkgate\"}\n",
+			want:     want,
+			wantErr:  false,
+		},
+		{
+			name:     "NDJSON without trailing newline and with empty lines",
+			fileData: "\n{\"uuid\":\"1a\",\"organizationName\":\"Blub.ai\"}\n\n{\"uuid\":\"2a\",\"organizationName\":\"Thinkgate\"}",
+			want:     want,
+			wantErr:  false,
+		},
+		{
+			name:     "NDJSON with a truncated line",
+			fileData: "{\"uuid\":\"1a\",\"organizationName\":\"Blub.ai\"}\n{\"uuid\":\"2a\",\"organi",
+			wantErr:  true,
+		},
+		{
+			name:         "NDJSON with funding rounds",
+			fileData:     "{\"uuid\":\"3f\",\"investmentType\":\"seed\"}\n",
+			collectionId: "funding_rounds",
+			want:         []document{&FundingRoundDocument{Uuid: "3f", InvestmentType: "seed"}},
+			wantErr:      false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collectionId := tt.collectionId
+			if collectionId == "" {
+				collectionId = defaultCollectionId
+			}
+			collection, err := lookupCollection(collectionId)
+			if err != nil {
+				t.Fatalf("lookupCollection() error = %v", err)
+			}
+			got, err := unmarshalFile([]byte(tt.fileData), collection.newDocument)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("unmarshalFile() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unmarshalFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNDJSONWriterResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "CBData_test.ndjson")
+
+	output, err := createNDJSONWriter(path)
+	if err != nil {
+		t.Fatalf("createNDJSONWriter() error = %v", err)
+	}
+	if err := output.writePage([]document{OrganizationDocument{Uuid: "1a"}, OrganizationDocument{Uuid: "2a"}}); err != nil {
+		t.Fatalf("writePage() error = %v", err)
+	}
+	// Offset and count as they would have been stored in a checkpoint.
+	offset, count := output.offset, output.count
+	// This page is written, but the program 'crashes' before the checkpoint
+	// is stored.
+	if err := output.writePage([]document{OrganizationDocument{Uuid: "3a"}}); err != nil {
+		t.Fatalf("writePage() error = %v", err)
+	}
+	if err := output.close(); err != nil {
+		t.Fatalf("close() error = %v", err)
+	}
+
+	// Resuming drops the page which was not recorded in the checkpoint.
+	output, err = openNDJSONWriter(path, offset, count)
+	if err != nil {
+		t.Fatalf("openNDJSONWriter() error = %v", err)
+	}
+	if err := output.writePage([]document{OrganizationDocument{Uuid: "3b"}}); err != nil {
+		t.Fatalf("writePage() error = %v", err)
+	}
+	if err := output.commit(); err != nil {
+		t.Fatalf("commit() error = %v", err)
+	}
+
+	if _, err := os.Stat(path + partialSuffix); !os.IsNotExist(err) {
+		t.Errorf("partial output file still exists after commit: %v", err)
+	}
+	fileData, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unable to read output file: %v", err)
+	}
+	got, err := unmarshalFile(fileData, entityCollections[defaultCollectionId].newDocument)
+	if err != nil {
+		t.Fatalf("unmarshalFile() error = %v", err)
+	}
+	want := []document{&OrganizationDocument{Uuid: "1a"}, &OrganizationDocument{Uuid: "2a"}, &OrganizationDocument{Uuid: "3b"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("output = %v, want %v", got, want)
+	}
+	if output.count != 3 {
+		t.Errorf("count = %d, want 3", output.count)
+	}
+}
+
+func TestNDJSONWriterInterrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "CBData_test.ndjson")
+
+	output, err := createNDJSONWriter(path)
+	if err != nil {
+		t.Fatalf("createNDJSONWriter() error = %v", err)
+	}
+	if err := output.writePage([]document{OrganizationDocument{Uuid: "1a"}}); err != nil {
+		t.Fatalf("writePage() error = %v", err)
+	}
+	offset, count := output.offset, output.count
+
+	// An interrupted run commits the entities retrieved so far.
+	app := newFixtureTestApplication()
+	err = app.interruptExtraction(&checkpoint{RunId: "test", LastUUID: "1a"}, output)
+	if err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("interruptExtraction() error = %v, want it to name %s", err, path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("output of the interrupted run is not stored at the output path: %v", err)
+	}
+
+	// Resuming the run continues the committed output file.
+	output, err = openNDJSONWriter(path, offset, count)
+	if err != nil {
+		t.Fatalf("openNDJSONWriter() error = %v", err)
+	}
+	if err := output.writePage([]document{OrganizationDocument{Uuid: "2a"}}); err != nil {
+		t.Fatalf("writePage() error = %v", err)
+	}
+	if err := output.commit(); err != nil {
+		t.Fatalf("commit() error = %v", err)
+	}
+	fileData, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unable to read output file: %v", err)
+	}
+	got, err := unmarshalFile(fileData, entityCollections[defaultCollectionId].newDocument)
+	if err != nil {
+		t.Fatalf("unmarshalFile() error = %v", err)
+	}
+	if want := []document{&OrganizationDocument{Uuid: "1a"}, &OrganizationDocument{Uuid: "2a"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("output = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// defaultMaxPartitionSize, default maximal number of entities extracted
+	// with a single search. A larger search is split into partitions, since
+	// paging with after_id over a huge result set tends to stall or to skip
+	// entities.
+	defaultMaxPartitionSize = 10000
+	// partitionMinYear, lower bound of the year ranges of partitions. The
+	// first partition also contains all entities before this year and the
+	// entities without a value in the partition field.
+	partitionMinYear = 1900
+)
+
+// partition, disjoint part of a query, defined by a range of years of the
+// collection's partition field, e.g. 'founded_on' in [2020, 2022).
+type partition struct {
+	// From, first year of the partition, 0 if the partition has no lower
+	// bound.
+	From int `json:"from,omitempty"`
+	// To, first year after the partition, 0 if the partition has no upper
+	// bound.
+	To int `json:"to,omitempty"`
+	// Count, number of entities matched by the partition according to the
+	// API.
+	Count int `json:"count"`
+	// Fetched, number of entities retrieved from the API by the last
+	// attempt, a retry starts counting again.
+	Fetched int `json:"fetched"`
+	// Written, number of retrieved entities which were not retrieved before
+	// (unique UUID) and were stored in the sink.
+	Written int `json:"written"`
+	// Quarantined, number of retrieved entities which could not be parsed
+	// and were stored in the quarantine file instead of the sink.
+	Quarantined int `json:"quarantined,omitempty"`
+	// Pages, number of pages retrieved from the API by the last attempt.
+	Pages int `json:"pages"`
+	// ShortPages, number of pages of the last attempt which returned fewer
+	// entities than requested, although the partition was not complete yet.
+	ShortPages int `json:"short_pages"`
+	// Retries, number of times the partition was extracted again from its
+	// first page, because it was incomplete.
+	Retries int `json:"retries,omitempty"`
+}
+
+// maxPartitionRetries, number of times an incomplete partition is extracted
+// again from its first page, before its entities are reported as missing.
+const maxPartitionRetries = 1
+
+// retry, prepares the partition to be extracted again from its first page.
+// The retrieved entities are counted again, since the retry receives the
+// entities of the first attempt once more. Written and Quarantined are kept,
+// the entities which were stored by the first attempt are not stored again.
+func (p *partition) retry() {
+	p.Retries++
+	p.Fetched = 0
+	p.Pages = 0
+	p.ShortPages = 0
+}
+
+// complete, returns true if all entities of the partition were either
+// stored or quarantined.
+func (p partition) complete() bool {
+	return p.Written+p.Quarantined >= p.Count
+}
+
+// String, human readable range of the partition.
+func (p partition) String() string {
+	switch {
+	case p.From == 0 && p.To == 0:
+		return "all entities"
+	case p.From == 0:
+		return fmt.Sprintf("before %d", p.To)
+	case p.To == 0:
+		return fmt.Sprintf("%d and later", p.From)
+	default:
+		return fmt.Sprintf("%d to %d", p.From, p.To-1)
+	}
+}
+
+// apply, returns a copy of 'query' restricted to the entities of the
+// partition, 'field' is the date field on which the query is partitioned.
+func (p partition) apply(query *Query, field string) *Query {
+	predicates := []QueryPredicate{}
+	if p.From != 0 {
+		predicates = append(predicates, QueryPredicate{
+			FieldId:    field,
+			OperatorId: "gte",
+			Values:     []interface{}{yearDate(p.From)},
+		})
+	}
+	if p.To != 0 {
+		predicate := QueryPredicate{
+			FieldId:    field,
+			OperatorId: "lt",
+			Values:     []interface{}{yearDate(p.To)},
+		}
+		// Entities without a value in the partition field belong to the
+		// first partition, otherwise no partition would contain them.
+		if p.From == 0 {
+			includeNulls := true
+			predicate.IncludeNulls = &includeNulls
+		}
+		predicates = append(predicates, predicate)
+	}
+	return query.withPredicates(predicates...)
+}
+
+// split, splits the partition into two halves, 'now' defines the upper bound
+// of the last partition. It returns false if the partition spans a single
+// year and cannot be split any further.
+func (p partition) split(now time.Time) (partition, partition, bool) {
+	from, to := p.From, p.To
+	if from == 0 {
+		from = partitionMinYear
+	}
+	if to == 0 {
+		to = now.Year() + 1
+	}
+	if to-from <= 1 {
+		return p, p, false
+	}
+	middle := (from + to) / 2
+	return partition{From: p.From, To: middle}, partition{From: middle, To: p.To}, true
+}
+
+// yearDate, returns the first day of 'year' as a date value of the API.
+func yearDate(year int) string {
+	return fmt.Sprintf("%d-01-01", year)
+}
+
+// partitionQuery, splits app.query into disjoint partitions of at most
+// app.userConfigurations.maxPartitionSize entities, 'totalCount' is the
+// number of entities of the whole query. Partitions are split in halves by
+// year ranges of the collection's partition field, until they are small
+// enough. Both halves of a split are counted by the API, so that the counts
+// of the partitions can be checked against the count of the whole query by
+// the reconciliation report (e.g. a gap or an overlap of the year ranges).
+func (app *application) partitionQuery(ctx context.Context, totalCount int, now time.Time) ([]partition, error) {
+	whole := partition{Count: totalCount}
+	maxSize := app.userConfigurations.maxPartitionSize
+	// The entities of a replay file cannot be filtered by the API.
+	if maxSize <= 0 || totalCount <= maxSize || app.replayFile != "" {
+		return []partition{whole}, nil
+	}
+	field := app.collection.partitionField
+	if field == "" {
+		app.errorLog.Printf("The query matches %d entities, but the collection '%s' cannot be partitioned, extracting it with a single search.", totalCount, app.collection.id)
+		return []partition{whole}, nil
+	}
+	app.infoLog.Printf("The query matches %d entities, splitting it into partitions of at most %d entities by %s.", totalCount, maxSize, field)
+
+	partitions := []partition{}
+	// Partitions which are still too large, processed in order so that the
+	// resulting partitions are sorted by year.
+	pending := []partition{whole}
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+
+		first, second, ok := current.split(now)
+		if current.Count <= maxSize || !ok {
+			if current.Count > maxSize {
+				app.errorLog.Printf("Partition %s matches %d entities and cannot be split any further.", current, current.Count)
+			}
+			partitions = append(partitions, current)
+			continue
+		}
+
+		for _, half := range []*partition{&first, &second} {
+			if err := app.pageDelay(ctx); err != nil {
+				return nil, err
+			}
+			count, err := app.getTotalCount(ctx, half.apply(app.query, field))
+			if err != nil {
+				return nil, fmt.Errorf("unable to count the entities of partition %s: %w", half, err)
+			}
+			half.Count = count
+		}
+		pending = append([]partition{first, second}, pending...)
+	}
+
+	app.infoLog.Printf("The query was split into %d partitions.", len(partitions))
+	return partitions, nil
+}
+
+// pageDelay, waits for a random delay between two requests to the API. It
+// returns an error if 'ctx' is cancelled while waiting. A max delay of 0
+// disables the delay, e.g. for a local fake of Crunchbase.
+func (app *application) pageDelay(ctx context.Context) error {
+	if app.userConfigurations.maxDelayExtract == 0 {
+		return nil
+	}
+	// Generate a random delay with a max and min delay constraints.
+	delay, err := app.calculateRandomDelay(app.userConfigurations.minDelayExtract, app.userConfigurations.maxDelayExtract)
+	if err != nil {
+		return fmt.Errorf("unable to create a random delay: %w", err)
+	}
+	app.infoLog.Printf("Delay until next API request: %ds\n", delay)
+	return sleepContext(ctx, time.Duration(delay)*time.Second)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPartitionSplit(t *testing.T) {
+	now := time.Date(2023, time.February, 14, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		partition  partition
+		wantFirst  partition
+		wantSecond partition
+		wantOk     bool
+	}{
+		{
+			name:       "Unbounded partition",
+			partition:  partition{},
+			wantFirst:  partition{To: 1962},
+			wantSecond: partition{From: 1962},
+			wantOk:     true,
+		},
+		{
+			name:       "Bounded partition",
+			partition:  partition{From: 2020, To: 2024},
+			wantFirst:  partition{From: 2020, To: 2022},
+			wantSecond: partition{From: 2022, To: 2024},
+			wantOk:     true,
+		},
+		{
+			name:       "Last partition",
+			partition:  partition{From: 2020},
+			wantFirst:  partition{From: 2020, To: 2022},
+			wantSecond: partition{From: 2022},
+			wantOk:     true,
+		},
+		{
+			name:      "Single year",
+			partition: partition{From: 2023},
+			wantOk:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, second, ok := tt.partition.split(now)
+			if ok != tt.wantOk {
+				t.Fatalf("split() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if ok && (first != tt.wantFirst || second != tt.wantSecond) {
+				t.Errorf("split() = %v, %v, want %v, %v", first, second, tt.wantFirst, tt.wantSecond)
+			}
+		})
+	}
+}
+
+func TestPartitionApply(t *testing.T) {
+	query := defaultQuery()
+	predicates := len(query.Predicates)
+
+	first := partition{To: 2022}.apply(query, "founded_on")
+	if len(first.Predicates) != predicates+1 {
+		t.Fatalf("apply() added %d predicates, want 1", len(first.Predicates)-predicates)
+	}
+	lower := first.Predicates[predicates]
+	if lower.OperatorId != "lt" || lower.IncludeNulls == nil || !*lower.IncludeNulls {
+		t.Errorf("first partition predicate = %v, want lt with include_nulls", lower)
+	}
+
+	middle := partition{From: 2020, To: 2022}.apply(query, "founded_on")
+	want := []QueryPredicate{
+		{FieldId: "founded_on", OperatorId: "gte", Values: []interface{}{"2020-01-01"}},
+		{FieldId: "founded_on", OperatorId: "lt", Values: []interface{}{"2022-01-01"}},
+	}
+	if got := middle.Predicates[predicates:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("middle partition predicates = %v, want %v", got, want)
+	}
+	if len(query.Predicates) != predicates {
+		t.Errorf("apply() modified the predicates of the original query")
+	}
+}
+
+func TestPartitionQuery(t *testing.T) {
+	now := time.Date(2023, time.February, 14, 10, 0, 0, 0, time.UTC)
+	// foundingYears, founding years of the organizations searched by the
+	// fake API, 0 if the founding date is unknown.
+	foundingYears := []int{0, 0, 1950, 1990, 2005, 2010, 2015, 2019, 2020, 2020, 2021, 2022}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := searchPayload{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		count := 0
+		for _, year := range foundingYears {
+			matches := true
+			for _, predicate := range payload.Query {
+				bound, _ := time.Parse(incrementalDateLayout, predicate.Values[0].(string))
+				switch {
+				case year == 0:
+					matches = matches && predicate.IncludeNulls != nil && *predicate.IncludeNulls
+				case predicate.OperatorId == "gte":
+					matches = matches && year >= bound.Year()
+				case predicate.OperatorId == "lt":
+					matches = matches && year < bound.Year()
+				}
+			}
+			if matches {
+				count++
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"count": count, "entities": []interface{}{}})
+	}))
+	defer server.Close()
+
+	app := newFixtureTestApplication()
+	app.cbBaseURL = server.URL
+	// The organizations matched by the query, without any predicate.
+	app.query = &Query{CollectionId: defaultCollectionId, FieldIds: defaultFieldIds}
+	app.userConfigurations.maxPartitionSize = 3
+
+	// The count of the whole query includes an organization which none of
+	// the partitions matches, e.g. because of a gap in the year ranges. It
+	// is only revealed if every partition is counted.
+	partitions, err := app.partitionQuery(context.Background(), len(foundingYears)+1, now)
+	if err != nil {
+		t.Fatalf("partitionQuery() error = %v", err)
+	}
+	total := 0
+	for _, p := range partitions {
+		// Every partition is counted by the API, its count is not derived
+		// from the count of the split partition.
+		want := 0
+		for _, year := range foundingYears {
+			if (p.From == 0 || (year != 0 && year >= p.From)) && (p.To == 0 || year < p.To) {
+				want++
+			}
+		}
+		if p.Count != want {
+			t.Errorf("partition %s count = %d, want %d", p, p.Count, want)
+		}
+		if p.Count > app.userConfigurations.maxPartitionSize {
+			t.Errorf("partition %s count = %d, want at most %d", p, p.Count, app.userConfigurations.maxPartitionSize)
+		}
+		total += p.Count
+	}
+	if total != len(foundingYears) {
+		t.Errorf("partition counts add up to %d, want %d", total, len(foundingYears))
+	}
+}
+
+func TestNDJSONWriterStoredUUIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "CBData_test.ndjson")
+	output, err := createNDJSONWriter(path)
+	if err != nil {
+		t.Fatalf("createNDJSONWriter() error = %v", err)
+	}
+	defer output.close()
+	if err := output.writePage([]document{OrganizationDocument{Uuid: "1a"}, OrganizationDocument{Uuid: "2a"}}); err != nil {
+		t.Fatalf("writePage() error = %v", err)
+	}
+
+	got, err := output.storedUUIDs()
+	if err != nil {
+		t.Fatalf("storedUUIDs() error = %v", err)
+	}
+	want := map[string]bool{"1a": true, "2a": true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("storedUUIDs() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// profilesDir, directory with the named query profiles. Each profile is a
+// query file (see docs/Query-Template.yaml) and its name is the file name
+// without extension, e.g. profiles/dach-deeptech.yaml is the profile
+// 'dach-deeptech'.
+const profilesDir = "./profiles"
+
+// profileExtensions, file extensions of query files that are recognised as
+// profiles.
+var profileExtensions = []string{".yaml", ".yml", ".json"}
+
+// listProfiles, returns the sorted names of all profiles found in dir.
+func listProfiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read profiles directory %s: %w", dir, err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !isProfileFile(entry.Name()) {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())))
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// loadProfile, loads the query of the profile 'name' from dir.
+func loadProfile(dir, name string) (*Query, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("invalid profile name '%s'", name)
+	}
+
+	for _, ext := range profileExtensions {
+		path := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		query, err := loadQuery(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load profile '%s': %w", name, err)
+		}
+		return query, nil
+	}
+
+	return nil, fmt.Errorf("profile '%s' not found in %s", name, dir)
+}
+
+// isProfileFile, returns true if the file name has the extension of a query
+// file.
+func isProfileFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, profileExt := range profileExtensions {
+		if ext == profileExt {
+			return true
+		}
+	}
+	return false
+}
+
+// printProfiles, prints the name and description of all available profiles.
+func (app *application) printProfiles() error {
+	names, err := listProfiles(profilesDir)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		fmt.Printf("No profiles found in %s.\n", profilesDir)
+		return nil
+	}
+
+	for _, name := range names {
+		query, err := loadProfile(profilesDir, name)
+		if err != nil {
+			// A broken profile should not hide all other profiles.
+			app.errorLog.Print(err)
+			fmt.Printf("%s -- INVALID\n", name)
+			continue
+		}
+		fmt.Printf("%s -- %s\n", name, query.Description)
+	}
+	return nil
+}
+
+// printProfile, prints the collection, order and predicates applied by the
+// profile 'name'.
+func (app *application) printProfile(name string) error {
+	query, err := loadProfile(profilesDir, name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Profile: %s\n", name)
+	if query.Description != "" {
+		fmt.Printf("Description: %s\n", query.Description)
+	}
+	fmt.Printf("Collection: %s\n", query.CollectionId)
+	fmt.Printf("Fields requested: %d\n", len(query.FieldIds))
+	fmt.Println("Order:")
+	for _, order := range query.Order {
+		fmt.Printf("  %s %s\n", order.FieldId, order.Sort)
+	}
+	fmt.Println("Predicates:")
+	for i, predicate := range query.Predicates {
+		fmt.Printf("  %d. %s\n", i+1, predicate)
+	}
+	return nil
+}
+
+// String, human readable representation of a predicate, e.g.
+// 'founded_on gte [2020] (include_nulls: false)'.
+func (p QueryPredicate) String() string {
+	s := fmt.Sprintf("%s %s", p.FieldId, p.OperatorId)
+	if len(p.Values) > 0 {
+		s = fmt.Sprintf("%s %v", s, p.Values)
+	}
+	if p.IncludeNulls != nil {
+		s = fmt.Sprintf("%s (include_nulls: %t)", s, *p.IncludeNulls)
+	}
+	return s
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestListProfiles(t *testing.T) {
+	got, err := listProfiles("../profiles")
+	if err != nil {
+		t.Fatalf("listProfiles() error = %v", err)
+	}
+	want := []string{"dach-deeptech", "na-europe", "seed-funding-rounds"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listProfiles() = %v, want %v", got, want)
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		wantErr bool
+	}{
+		{
+			name:    "Existing profile",
+			profile: "dach-deeptech",
+			wantErr: false,
+		},
+		{
+			name:    "Existing profile of another collection",
+			profile: "seed-funding-rounds",
+			wantErr: false,
+		},
+		{
+			name:    "Missing profile",
+			profile: "moon-startups",
+			wantErr: true,
+		},
+		{
+			name:    "Profile name with path separator",
+			profile: "../docs/Query-Template",
+			wantErr: true,
+		},
+		{
+			name:    "Empty profile name",
+			profile: "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadProfile("../profiles", tt.profile)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadProfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// The 'na-europe' profile must stay equivalent to the built-in default query.
+func TestNAEuropeProfileMatchesDefaultQuery(t *testing.T) {
+	got, err := loadProfile("../profiles", "na-europe")
+	if err != nil {
+		t.Fatalf("loadProfile() error = %v", err)
+	}
+	gotPayload, err := got.payload(1000, "")
+	if err != nil {
+		t.Fatalf("payload() error = %v", err)
+	}
+	wantPayload, err := defaultQuery().payload(1000, "")
+	if err != nil {
+		t.Fatalf("payload() error = %v", err)
+	}
+	if string(gotPayload) != string(wantPayload) {
+		t.Errorf("na-europe payload = %s, want %s", gotPayload, wantPayload)
+	}
+}
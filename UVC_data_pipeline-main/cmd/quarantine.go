@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// quarantinedEntity, entity of a page which could not be parsed into a
+// document. It is not stored in the sink, but in the quarantine file of the
+// run with its raw JSON and the reason, so that one odd entity does not stop
+// the extraction.
+type quarantinedEntity struct {
+	Uuid          string          `json:"uuid"`
+	Reason        string          `json:"reason"`
+	QuarantinedAt time.Time       `json:"quarantined_at"`
+	Raw           json.RawMessage `json:"raw"`
+}
+
+func (entity quarantinedEntity) entityUuid() string {
+	return entity.Uuid
+}
+
+// quarantineFileName, returns the name of the quarantine file of a run.
+func quarantineFileName(runId string) string {
+	return fmt.Sprintf("./CBData_%s.quarantine.ndjson", runId)
+}
+
+// decodeEntities, decodes the entities of a page one by one with 'parse'. An
+// entity which cannot be parsed is returned as a quarantinedEntity, only an
+// invalid page returns an error.
+func decodeEntities(payload []byte, parse func(raw json.RawMessage) (document, error)) ([]document, error) {
+	page := struct {
+		Entities []json.RawMessage `json:"entities"`
+	}{}
+	if err := json.Unmarshal(payload, &page); err != nil {
+		return nil, fmt.Errorf("unable to decode payload []byte into entities: %w", err)
+	}
+
+	documents := make([]document, 0, len(page.Entities))
+	for _, raw := range page.Entities {
+		document, err := parseEntity(raw, parse)
+		if err != nil {
+			documents = append(documents, newQuarantinedEntity(raw, err))
+			continue
+		}
+		documents = append(documents, document)
+	}
+	return documents, nil
+}
+
+// parseEntity, parses the entity 'raw' with 'parse', a panic while parsing
+// (e.g. an unexpected shape of the entity) is returned as an error.
+func parseEntity(raw json.RawMessage, parse func(raw json.RawMessage) (document, error)) (d document, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while parsing the entity: %v", r)
+		}
+	}()
+	return parse(raw)
+}
+
+// newQuarantinedEntity, returns the quarantinedEntity of the entity 'raw'
+// which could not be parsed because of 'reason'.
+func newQuarantinedEntity(raw json.RawMessage, reason error) quarantinedEntity {
+	// The UUID is needed for paging (after_id), so it is read even if the
+	// rest of the entity is invalid.
+	identifier := struct {
+		Uuid string `json:"uuid"`
+	}{}
+	json.Unmarshal(raw, &identifier)
+	return quarantinedEntity{
+		Uuid:          identifier.Uuid,
+		Reason:        reason.Error(),
+		QuarantinedAt: time.Now(),
+		Raw:           raw,
+	}
+}
+
+// splitQuarantined, splits a page into the parsed documents and the
+// quarantined entities.
+func splitQuarantined(page []document) ([]document, []quarantinedEntity) {
+	documents := make([]document, 0, len(page))
+	quarantined := []quarantinedEntity{}
+	for _, d := range page {
+		if entity, ok := d.(quarantinedEntity); ok {
+			quarantined = append(quarantined, entity)
+			continue
+		}
+		documents = append(documents, d)
+	}
+	return documents, quarantined
+}
+
+// markSeen, records the entity with the UUID 'uuid' in 'seen' and returns
+// true if it was already recorded. An entity without UUID (e.g. a quarantined
+// entity whose UUID could not be read) is never a duplicate of another one.
+func markSeen(seen map[string]bool, uuid string) bool {
+	if uuid == "" {
+		return false
+	}
+	if seen[uuid] {
+		return true
+	}
+	seen[uuid] = true
+	return false
+}
+
+// entityKey, returns the key of the entity with the UUID 'uuid' and the raw
+// JSON 'raw' in the set of seen entities: its UUID, or the hash of its raw
+// JSON if it has no UUID. An entity without UUID which is received again
+// (e.g. by the retry of a partition) is recognized, but two different
+// entities without UUID are not taken for the same one.
+func entityKey(uuid string, raw json.RawMessage) string {
+	if uuid != "" {
+		return uuid
+	}
+	sum := sha256.Sum256(raw)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// lastUUID, returns the UUID of the last entity of the page which has a UUID,
+// or an empty string if no entity of the page has one.
+func lastUUID(page []document) string {
+	for i := len(page) - 1; i >= 0; i-- {
+		if uuid := page[i].entityUuid(); uuid != "" {
+			return uuid
+		}
+	}
+	return ""
+}
+
+// appendQuarantine, appends the 'entities' as newline-delimited JSON to the
+// quarantine file at 'path' and syncs the file to disk. The file is created
+// if it does not exist, e.g. by the first quarantined entity of a run.
+func appendQuarantine(path string, entities []quarantinedEntity) error {
+	buf := new(bytes.Buffer)
+	encoder := json.NewEncoder(buf)
+	for _, entity := range entities {
+		if err := encoder.Encode(entity); err != nil {
+			return fmt.Errorf("unable to encode quarantined entity %s as JSON: %w", entity.Uuid, err)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("unable to open quarantine file: %w", err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write quarantine file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to sync quarantine file: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeOrganizationsQuarantine(t *testing.T) {
+	tests := []struct {
+		name   string
+		entity string
+		// wantReason, text contained in the reason of the quarantined
+		// entity, empty if the entity is parsed.
+		wantReason      string
+		wantCity        string
+		wantFoundedOn   Date
+		wantLastFunding Date
+	}{
+		{
+			name:            "Complete organization",
+			entity:          `{"uuid":"1a","properties":{"founded_on":{"value":"2019-04-01"},"last_funding_at":"2022-01-21","location_identifiers":[{"value":"Zurich","location_type":"city"},{"value":"Switzerland","location_type":"country"}]}}`,
+			wantCity:        "Zurich",
+			wantFoundedOn:   NewDate(time.Date(2019, time.April, 1, 0, 0, 0, 0, time.UTC), PrecisionDay),
+			wantLastFunding: NewDate(time.Date(2022, time.January, 21, 0, 0, 0, 0, time.UTC), PrecisionDay),
+		},
+		{
+			name:   "Without dates and locations",
+			entity: `{"uuid":"1a","properties":{"identifier":{"value":"Blub.ai"}}}`,
+		},
+		{
+			name:       "Invalid founding date",
+			entity:     `{"uuid":"1a","properties":{"founded_on":{"value":"01.04.2019"}}}`,
+			wantReason: "FoundedOn",
+		},
+		{
+			name:       "Invalid last funding date",
+			entity:     `{"uuid":"1a","properties":{"last_funding_at":"sometime"}}`,
+			wantReason: "LastFundingAtDate",
+		},
+		{
+			name:       "Unexpected type",
+			entity:     `{"uuid":"1a","properties":{"num_founders":"two"}}`,
+			wantReason: "unable to decode entity",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The odd entity is surrounded by valid ones, which must not be
+			// affected.
+			payload := `{"entities":[{"uuid":"0a"},` + tt.entity + `,{"uuid":"2a"}]}`
+			documents, err := decodeOrganizations([]byte(payload))
+			if err != nil {
+				t.Fatalf("decodeOrganizations() error = %v", err)
+			}
+			if len(documents) != 3 {
+				t.Fatalf("decodeOrganizations() returned %d documents, want 3", len(documents))
+			}
+			if documents[0].entityUuid() != "0a" || documents[2].entityUuid() != "2a" {
+				t.Errorf("decodeOrganizations() returned %s and %s around the entity, want 0a and 2a", documents[0].entityUuid(), documents[2].entityUuid())
+			}
+
+			switch d := documents[1].(type) {
+			case quarantinedEntity:
+				if tt.wantReason == "" {
+					t.Fatalf("entity was quarantined: %s", d.Reason)
+				}
+				if !strings.Contains(d.Reason, tt.wantReason) {
+					t.Errorf("reason = %q, want it to contain %q", d.Reason, tt.wantReason)
+				}
+				if d.Uuid != "1a" || string(d.Raw) != tt.entity {
+					t.Errorf("quarantined entity = %s %s, want 1a %s", d.Uuid, d.Raw, tt.entity)
+				}
+			case OrganizationDocument:
+				if tt.wantReason != "" {
+					t.Fatalf("entity was parsed, want it to be quarantined")
+				}
+				if d.City != tt.wantCity {
+					t.Errorf("City = %q, want %q", d.City, tt.wantCity)
+				}
+				if d.FoundedOn != tt.wantFoundedOn || d.LastFundingAt != tt.wantLastFunding {
+					t.Errorf("FoundedOn, LastFundingAt = %v, %v, want %v, %v", d.FoundedOn, d.LastFundingAt, tt.wantFoundedOn, tt.wantLastFunding)
+				}
+			default:
+				t.Fatalf("decodeOrganizations() returned a %T", d)
+			}
+		})
+	}
+}
+
+func TestMarkSeen(t *testing.T) {
+	seen := map[string]bool{"1a": true}
+	tests := []struct {
+		uuid string
+		want bool
+	}{
+		{"1a", true},
+		{"2a", false},
+		{"2a", true},
+		{"", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := markSeen(seen, tt.uuid); got != tt.want {
+			t.Errorf("markSeen(%q) = %v, want %v", tt.uuid, got, tt.want)
+		}
+	}
+}
+
+func TestLastUUID(t *testing.T) {
+	tests := []struct {
+		name string
+		page []document
+		want string
+	}{
+		{
+			name: "Last entity has a UUID",
+			page: []document{OrganizationDocument{Uuid: "1a"}, quarantinedEntity{Uuid: "2a"}},
+			want: "2a",
+		},
+		{
+			name: "Last entity has no UUID",
+			page: []document{OrganizationDocument{Uuid: "1a"}, quarantinedEntity{}},
+			want: "1a",
+		},
+		{
+			name: "No entity has a UUID",
+			page: []document{quarantinedEntity{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lastUUID(tt.page); got != tt.want {
+				t.Errorf("lastUUID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntityKey(t *testing.T) {
+	withoutUUID := entityKey("", []byte(`{"properties":[]}`))
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"Entity with UUID", entityKey("1a", []byte(`{"uuid":"1a"}`)), "1a"},
+		{"Entity without UUID received again", entityKey("", []byte(`{"properties":[]}`)), withoutUUID},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: entityKey() = %s, want %s", tt.name, tt.got, tt.want)
+		}
+	}
+	if other := entityKey("", []byte(`{"properties":[1]}`)); other == withoutUUID {
+		t.Errorf("entityKey() of two different entities without UUID = %s", other)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultCollectionId, Crunchbase collection queried if a query does not
+// define its own collection.
+const defaultCollectionId = "organization.companies"
+
+// defaultFieldIds, fields requested from the Crunchbase API for every
+// organization, if a query does not define its own list of fields.
+var defaultFieldIds = []string{"identifier", "operating_status", "founded_on", "ipo_status", "diversity_spotlights", "location_identifiers", "categories", "description", "last_funding_type", "investor_identifiers", "last_funding_at", "funding_total", "funding_stage", "investor_type", "last_equity_funding_type", "last_funding_total", "num_funding_rounds", "num_lead_investors", "num_investors", "semrush_visits_latest_month", "semrush_visits_latest_6_months_avg", "semrush_visits_mom_pct", "semrush_visit_duration", "semrush_visit_duration_mom_pct", "semrush_visit_pageviews", "semrush_visit_pageview_mom_pct", "semrush_bounce_rate", "semrush_bounce_rate_mom_pct", "semrush_global_rank", "semrush_global_rank_mom", "semrush_global_rank_mom_pct", "apptopia_total_apps", "apptopia_total_downloads", "num_founders", "founder_identifiers", "num_employees_enum", "investor_stage", "website", "linkedin", "num_articles", "hub_tags", "twitter", "facebook", "short_description", "contact_email", "last_key_employee_change_date", "last_layoff_date", "num_event_appearances", "rank_org_company", "num_contacts", "num_private_contacts", "builtwith_num_technologies_used", "siftery_num_products", "ipqwery_num_patent_granted", "ipqwery_num_trademark_registered", "private_tags", "num_private_notes"}
+
+// Query, declarative representation of a Crunchbase search. A Query can be
+// loaded from a YAML or JSON file, so that regions, funding stages or founding
+// years can be changed without editing and rebuilding the codebase.
+type Query struct {
+	// Description, human readable summary of the query, e.g. shown by the
+	// 'profiles list' command. It is not sent to the API.
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// FieldIds, fields requested for every entity in the response.
+	FieldIds []string `json:"field_ids" yaml:"field_ids"`
+	// Order, sort order of the results, required for after_id paging.
+	Order []QueryOrder `json:"order" yaml:"order"`
+	// Predicates, filters applied to the search.
+	Predicates []QueryPredicate `json:"predicates" yaml:"predicates"`
+	// CollectionId, Crunchbase collection being searched, e.g.
+	// 'organization.companies'.
+	CollectionId string `json:"collection_id" yaml:"collection_id"`
+	// IncrementalField, date field which is filtered by an incremental
+	// extraction, only entities with a newer value than the last successful
+	// run are requested. It defaults to the collection's updated field. It is
+	// not sent to the API.
+	IncrementalField string `json:"incremental_field,omitempty" yaml:"incremental_field,omitempty"`
+}
+
+// QueryOrder, sort order of a Crunchbase search on a single field.
+type QueryOrder struct {
+	FieldId string `json:"field_id" yaml:"field_id"`
+	// Sort, either 'asc' or 'desc'.
+	Sort string `json:"sort" yaml:"sort"`
+}
+
+// QueryPredicate, single filter of a Crunchbase search, e.g. all entities with
+// a 'founded_on' value greater or equal than 2020.
+type QueryPredicate struct {
+	FieldId    string `json:"field_id" yaml:"field_id"`
+	OperatorId string `json:"operator_id" yaml:"operator_id"`
+	// IncludeNulls, is a pointer so that a predicate without an explicit
+	// include_nulls value is sent to the API without it.
+	IncludeNulls *bool         `json:"include_nulls,omitempty" yaml:"include_nulls,omitempty"`
+	Values       []interface{} `json:"values,omitempty" yaml:"values,omitempty"`
+}
+
+// searchPredicate, JSON representation of a QueryPredicate expected by the
+// Crunchbase search API.
+type searchPredicate struct {
+	Type string `json:"type"`
+	QueryPredicate
+}
+
+// searchPayload, body of a POST request to the Crunchbase search API.
+type searchPayload struct {
+	FieldIds         []string          `json:"field_ids"`
+	Order            []QueryOrder      `json:"order"`
+	Query            []searchPredicate `json:"query"`
+	FieldAggregators []string          `json:"field_aggregators"`
+	CollectionId     string            `json:"collection_id"`
+	Limit            int               `json:"limit"`
+	AfterId          string            `json:"after_id"`
+}
+
+// defaultQuery, returns the UVC data team search: NA and Europe, seed to late
+// stage, founded in 2020 or later. It is used if no query file is provided.
+func defaultQuery() *Query {
+	includeNulls := false
+	return &Query{
+		Description: "NA and Europe, seed to late stage, founded 2020+.",
+		FieldIds:    defaultFieldIds,
+		Order:       []QueryOrder{{FieldId: "founded_on", Sort: "desc"}},
+		Predicates: []QueryPredicate{
+			{FieldId: "founded_on", OperatorId: "gte", IncludeNulls: &includeNulls, Values: []interface{}{"2020"}},
+			{FieldId: "operating_status", OperatorId: "includes", IncludeNulls: &includeNulls},
+			{FieldId: "location_identifiers", OperatorId: "includes", Values: []interface{}{"b25caef9-a1b8-3a5d-6232-93b2dfb6a1d1", "6106f5dc-823e-5da8-40d7-51612c0b2c4e"}},
+			{FieldId: "funding_stage", OperatorId: "includes", Values: []interface{}{"seed", "early_stage_venture", "late_stage_venture"}},
+		},
+		CollectionId: defaultCollectionId,
+	}
+}
+
+// loadQuery, loads a Query from a YAML (.yaml, .yml) or JSON (.json) file.
+// Fields missing in the file are set to their defaults, i.e. the default
+// collection_id and the default field_ids and order of the collection.
+func loadQuery(path string) (*Query, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read query file %s: %w", path, err)
+	}
+
+	query, err := parseQuery(data, filepath.Ext(path))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse query file %s: %w", path, err)
+	}
+
+	return query, nil
+}
+
+// parseQuery, decodes a Query from data, the format of data is defined by the
+// file extension ext. It fills in defaults and validates the decoded Query.
+func parseQuery(data []byte, ext string) (*Query, error) {
+	query := new(Query)
+
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, query); err != nil {
+			return nil, fmt.Errorf("unable to decode YAML query: %w", err)
+		}
+	case ".json":
+		if err := json.Unmarshal(data, query); err != nil {
+			return nil, fmt.Errorf("unable to decode JSON query: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported query file extension '%s', use .yaml, .yml or .json", ext)
+	}
+
+	if query.CollectionId == "" {
+		query.CollectionId = defaultCollectionId
+	}
+	collection, err := lookupCollection(query.CollectionId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
+	}
+	if len(query.FieldIds) == 0 {
+		query.FieldIds = collection.fieldIds
+	}
+	if len(query.Order) == 0 {
+		query.Order = collection.order
+	}
+
+	if err := query.validate(); err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
+	}
+
+	return query, nil
+}
+
+// validate, checks that a Query can be sent to the Crunchbase API.
+func (q *Query) validate() error {
+	if _, err := lookupCollection(q.CollectionId); err != nil {
+		return err
+	}
+	// Paging with after_id requires a deterministic sort order.
+	if len(q.Order) == 0 {
+		return fmt.Errorf("query has no order, at least one order field is required for paging")
+	}
+	for i, order := range q.Order {
+		if order.FieldId == "" {
+			return fmt.Errorf("order %d has no field_id", i)
+		}
+		if order.Sort != "asc" && order.Sort != "desc" {
+			return fmt.Errorf("order %d (%s) has an invalid sort '%s', use 'asc' or 'desc'", i, order.FieldId, order.Sort)
+		}
+	}
+	for i, predicate := range q.Predicates {
+		if predicate.FieldId == "" {
+			return fmt.Errorf("predicate %d has no field_id", i)
+		}
+		if predicate.OperatorId == "" {
+			return fmt.Errorf("predicate %d (%s) has no operator_id", i, predicate.FieldId)
+		}
+	}
+	return nil
+}
+
+// payload, encodes the Query as the JSON body of a search request. Parameters:
+// limit, number of entities requested. afterId, UUID of the last entity of the
+// previous page, an empty string requests the first page.
+func (q *Query) payload(limit int, afterId string) ([]byte, error) {
+	predicates := make([]searchPredicate, len(q.Predicates))
+	for i, predicate := range q.Predicates {
+		predicates[i] = searchPredicate{Type: "predicate", QueryPredicate: predicate}
+	}
+
+	body := searchPayload{
+		FieldIds:         q.FieldIds,
+		Order:            q.Order,
+		Query:            predicates,
+		FieldAggregators: []string{},
+		CollectionId:     q.CollectionId,
+		Limit:            limit,
+		AfterId:          afterId,
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode query as JSON payload: %w", err)
+	}
+	return data, nil
+}
+
+// withPredicates, returns a copy of the query with the additional predicates
+// 'predicates', the original query is not modified.
+func (q *Query) withPredicates(predicates ...QueryPredicate) *Query {
+	restricted := *q
+	restricted.Predicates = make([]QueryPredicate, 0, len(q.Predicates)+len(predicates))
+	restricted.Predicates = append(restricted.Predicates, q.Predicates...)
+	restricted.Predicates = append(restricted.Predicates, predicates...)
+	return &restricted
+}
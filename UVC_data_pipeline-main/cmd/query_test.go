@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		ext     string
+		wantErr bool
+	}{
+		{
+			name:    "YAML query",
+			data:    "order:\n  - field_id: founded_on\n    sort: desc\npredicates:\n  - field_id: funding_stage\n    operator_id: includes\n    values: [\"seed\"]\n",
+			ext:     ".yaml",
+			wantErr: false,
+		},
+		{
+			name:    "JSON query",
+			data:    `{"order":[{"field_id":"founded_on","sort":"asc"}],"predicates":[{"field_id":"funding_stage","operator_id":"includes","values":["seed"]}]}`,
+			ext:     ".json",
+			wantErr: false,
+		},
+		{
+			name:    "Unsupported file extension",
+			data:    `{"order":[{"field_id":"founded_on","sort":"asc"}]}`,
+			ext:     ".txt",
+			wantErr: true,
+		},
+		{
+			name:    "Query without order uses the default order",
+			data:    `{"predicates":[{"field_id":"funding_stage","operator_id":"includes","values":["seed"]}]}`,
+			ext:     ".json",
+			wantErr: false,
+		},
+		{
+			name:    "Unsupported collection",
+			data:    `{"collection_id":"organization.schools","order":[{"field_id":"founded_on","sort":"asc"}]}`,
+			ext:     ".json",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid sort direction",
+			data:    `{"order":[{"field_id":"founded_on","sort":"up"}]}`,
+			ext:     ".json",
+			wantErr: true,
+		},
+		{
+			name:    "Predicate without operator",
+			data:    `{"order":[{"field_id":"founded_on","sort":"asc"}],"predicates":[{"field_id":"funding_stage"}]}`,
+			ext:     ".json",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseQuery([]byte(tt.data), tt.ext)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseQuery() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			// Missing fields are filled in with the defaults.
+			if !reflect.DeepEqual(got.FieldIds, defaultFieldIds) {
+				t.Errorf("parseQuery() FieldIds = %v, want default field ids", got.FieldIds)
+			}
+			if got.CollectionId != defaultCollectionId {
+				t.Errorf("parseQuery() CollectionId = %s, want %s", got.CollectionId, defaultCollectionId)
+			}
+		})
+	}
+}
+
+func TestQueryPayload(t *testing.T) {
+	data, err := defaultQuery().payload(1000, "2bc5c89f-f222-41c9-afb6-876656f495ba")
+	if err != nil {
+		t.Fatalf("payload() error = %v", err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("payload() returned invalid JSON: %v", err)
+	}
+
+	if got["collection_id"] != "organization.companies" {
+		t.Errorf("payload() collection_id = %v", got["collection_id"])
+	}
+	if got["limit"] != float64(1000) {
+		t.Errorf("payload() limit = %v, want 1000", got["limit"])
+	}
+	if got["after_id"] != "2bc5c89f-f222-41c9-afb6-876656f495ba" {
+		t.Errorf("payload() after_id = %v", got["after_id"])
+	}
+	predicates, ok := got["query"].([]interface{})
+	if !ok || len(predicates) != 4 {
+		t.Fatalf("payload() query = %v, want 4 predicates", got["query"])
+	}
+	first := predicates[0].(map[string]interface{})
+	want := map[string]interface{}{
+		"type":          "predicate",
+		"field_id":      "founded_on",
+		"operator_id":   "gte",
+		"include_nulls": false,
+		"values":        []interface{}{"2020"},
+	}
+	if !reflect.DeepEqual(first, want) {
+		t.Errorf("payload() first predicate = %v, want %v", first, want)
+	}
+	// Predicates without include_nulls or values must not send them.
+	third := predicates[2].(map[string]interface{})
+	if _, ok := third["include_nulls"]; ok {
+		t.Errorf("payload() third predicate should not contain include_nulls: %v", third)
+	}
+}
+
+func TestLoadQueryTemplate(t *testing.T) {
+	query, err := loadQuery("../docs/Query-Template.yaml")
+	if err != nil {
+		t.Fatalf("loadQuery() error = %v", err)
+	}
+	if len(query.Predicates) != 5 {
+		t.Errorf("loadQuery() got %d predicates, want 5", len(query.Predicates))
+	}
+}
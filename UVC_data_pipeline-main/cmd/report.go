@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// errNotReconciled, returned by a complete extraction whose stored entities do
+// not match the count reported by the API, e.g. because the paging skipped
+// entities.
+var errNotReconciled = errors.New("the extracted entities do not reconcile with the count reported by the API")
+
+// errIncomplete, returned by an extraction which stopped before all pages were
+// retrieved, e.g. because it was interrupted or blocked by the API. The run
+// can be continued with --resume.
+var errIncomplete = errors.New("the extraction stopped before all entities were retrieved")
+
+// reconciliationReport, pagination integrity report of an extraction run.
+type reconciliationReport struct {
+	RunId        string `json:"run_id"`
+	Description  string `json:"description,omitempty"`
+	CollectionId string `json:"collection_id"`
+	// Complete, false if the run stopped before all pages were retrieved,
+	// e.g. because it was interrupted, blocked by the API or failed.
+	Complete bool `json:"complete"`
+	// StopReason, why an incomplete run stopped.
+	StopReason string `json:"stop_reason,omitempty"`
+	// TotalCount, number of entities matched by the whole query according to
+	// the API, before it was partitioned.
+	TotalCount int `json:"total_count,omitempty"`
+	// Expected, number of entities matched by the query according to the API,
+	// i.e. the sum of the counts of all partitions.
+	Expected int `json:"expected"`
+	// Fetched, number of entities retrieved, including duplicates.
+	Fetched int `json:"fetched"`
+	// Unique, number of entities with a unique UUID, i.e. stored in the sink.
+	Unique int `json:"unique"`
+	// Duplicates, number of entities retrieved more than once.
+	Duplicates int `json:"duplicates"`
+	// Quarantined, number of entities which could not be parsed, they are
+	// stored in the quarantine file instead of the sink.
+	Quarantined int `json:"quarantined"`
+	// QuarantineFile, path of the quarantine file, if any entity was
+	// quarantined.
+	QuarantineFile string `json:"quarantine_file,omitempty"`
+	// Missing, number of expected entities which were never retrieved, it is
+	// negative if more entities than expected were retrieved.
+	Missing int `json:"missing"`
+	// Pages, number of pages retrieved from the API.
+	Pages int `json:"pages"`
+	// ShortPages, number of pages which returned fewer entities than
+	// requested, although the partition was not complete yet.
+	ShortPages int `json:"short_pages"`
+	// Partitions, counts of every partition of the query.
+	Partitions []partition `json:"partitions"`
+	// Reconciled, true if the run is complete, the unique and the
+	// quarantined entities match the expected count of every partition, and
+	// the counts of the partitions add up to the total count of the query.
+	Reconciled bool `json:"reconciled"`
+}
+
+// reportFileName, returns the name of the reconciliation report of a run.
+func reportFileName(runId string) string {
+	return fmt.Sprintf("./CBData_%s.report.json", runId)
+}
+
+// newReconciliationReport, returns the reconciliation report of the complete
+// run of the checkpoint 'cp' of the query 'query'.
+func newReconciliationReport(cp *checkpoint, query *Query) reconciliationReport {
+	report := reconciliationReport{
+		RunId:        cp.RunId,
+		Description:  query.Description,
+		CollectionId: query.CollectionId,
+		Complete:     true,
+		TotalCount:   cp.TotalCount,
+		Partitions:   cp.Partitions,
+		Reconciled:   true,
+	}
+	for _, p := range cp.Partitions {
+		report.Expected += p.Count
+		report.Fetched += p.Fetched
+		report.Unique += p.Written
+		report.Quarantined += p.Quarantined
+		report.Pages += p.Pages
+		report.ShortPages += p.ShortPages
+		if p.Written+p.Quarantined != p.Count {
+			report.Reconciled = false
+		}
+	}
+	// The partitions overlap or do not cover the whole query.
+	if report.TotalCount > 0 && report.Expected != report.TotalCount {
+		report.Reconciled = false
+	}
+	if report.Quarantined > 0 {
+		report.QuarantineFile = quarantineFileName(cp.RunId)
+	}
+	report.Duplicates = report.Fetched - report.Unique - report.Quarantined
+	report.Missing = report.Expected - report.Unique - report.Quarantined
+	return report
+}
+
+// newIncompleteReport, returns the reconciliation report of the run of the
+// checkpoint 'cp' of the query 'query', which stopped before it was complete
+// because of 'stopErr'.
+func newIncompleteReport(cp *checkpoint, query *Query, stopErr error) reconciliationReport {
+	report := newReconciliationReport(cp, query)
+	report.Complete = false
+	report.Reconciled = false
+	if stopErr != nil {
+		report.StopReason = stopErr.Error()
+	}
+	return report
+}
+
+// save, stores the report as JSON at 'path'.
+func (report reconciliationReport) save(path string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode reconciliation report as JSON: %w", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("unable to store reconciliation report: %w", err)
+	}
+	return nil
+}
+
+// logReport, prints a summary of the report and the partitions which do not
+// reconcile.
+func (app *application) logReport(report reconciliationReport) {
+	if !report.Complete {
+		app.errorLog.Printf("Run %s stopped before it was complete, %d of %d expected entities were retrieved so far, check the report %s.", report.RunId, report.Unique+report.Quarantined, report.Expected, reportFileName(report.RunId))
+		return
+	}
+	app.infoLog.Printf("Reconciliation of run %s: expected %d, unique %d, duplicates %d, quarantined %d, missing %d, pages %d (%d short pages).", report.RunId, report.Expected, report.Unique, report.Duplicates, report.Quarantined, report.Missing, report.Pages, report.ShortPages)
+	if report.Quarantined > 0 {
+		app.errorLog.Printf("%d entities could not be parsed and were skipped, they are stored with the reason in %s.", report.Quarantined, report.QuarantineFile)
+	}
+	if report.TotalCount > 0 && report.Expected != report.TotalCount {
+		app.errorLog.Printf("The counts of the partitions add up to %d entities, but the API reported %d entities for the whole query.", report.Expected, report.TotalCount)
+	}
+	for _, p := range report.Partitions {
+		if p.Written+p.Quarantined != p.Count {
+			app.errorLog.Printf("Partition %s does not reconcile: the API reported %d entities, %d unique entities were retrieved (%d in total, %d quarantined).", p, p.Count, p.Written, p.Fetched, p.Quarantined)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+func TestNewReconciliationReport(t *testing.T) {
+	tests := []struct {
+		name           string
+		totalCount     int
+		partitions     []partition
+		wantDuplicates int
+		wantMissing    int
+		wantReconciled bool
+	}{
+		{
+			name:           "Complete run",
+			totalCount:     1510,
+			partitions:     []partition{{To: 2020, Count: 1500, Fetched: 1500, Written: 1500, Pages: 2}, {From: 2020, Count: 10, Fetched: 10, Written: 10, Pages: 1}},
+			wantDuplicates: 0,
+			wantMissing:    0,
+			wantReconciled: true,
+		},
+		{
+			name:           "Paging returned duplicates and skipped entities",
+			partitions:     []partition{{Count: 2000, Fetched: 2000, Written: 1990, Pages: 2}},
+			wantDuplicates: 10,
+			wantMissing:    10,
+			wantReconciled: false,
+		},
+		{
+			name:           "Paging stopped early in one partition",
+			partitions:     []partition{{To: 2020, Count: 1500, Fetched: 1500, Written: 1500, Pages: 2}, {From: 2020, Count: 1200, Fetched: 1000, Written: 1000, Pages: 1}},
+			wantDuplicates: 0,
+			wantMissing:    200,
+			wantReconciled: false,
+		},
+		{
+			name:           "Quarantined entities are not missing",
+			partitions:     []partition{{Count: 1000, Fetched: 1000, Written: 997, Quarantined: 3, Pages: 1}},
+			wantDuplicates: 0,
+			wantMissing:    0,
+			wantReconciled: true,
+		},
+		{
+			name:           "Partition counts do not add up to the total count",
+			totalCount:     1520,
+			partitions:     []partition{{To: 2020, Count: 1500, Fetched: 1500, Written: 1500, Pages: 2}, {From: 2020, Count: 10, Fetched: 10, Written: 10, Pages: 1}},
+			wantDuplicates: 0,
+			wantMissing:    0,
+			wantReconciled: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := newReconciliationReport(&checkpoint{RunId: "run1", TotalCount: tt.totalCount, Partitions: tt.partitions}, defaultQuery())
+			if report.Duplicates != tt.wantDuplicates {
+				t.Errorf("Duplicates = %d, want %d", report.Duplicates, tt.wantDuplicates)
+			}
+			if report.Missing != tt.wantMissing {
+				t.Errorf("Missing = %d, want %d", report.Missing, tt.wantMissing)
+			}
+			if report.Reconciled != tt.wantReconciled {
+				t.Errorf("Reconciled = %v, want %v", report.Reconciled, tt.wantReconciled)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// reprocessOptions, options of the 'reprocess' command.
+type reprocessOptions struct {
+	// runId, ID of the run whose raw archive is reprocessed.
+	runId string
+	// archive, path of the raw archive, the archive of the run in the
+	// working directory if it is empty.
+	archive string
+	// sink, sink in which the documents are stored again, see documentSink.
+	sink   string
+	remote string
+}
+
+// setupReprocessCommands, connects to the db if the documents are stored in
+// the mongo sink. A reprocessing never sends requests to Crunchbase.
+func (app *application) setupReprocessCommands(opts reprocessOptions) error {
+	switch opts.sink {
+	case sinkFile:
+	case sinkMongo:
+		if opts.remote == "" {
+			return fmt.Errorf("--remote flag missing: the mongo sink requires the IP address of the MongoDB instance")
+		}
+		if err := app.setupDBCommands(opts.remote); err != nil {
+			return fmt.Errorf("error while configuring the mongo sink: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown sink '%s', use '%s' or '%s'", opts.sink, sinkFile, sinkMongo)
+	}
+	return nil
+}
+
+// reprocess, parses the raw entities archived by the run 'opts.runId' again
+// with the current parser of their collection, and stores the documents in
+// the sink 'opts.sink': the output file of the run is replaced or the
+// documents are upserted into the collection. Entities archived more than
+// once are only stored once, entities which cannot be parsed are stored in
+// the quarantine file of the run, which replaces the previous one.
+func (app *application) reprocess(opts reprocessOptions) error {
+	path := opts.archive
+	if path == "" {
+		path = archiveFileName(opts.runId)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("no raw archive of run %s: %w", opts.runId, err)
+	}
+	app.infoLog.Printf("Reprocessing the raw entities of run %s archived in %s.", opts.runId, path)
+
+	var output documentSink
+	// closeOutput, releases the sink after a failure, a partial output file
+	// is removed, so that the run can be reprocessed again.
+	closeOutput := func() {
+		if output == nil {
+			return
+		}
+		output.close()
+		if opts.sink == sinkFile {
+			os.Remove(outputFileName(opts.runId) + partialSuffix)
+		}
+	}
+
+	// collection, collection of the archived entities, the collection of
+	// the first entity.
+	var collection *entityCollection
+	seen := map[string]bool{}
+	page := make([]document, 0, pageSize)
+	quarantined := []quarantinedEntity{}
+	archived, duplicates := 0, 0
+	// writePage, stores the documents of 'page', the sink is created with
+	// the first page.
+	writePage := func() error {
+		if len(page) == 0 {
+			return nil
+		}
+		if output == nil {
+			var err error
+			output, err = app.newSink(opts.sink, &checkpoint{RunId: opts.runId, OutputFile: outputFileName(opts.runId)}, false)
+			if err != nil {
+				return err
+			}
+		}
+		if err := output.writePage(page); err != nil {
+			return fmt.Errorf("unable to store page in %s: %w", output.location(), err)
+		}
+		page = page[:0]
+		return nil
+	}
+
+	err := readRawArchive(path, func(entity archivedEntity) error {
+		archived++
+		if entity.RunId != opts.runId {
+			return fmt.Errorf("entity %s of the archive was extracted by run %s, not by run %s", entity.Uuid, entity.RunId, opts.runId)
+		}
+		if collection == nil {
+			var err error
+			if collection, err = lookupCollection(entity.CollectionId); err != nil {
+				return err
+			}
+			// The mongo sink stores the documents in the collection's
+			// MongoDB collection.
+			app.collection = collection
+		}
+		if entity.CollectionId != collection.id {
+			return fmt.Errorf("entity %s of the archive belongs to the collection %s, not to %s", entity.Uuid, entity.CollectionId, collection.id)
+		}
+		if markSeen(seen, entityKey(entity.Uuid, entity.Entity)) {
+			duplicates++
+			return nil
+		}
+
+		d, err := parseEntity(entity.Entity, collection.parse)
+		if err != nil {
+			quarantined = append(quarantined, newQuarantinedEntity(entity.Entity, err))
+			return nil
+		}
+		page = append(page, d)
+		if len(page) < pageSize {
+			return nil
+		}
+		return writePage()
+	})
+	if err == nil {
+		err = writePage()
+	}
+	if err != nil {
+		closeOutput()
+		return fmt.Errorf("unable to reprocess run %s: %w", opts.runId, err)
+	}
+	if output == nil {
+		return fmt.Errorf("the raw archive %s of run %s does not contain any entity that can be parsed", path, opts.runId)
+	}
+	// The quarantine file is only replaced once the output is committed, so
+	// that a failed reprocessing keeps the output and the quarantine file of
+	// the same parser.
+	if err := output.commit(); err != nil {
+		closeOutput()
+		return err
+	}
+	if err := replaceQuarantine(quarantineFileName(opts.runId), quarantined); err != nil {
+		return fmt.Errorf("the documents of run %s were stored in %s, but its quarantine file was not replaced: %w", opts.runId, output.location(), err)
+	}
+
+	app.infoLog.Printf("%d documents of run %s were rebuilt from %d archived entities (%d duplicates, %d quarantined) and stored in %s.", output.entitiesWritten(), opts.runId, archived, duplicates, len(quarantined), output.location())
+	if len(quarantined) > 0 {
+		app.errorLog.Printf("%d entities could not be parsed and were skipped, they are stored with the reason in %s.", len(quarantined), quarantineFileName(opts.runId))
+	}
+	return nil
+}
+
+// replaceQuarantine, replaces the quarantine file at 'path' with the
+// 'entities', the file is removed if there are no entities.
+func replaceQuarantine(path string, entities []quarantinedEntity) error {
+	if len(entities) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("unable to remove quarantine file: %w", err)
+		}
+		return nil
+	}
+	buf := new(bytes.Buffer)
+	encoder := json.NewEncoder(buf)
+	for _, entity := range entities {
+		if err := encoder.Encode(entity); err != nil {
+			return fmt.Errorf("unable to encode quarantined entity %s as JSON: %w", entity.Uuid, err)
+		}
+	}
+	if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+		return fmt.Errorf("unable to store quarantine file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erodrigufer/UVC_data_pipeline/internal/cbfake"
+)
+
+func TestReprocess(t *testing.T) {
+	// The archive, the output file and the quarantine file are stored in the
+	// working directory.
+	chdirTemp(t)
+
+	server := cbfake.NewServer(cbfake.Config{Entities: 1200, MalformedEvery: 100})
+	defer server.Close()
+	if status := runFakeCLI(t, server, "extract", "--no-proxy"); status != 0 {
+		t.Fatalf("extract exit status = %d, want 0", status)
+	}
+	archives, _ := filepath.Glob("CBData_*.raw.ndjson.gz")
+	if len(archives) != 1 {
+		t.Fatalf("found raw archives %v, want exactly one", archives)
+	}
+	runId := strings.TrimSuffix(strings.TrimPrefix(archives[0], "CBData_"), ".raw.ndjson.gz")
+	searches := server.Searches()
+
+	tests := []struct {
+		name string
+		// prepare, changes the files of the run before the reprocessing.
+		prepare         func(t *testing.T)
+		args            []string
+		wantStatus      int
+		wantDocuments   int
+		wantQuarantined int
+	}{
+		{
+			name: "Rebuilds the output file",
+			prepare: func(t *testing.T) {
+				os.Remove(outputFileName(runId))
+				os.Remove(quarantineFileName(runId))
+			},
+			args:            []string{"reprocess", "--run", runId},
+			wantDocuments:   1188,
+			wantQuarantined: 12,
+		},
+		{
+			name: "Replaces the output file",
+			prepare: func(t *testing.T) {
+				if err := os.WriteFile(outputFileName(runId), []byte(`{"uuid":"outdated"}`+"\n"), 0640); err != nil {
+					t.Fatal(err)
+				}
+			},
+			args:            []string{"reprocess", "--run", runId},
+			wantDocuments:   1188,
+			wantQuarantined: 12,
+		},
+		{
+			name:            "Unknown run",
+			args:            []string{"reprocess", "--run", "20230214T101502Z-000000"},
+			wantStatus:      1,
+			wantDocuments:   1188,
+			wantQuarantined: 12,
+		},
+		{
+			name:            "Archive of another run",
+			args:            []string{"reprocess", "--run", "20230214T101502Z-000000", "--archive", archives[0]},
+			wantStatus:      1,
+			wantDocuments:   1188,
+			wantQuarantined: 12,
+		},
+		{
+			name:            "Mongo sink without remote",
+			args:            []string{"reprocess", "--run", runId, "--sink", "mongo"},
+			wantStatus:      1,
+			wantDocuments:   1188,
+			wantQuarantined: 12,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepare != nil {
+				tt.prepare(t)
+			}
+			if status := runFakeCLI(t, server, tt.args...); status != tt.wantStatus {
+				t.Fatalf("exit status = %d, want %d", status, tt.wantStatus)
+			}
+			if got := extractedDocuments(t); got != tt.wantDocuments {
+				t.Errorf("output contains %d documents, want %d", got, tt.wantDocuments)
+			}
+			if got := quarantinedEntities(t); got != tt.wantQuarantined {
+				t.Errorf("quarantine file contains %d entities, want %d", got, tt.wantQuarantined)
+			}
+			// A reprocessing never requests Crunchbase.
+			if server.Searches() != searches {
+				t.Errorf("reprocess sent %d search requests, want none", server.Searches()-searches)
+			}
+		})
+	}
+}
+
+func TestReprocessDuplicates(t *testing.T) {
+	chdirTemp(t)
+
+	runId := "20230214T101502Z-8f3a1c"
+	archive, err := createRawArchive(archiveFileName(runId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The paging returned the entity 2a twice. The invalid entities without
+	// UUID are different entities, which are both quarantined.
+	pages := []string{
+		`{"entities":[{"uuid":"1a","properties":{"identifier":{"value":"Blub.ai"}}},{"uuid":"2a","properties":{}}]}`,
+		`{"entities":[{"uuid":"2a","properties":{}},{"uuid":"3a","properties":{"founded_on":{"value":"2021-01-01","precision":"year"}}}]}`,
+		`{"entities":[{"properties":[]},{"properties":[1]}]}`,
+	}
+	for _, page := range pages {
+		archive.receive([]byte(page))
+		if err := archive.writePage(runId, defaultCollectionId); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archive.close()
+
+	app := newFixtureTestApplication()
+	if err := app.reprocess(reprocessOptions{runId: runId, sink: sinkFile}); err != nil {
+		t.Fatalf("reprocess() error = %v", err)
+	}
+	fileData, err := os.ReadFile(outputFileName(runId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	documents, err := unmarshalFile(fileData, entityCollections[defaultCollectionId].newDocument)
+	if err != nil {
+		t.Fatalf("unmarshalFile() error = %v", err)
+	}
+	uuids := []string{}
+	for _, d := range documents {
+		uuids = append(uuids, d.entityUuid())
+	}
+	if strings.Join(uuids, ",") != "1a,2a,3a" {
+		t.Errorf("output contains %v, want [1a 2a 3a]", uuids)
+	}
+	if got := quarantinedEntities(t); got != 2 {
+		t.Errorf("quarantine file contains %d entities, want 2", got)
+	}
+	if founded := documents[2].(*OrganizationDocument).FoundedOn.String(); founded != "2021" {
+		t.Errorf("FoundedOn of 3a = %s, want 2021", founded)
+	}
+}
+
+func TestReprocessFailedCommit(t *testing.T) {
+	chdirTemp(t)
+
+	runId := "20230214T101502Z-8f3a1c"
+	archive, err := createRawArchive(archiveFileName(runId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive.receive([]byte(`{"entities":[{"uuid":"1a","properties":{}},{"uuid":"2a","properties":[]}]}`))
+	if err := archive.writePage(runId, defaultCollectionId); err != nil {
+		t.Fatal(err)
+	}
+	archive.close()
+
+	// The quarantine file of the last extraction, and an output path which
+	// cannot be replaced.
+	previous := []byte(`{"uuid":"previous"}` + "\n")
+	if err := os.WriteFile(quarantineFileName(runId), previous, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(outputFileName(runId), "blocked"), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	app := newFixtureTestApplication()
+	if err := app.reprocess(reprocessOptions{runId: runId, sink: sinkFile}); err == nil {
+		t.Fatalf("reprocess() returned no error, although the output could not be committed")
+	}
+	got, err := os.ReadFile(quarantineFileName(runId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(previous) {
+		t.Errorf("quarantine file = %s, want the quarantine file of the last extraction", got)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultMaxRetries, default number of times a failed request to the
+	// Crunchbase API is retried.
+	defaultMaxRetries = 4
+	// defaultBaseBackoff, default delay (in s) before the first retry, the
+	// delay doubles with every further retry.
+	defaultBaseBackoff = 30
+	// defaultMaxBackoff, default cap (in s) of the delay between two retries.
+	defaultMaxBackoff = 600
+)
+
+// isRetryableStatus, returns true if a response with the HTTP status code
+// 'code' is worth retrying: too many requests (429) or a server error (5xx).
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// parseRetryAfter, parses the value of a Retry-After HTTP header, which is
+// either an amount of seconds or an HTTP date. It returns false if the header
+// is missing or invalid.
+func parseRetryAfter(header string, now time.Time) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		delay := date.Sub(now)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// backoff, returns the delay before the retry number 'retry' (starting at 1).
+// The delay grows exponentially from the base backoff up to the max backoff,
+// with a random jitter of up to half of the delay, so that retries look less
+// like a bot. A Retry-After value sent by the API is always honoured.
+func (app *application) backoff(retry int, retryAfter time.Duration) time.Duration {
+	base := time.Duration(app.userConfigurations.baseBackoff) * time.Second
+	max := time.Duration(app.userConfigurations.maxBackoff) * time.Second
+
+	delay := base
+	for i := 1; i < retry && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	if delay > 0 {
+		// Jitter: a random delay in [delay/2, delay].
+		delay = delay/2 + time.Duration(app.seededRand.Int63n(int64(delay/2)+1))
+	}
+
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+	return delay
+}
+
+// doWithRetry, sends the request created by 'newRequest' through app.client
+// and reads the response's body. Transport errors, 429 and 5xx responses are
+// retried up to app.userConfigurations.maxRetries times with an exponential
+// backoff. Each attempt gets its own 'timeout' context derived from 'ctx', if
+// 'ctx' is cancelled no further attempts are made. The parameter 'name'
+// identifies the request in log and error messages, e.g. 'extract'.
+// The returned response's body is already closed, its content is returned as
+// []byte. The response of the last attempt is returned, even if its status
+// code is not successful, the caller has to check the status code.
+func (app *application) doWithRetry(ctx context.Context, name string, timeout time.Duration, newRequest func(ctx context.Context) (*http.Request, error)) (*http.Response, []byte, error) {
+	maxAttempts := app.userConfigurations.maxRetries + 1
+	for attempt := 1; ; attempt++ {
+		res, body, err := app.doAttempt(ctx, timeout, newRequest)
+		// The root context was cancelled (e.g. Ctrl+C), do not retry.
+		if ctx.Err() != nil {
+			return nil, nil, fmt.Errorf("HTTP request (%s) was cancelled: %w", name, ctx.Err())
+		}
+
+		// Decide if the attempt should be retried.
+		var retryAfter time.Duration
+		switch {
+		case err != nil:
+			err = fmt.Errorf("attempt %d/%d of HTTP request (%s) failed: %w", attempt, maxAttempts, name, err)
+		case isRetryableStatus(res.StatusCode):
+			retryAfter, _ = parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+			app.errorLog.Printf("attempt %d/%d of HTTP request (%s) failed with status code %d (%s).", attempt, maxAttempts, name, res.StatusCode, res.Status)
+		default:
+			return res, body, nil
+		}
+
+		if attempt >= maxAttempts {
+			if err != nil {
+				return nil, nil, err
+			}
+			// Return the last response, so that the caller can report the
+			// status code.
+			return res, body, nil
+		}
+		if err != nil {
+			app.errorLog.Print(err)
+		}
+
+		delay := app.backoff(attempt, retryAfter)
+		app.infoLog.Printf("Retrying HTTP request (%s) in %s (retry %d/%d).", name, delay.Round(time.Second), attempt, maxAttempts-1)
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, nil, fmt.Errorf("HTTP request (%s) was cancelled while waiting for a retry: %w", name, err)
+		}
+	}
+}
+
+// doAttempt, sends a single request created by 'newRequest' with a timeout
+// context derived from 'parent' and reads the response's body.
+func (app *application) doAttempt(parent context.Context, timeout time.Duration, newRequest func(ctx context.Context) (*http.Request, error)) (*http.Response, []byte, error) {
+	// Configure a timeout for the client's HTTP request. If the request takes
+	// more than this time duration, then it should be cancelled.
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	// Cancelling a context releases resources associated with it,
+	// cancel should be call as soon as the operations running in a context
+	// complete.
+	defer cancel()
+
+	req, err := newRequest(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to create HTTP request: %w", err)
+	}
+
+	res, err := app.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to send HTTP request: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read response's body: %w", err)
+	}
+
+	return res, body, nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, time.February, 14, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+		wantOk bool
+	}{
+		{
+			name:   "Missing header",
+			header: "",
+			want:   0,
+			wantOk: false,
+		},
+		{
+			name:   "Seconds",
+			header: "120",
+			want:   2 * time.Minute,
+			wantOk: true,
+		},
+		{
+			name:   "Negative seconds",
+			header: "-1",
+			want:   0,
+			wantOk: false,
+		},
+		{
+			name:   "HTTP date",
+			header: "Tue, 14 Feb 2023 10:00:30 GMT",
+			want:   30 * time.Second,
+			wantOk: true,
+		},
+		{
+			name:   "HTTP date in the past",
+			header: "Tue, 14 Feb 2023 09:00:00 GMT",
+			want:   0,
+			wantOk: true,
+		},
+		{
+			name:   "Invalid value",
+			header: "soon",
+			want:   0,
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.header, now)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("parseRetryAfter() = %v, %t, want %v, %t", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	app := newRetryTestApplication(4)
+	app.userConfigurations.baseBackoff = 10
+	app.userConfigurations.maxBackoff = 60
+
+	tests := []struct {
+		name       string
+		retry      int
+		retryAfter time.Duration
+		min        time.Duration
+		max        time.Duration
+	}{
+		{name: "First retry", retry: 1, min: 5 * time.Second, max: 10 * time.Second},
+		{name: "Third retry", retry: 3, min: 20 * time.Second, max: 40 * time.Second},
+		{name: "Capped retry", retry: 10, min: 30 * time.Second, max: 60 * time.Second},
+		{name: "Retry-After longer than the cap", retry: 1, retryAfter: 5 * time.Minute, min: 5 * time.Minute, max: 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := app.backoff(tt.retry, tt.retryAfter)
+			if got < tt.min || got > tt.max {
+				t.Errorf("backoff() = %v, want a delay in [%v, %v]", got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestDoWithRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		statusCodes  []int
+		maxRetries   int
+		wantStatus   int
+		wantRequests int
+	}{
+		{
+			name:         "Success after a 503 and a 429",
+			statusCodes:  []int{503, 429, 200},
+			maxRetries:   4,
+			wantStatus:   200,
+			wantRequests: 3,
+		},
+		{
+			name:         "Retries exhausted",
+			statusCodes:  []int{503, 503, 503},
+			maxRetries:   2,
+			wantStatus:   503,
+			wantRequests: 3,
+		},
+		{
+			name:         "Client errors are not retried",
+			statusCodes:  []int{401, 200},
+			maxRetries:   4,
+			wantStatus:   401,
+			wantRequests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(tt.statusCodes[requests])
+				requests++
+			}))
+			defer server.Close()
+
+			app := newRetryTestApplication(tt.maxRetries)
+			res, _, err := app.doWithRetry(context.Background(), "test", time.Second, func(ctx context.Context) (*http.Request, error) {
+				return http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+			})
+			if err != nil {
+				t.Fatalf("doWithRetry() error = %v", err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("doWithRetry() status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("doWithRetry() sent %d requests, want %d", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+// newRetryTestApplication, returns an application which retries requests
+// 'maxRetries' times without any backoff delay.
+func newRetryTestApplication(maxRetries int) *application {
+	app := new(application)
+	app.infoLog = log.New(io.Discard, "", 0)
+	app.errorLog = log.New(io.Discard, "", 0)
+	app.seededRand = rand.New(
+		rand.NewSource(time.Now().UnixNano()))
+	app.client = new(http.Client)
+	app.userConfigurations.maxRetries = maxRetries
+	return app
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// cookiesFile, path to the file in which the session cookies of the CB
+// account are stored, so that the session is reused by the next runs.
+const cookiesFile = "./cookies.json"
+
+// errSessionRejected, is returned if Crunchbase rejects a request with 401 or
+// 403, i.e. the session of the CB account (or the API key) is not valid.
+var errSessionRejected = errors.New("the session was rejected by Crunchbase")
+
+// isSessionRejected, returns true if a response with the HTTP status code
+// 'code' means that the session is not valid (anymore).
+func isSessionRejected(code int) bool {
+	return code == http.StatusUnauthorized || code == http.StatusForbidden
+}
+
+// readStoredCookies, reads the cookies stored in the file at 'path' and
+// returns them with the time at which they were stored.
+func readStoredCookies(path string) ([]*http.Cookie, time.Time, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("unable to read cookies file %s: %w", path, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("unable to read cookies file %s: %w", path, err)
+	}
+
+	// Decode json from data the cookies file into []*http.Cookie.
+	var cookies []*http.Cookie
+	if err := json.Unmarshal(data, &cookies); err != nil {
+		return nil, time.Time{}, fmt.Errorf("unable to unmarshal cookies json file: %w", err)
+	}
+	return cookies, info.ModTime(), nil
+}
+
+// sessionExpiry, returns the time at which the first of the 'cookies', which
+// were stored at 'storedAt', expires. It returns the zero time if none of the
+// cookies has an expiry date, e.g. if they are valid until the session is
+// closed by Crunchbase.
+func sessionExpiry(cookies []*http.Cookie, storedAt time.Time) time.Time {
+	var expiry time.Time
+	for _, cookie := range cookies {
+		cookieExpiry := cookie.Expires
+		if cookie.MaxAge > 0 {
+			cookieExpiry = storedAt.Add(time.Duration(cookie.MaxAge) * time.Second)
+		}
+		if cookie.MaxAge < 0 {
+			cookieExpiry = storedAt
+		}
+		if cookieExpiry.IsZero() {
+			continue
+		}
+		if expiry.IsZero() || cookieExpiry.Before(expiry) {
+			expiry = cookieExpiry
+		}
+	}
+	return expiry
+}
+
+// cookiesExpired, returns true if the stored session 'cookies' cannot be
+// reused at 'now'.
+func cookiesExpired(cookies []*http.Cookie, storedAt, now time.Time) bool {
+	if len(cookies) == 0 {
+		return true
+	}
+	expiry := sessionExpiry(cookies, storedAt)
+	return !expiry.IsZero() && !now.Before(expiry)
+}
+
+// renewSession, logs in again after Crunchbase rejected the session, e.g.
+// because it expired during a long extraction. The new cookies are stored for
+// the next runs.
+func (app *application) renewSession(ctx context.Context) error {
+	app.errorLog.Print("Crunchbase rejected the session, logging in again.")
+	if err := app.login(ctx, true); err != nil {
+		return fmt.Errorf("unable to renew the session: %w", err)
+	}
+	app.infoLog.Print("Received new cookies from Crunchbase API.")
+	return nil
+}
+
+// authStatus, writes the status of the session stored in the cookies file
+// at 'now' to 'w'.
+func (app *application) authStatus(w io.Writer, now time.Time) error {
+	cookies, storedAt, err := readStoredCookies(cookiesFile)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(w, "No session is stored in %s, the next extraction logs in.\n", cookiesFile)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Session stored in %s at %s (%d cookies).\n", cookiesFile, storedAt.Format(time.RFC3339), len(cookies))
+	expiry := sessionExpiry(cookies, storedAt)
+	switch {
+	case cookiesExpired(cookies, storedAt, now):
+		fmt.Fprintln(w, "Status: expired, the next extraction logs in again.")
+	case expiry.IsZero():
+		fmt.Fprintln(w, "Status: reusable, the cookies have no expiry date (Crunchbase may still close the session).")
+	default:
+		fmt.Fprintf(w, "Status: reusable until %s.\n", expiry.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// logout, removes the stored session, the next extraction logs in again.
+func (app *application) logout() error {
+	err := os.Remove(cookiesFile)
+	if errors.Is(err, os.ErrNotExist) {
+		app.infoLog.Printf("No session is stored in %s.", cookiesFile)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to remove the stored session: %w", err)
+	}
+	app.infoLog.Printf("Removed the session stored in %s.", cookiesFile)
+	return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erodrigufer/UVC_data_pipeline/internal/cbfake"
+)
+
+func TestCookiesExpired(t *testing.T) {
+	storedAt := time.Date(2023, time.February, 14, 10, 0, 0, 0, time.UTC)
+	now := storedAt.Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		cookies []*http.Cookie
+		want    bool
+	}{
+		{
+			name:    "No cookies",
+			cookies: []*http.Cookie{},
+			want:    true,
+		},
+		{
+			name:    "Session cookie without expiry",
+			cookies: []*http.Cookie{{Name: "cid", Value: "1"}},
+			want:    false,
+		},
+		{
+			name:    "Expires in the future",
+			cookies: []*http.Cookie{{Name: "cid", Value: "1", Expires: now.Add(time.Minute)}},
+			want:    false,
+		},
+		{
+			name:    "One of the cookies expired",
+			cookies: []*http.Cookie{{Name: "cid", Value: "1"}, {Name: "authcookie", Value: "2", Expires: now.Add(-time.Minute)}},
+			want:    true,
+		},
+		{
+			name:    "Max age elapsed",
+			cookies: []*http.Cookie{{Name: "cid", Value: "1", MaxAge: 1800}},
+			want:    true,
+		},
+		{
+			name:    "Max age not elapsed",
+			cookies: []*http.Cookie{{Name: "cid", Value: "1", MaxAge: 7200}},
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cookiesExpired(tt.cookies, storedAt, now); got != tt.want {
+				t.Errorf("cookiesExpired() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthCommands(t *testing.T) {
+	// The cookies file is stored in the working directory.
+	chdirTemp(t)
+
+	server := cbfake.NewServer(cbfake.Config{Entities: 10})
+	defer server.Close()
+	app := newFixtureTestApplication()
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantStatus int
+		wantStored bool
+		// wantOutput, text contained in the output of authStatus.
+		wantOutput string
+	}{
+		{
+			name:       "Status without a session",
+			args:       []string{"auth", "status"},
+			wantOutput: "No session is stored",
+		},
+		{
+			name:       "Login",
+			args:       []string{"auth", "login", "--no-proxy"},
+			wantStored: true,
+			wantOutput: "Status: reusable",
+		},
+		{
+			name:       "Logout",
+			args:       []string{"auth", "logout"},
+			wantOutput: "No session is stored",
+		},
+		{
+			name:       "Logout without a session",
+			args:       []string{"auth", "logout"},
+			wantOutput: "No session is stored",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := runFakeCLI(t, server, tt.args...); status != tt.wantStatus {
+				t.Fatalf("exit status = %d, want %d", status, tt.wantStatus)
+			}
+			if _, err := os.Stat(cookiesFile); (err == nil) != tt.wantStored {
+				t.Errorf("session stored = %t, want %t", err == nil, tt.wantStored)
+			}
+			var output bytes.Buffer
+			if err := app.authStatus(&output, time.Now()); err != nil {
+				t.Fatalf("authStatus() error = %v", err)
+			}
+			if !strings.Contains(output.String(), tt.wantOutput) {
+				t.Errorf("authStatus() = %q, want it to contain %q", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestLoginEscapesCredentials(t *testing.T) {
+	server := cbfake.NewServer(cbfake.Config{Email: "analyst@uvc.com", Password: `se"c\ret`})
+	defer server.Close()
+
+	app := newFixtureTestApplication()
+	app.cbBaseURL = server.URL
+	app.cbUsername = "analyst@uvc.com"
+	app.cbPassword = `se"c\ret`
+	if err := app.configureClient(true); err != nil {
+		t.Fatalf("configureClient() error = %v", err)
+	}
+	if err := app.login(context.Background(), false); err != nil {
+		t.Fatalf("login() error = %v", err)
+	}
+	if server.Logins() != 1 {
+		t.Errorf("logins = %d, want 1", server.Logins())
+	}
+}
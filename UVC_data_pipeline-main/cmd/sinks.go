@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/erodrigufer/UVC_data_pipeline/internal/mongodb"
+)
+
+const (
+	// sinkFile, extracted pages are stored in an NDJSON output file.
+	sinkFile = "file"
+	// sinkMongo, extracted pages are upserted into the CB collection of a
+	// MongoDB instance.
+	sinkMongo = "mongo"
+)
+
+// documentSink, destination of the pages retrieved during an extraction.
+type documentSink interface {
+	// writePage, stores all documents of a page.
+	writePage(documents []document) error
+	// entitiesWritten, returns the number of documents stored so far.
+	entitiesWritten() int
+	// storedUUIDs, returns the UUIDs of the documents stored so far by the
+	// run, used to drop duplicates when a run is resumed.
+	storedUUIDs() (map[string]bool, error)
+	// updateCheckpoint, records the progress of the sink in a checkpoint.
+	updateCheckpoint(cp *checkpoint)
+	// location, human readable description of where the documents are
+	// stored, used in log messages.
+	location() string
+	// close, releases the sink after an interrupted run, which can be
+	// resumed later on.
+	close() error
+	// commit, finalizes the sink after a complete run, or after an
+	// interrupted run so that the entities retrieved so far are available at
+	// the normal output path. An interrupted run can still be resumed.
+	commit() error
+}
+
+// mongoSink, upserts every page into the MongoDB collection of the extracted
+// Crunchbase collection. A document replaces the document with the same UUID,
+// so that resuming a run or running the same query twice does not create
+// duplicates.
+type mongoSink struct {
+	db     *mongodb.MongoDBInstance
+	dbName string
+	coll   string
+	count  int
+	// startedAt, start of the run, the documents upserted by the run have a
+	// timestamp after it.
+	startedAt time.Time
+}
+
+// newSink, returns the sink 'sink' for the run of the checkpoint 'cp'. If
+// 'resume' is true, the sink continues where the run of 'cp' stopped.
+func (app *application) newSink(sink string, cp *checkpoint, resume bool) (documentSink, error) {
+	switch sink {
+	case sinkFile:
+		if resume {
+			return openNDJSONWriter(cp.OutputFile, cp.OutputOffset, cp.EntitiesWritten)
+		}
+		return createNDJSONWriter(cp.OutputFile)
+	case sinkMongo:
+		if app.mongoDB == nil {
+			return nil, fmt.Errorf("no connection to a MongoDB instance was configured for the mongo sink")
+		}
+		return &mongoSink{db: app.mongoDB, dbName: app.dbName, coll: app.mongoCollection(app.collection), count: cp.EntitiesWritten, startedAt: cp.StartedAt}, nil
+	default:
+		return nil, fmt.Errorf("unknown sink '%s', use '%s' or '%s'", sink, sinkFile, sinkMongo)
+	}
+}
+
+// writePage, upserts all documents of a page into the collection, matched by
+// their UUID.
+func (s *mongoSink) writePage(documents []document) error {
+	// Documents to be upserted into the db. Create an interface{} slice of the
+	// correct size.
+	docs := make([]interface{}, len(documents))
+	for i, document := range documents {
+		docs[i] = document
+	}
+	if err := s.db.UpsertMultipleDocuments(docs, "uuid", s.dbName, s.coll); err != nil {
+		return fmt.Errorf("failed to upsert page into DB: %w", err)
+	}
+	s.count += len(documents)
+	return nil
+}
+
+// entitiesWritten, returns the number of documents upserted so far by the
+// run.
+func (s *mongoSink) entitiesWritten() int {
+	return s.count
+}
+
+// storedUUIDs, returns the UUIDs of the documents upserted since the run
+// started, so that a resumed run does not count them again. Documents
+// upserted into the same collection by another run in the meantime are
+// included as well.
+func (s *mongoSink) storedUUIDs() (map[string]bool, error) {
+	uuids, err := mongodb.FindUUIDsSinceDate(s.db.Client, s.dbName, s.coll, s.startedAt)
+	if err != nil {
+		return nil, err
+	}
+	return stringSet(uuids), nil
+}
+
+// updateCheckpoint, records the number of upserted documents in a
+// checkpoint.
+func (s *mongoSink) updateCheckpoint(cp *checkpoint) {
+	cp.EntitiesWritten = s.count
+}
+
+// location, returns the name of the collection in which the documents are
+// upserted.
+func (s *mongoSink) location() string {
+	return fmt.Sprintf("the collection %s.%s", s.dbName, s.coll)
+}
+
+// close, the documents are already stored in the db, there is nothing to
+// release.
+func (s *mongoSink) close() error {
+	return nil
+}
+
+// commit, the documents are already stored in the db, there is nothing to
+// finalize.
+func (s *mongoSink) commit() error {
+	return nil
+}
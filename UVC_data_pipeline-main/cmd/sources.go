@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/erodrigufer/UVC_data_pipeline/internal/source"
+)
+
+// newSource, returns the source of the entities of 'query', starting after
+// the entity with the UUID 'afterId' (or with the first entity if 'afterId'
+// is an empty string). If a replay file was configured, the entities of the
+// file are returned, otherwise the entities are requested from Crunchbase
+// through the configured backend. If 'received' is not nil, it is called with
+// the body of every page received from Crunchbase, e.g. to archive it.
+func (app *application) newSource(query *Query, afterId string, received func(body []byte)) (source.Source[document], error) {
+	if app.replayFile != "" {
+		return app.newReplaySource(afterId)
+	}
+	search := func(ctx context.Context, afterId string, limit int) ([]byte, error) {
+		return app.extract(ctx, query, afterId, limit)
+	}
+	decode := app.collection.decode
+	if received != nil {
+		decode = func(body []byte) ([]document, error) {
+			received(body)
+			return app.collection.decode(body)
+		}
+	}
+	return source.NewSearch(search, decode, document.entityUuid, pageSize, afterId), nil
+}
+
+// newReplaySource, returns the source of the entities stored in the replay
+// file app.replayFile, e.g. the output file of a previous extraction. The
+// predicates of the query are not applied to a replay.
+func (app *application) newReplaySource(afterId string) (source.Source[document], error) {
+	src, err := source.OpenFile(app.replayFile, pageSize, storedDocumentDecoder(app.collection.newDocument))
+	if err != nil {
+		return nil, fmt.Errorf("unable to open replay file: %w", err)
+	}
+	if afterId != "" {
+		err := src.SkipThrough(func(d document) bool {
+			return d.entityUuid() == afterId
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to resume the replay of %s after %s: %w", app.replayFile, afterId, err)
+		}
+	}
+	return src, nil
+}
+
+// storedDocumentDecoder, returns a function which decodes a stored document
+// (e.g. a line of an output file) into a document created with 'newDocument'.
+func storedDocumentDecoder(newDocument func() document) func(record []byte) (document, error) {
+	return func(record []byte) (document, error) {
+		d := newDocument()
+		if err := json.Unmarshal(record, d); err != nil {
+			return nil, err
+		}
+		return d, nil
+	}
+}
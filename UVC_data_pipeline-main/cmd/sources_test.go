@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractReplay(t *testing.T) {
+	tests := []struct {
+		name        string
+		replay      string
+		wantEntries int
+		wantErr     error
+	}{
+		{
+			name:        "Replay of a complete output file",
+			replay:      "{\"uuid\":\"1a\"}\n{\"uuid\":\"2a\"}\n{\"uuid\":\"3a\"}\n",
+			wantEntries: 3,
+			wantErr:     nil,
+		},
+		{
+			name:        "Replay with a duplicate",
+			replay:      "{\"uuid\":\"1a\"}\n{\"uuid\":\"2a\"}\n{\"uuid\":\"1a\"}\n",
+			wantEntries: 2,
+			wantErr:     errNotReconciled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The checkpoint, the output file and the report are stored in
+			// the working directory.
+			chdirTemp(t)
+
+			if err := os.WriteFile("replay.ndjson", []byte(tt.replay), 0640); err != nil {
+				t.Fatal(err)
+			}
+			app := newRetryTestApplication(0)
+			app.query = defaultQuery()
+			app.collection = entityCollections[defaultCollectionId]
+			app.replayFile = "replay.ndjson"
+
+			err := app.extractCBData(context.Background(), extractOptions{sink: sinkFile})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("extractCBData() error = %v, want %v", err, tt.wantErr)
+			}
+
+			outputs, _ := filepath.Glob("CBData_*.ndjson")
+			if len(outputs) != 1 {
+				t.Fatalf("found output files %v, want exactly one", outputs)
+			}
+			fileData, err := os.ReadFile(outputs[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			documents, err := unmarshalFile(fileData, app.collection.newDocument)
+			if err != nil {
+				t.Fatalf("unmarshalFile() error = %v", err)
+			}
+			if len(documents) != tt.wantEntries {
+				t.Errorf("output contains %d documents, want %d", len(documents), tt.wantEntries)
+			}
+			if _, err := os.Stat(checkpointFile); !os.IsNotExist(err) {
+				t.Errorf("checkpoint still exists after a complete run: %v", err)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ require (
 	github.com/joho/godotenv v1.4.0
 	github.com/urfave/cli/v2 v2.17.1
 	go.mongodb.org/mongo-driver v1.11.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,261 @@
+// Package cbfake provides a local fake of the Crunchbase web API, which
+// serves generated organizations, so that the extraction can be tested end to
+// end without network access. The fake implements the login and the search
+// endpoints, including after_id paging, expired sessions and throttling.
+package cbfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SessionsPath, path of the login endpoint.
+	SessionsPath = "/v4/cb/sessions"
+	// SearchPath, path prefix of the search endpoints, e.g. of a list of
+	// organizations or of the search of people.
+	SearchPath = "/v4/data/"
+	// SessionCookie, name of the session cookie set by the login.
+	SessionCookie = "cid"
+)
+
+// Config, behaviour of a fake Server.
+type Config struct {
+	// Entities, number of generated organizations.
+	Entities int
+	// Email and Password, credentials accepted by the login. Any credentials
+	// are accepted if both are empty.
+	Email    string
+	Password string
+	// MaxLogins, number of logins which succeed, the following logins are
+	// answered with 401. If it is 0, all logins succeed.
+	MaxLogins int
+	// SessionRequests, number of search requests accepted per session, the
+	// following search requests of the session are answered with 401. If it
+	// is 0, sessions never expire.
+	SessionRequests int
+	// ThrottleEvery, every n-th search request is answered with 429. If it is
+	// 0, requests are never throttled.
+	ThrottleEvery int
+	// RetryAfter, value of the Retry-After header of throttled requests.
+	RetryAfter time.Duration
+	// MalformedEvery, every n-th organization has an invalid founding date
+	// and neither locations nor a last funding date. If it is 0, all
+	// organizations are valid.
+	MalformedEvery int
+	// RenamedProperties, new names of properties of all organizations,
+	// accessible by their Crunchbase name, e.g. to simulate a change of the
+	// Crunchbase API.
+	RenamedProperties map[string]string
+	// SkipEvery, every n-th organization is left out of the pages of the
+	// first pass through the organizations, as if the paging skipped it. The
+	// following passes, which start again at the first page, return all
+	// organizations. If it is 0, no organization is skipped.
+	SkipEvery int
+}
+
+// Server, fake of the Crunchbase web API listening on a local address, see
+// httptest.Server. Its URL replaces https://www.crunchbase.com.
+type Server struct {
+	*httptest.Server
+	config   Config
+	entities []json.RawMessage
+
+	mu sync.Mutex
+	// sessions, number of search requests served per session cookie.
+	sessions map[string]int
+	logins   int
+	searches int
+	// passes, number of searches which requested the first page, except
+	// the count requests of a single organization.
+	passes int
+}
+
+// NewServer, starts a fake Server which behaves as defined by 'config'. The
+// server must be closed with Close.
+func NewServer(config Config) *Server {
+	s := &Server{
+		config:   config,
+		entities: Organizations(config.Entities),
+		sessions: map[string]int{},
+	}
+	if config.MalformedEvery > 0 {
+		for i := config.MalformedEvery - 1; i < len(s.entities); i += config.MalformedEvery {
+			s.entities[i] = malformedOrganization(i)
+		}
+	}
+	if len(config.RenamedProperties) > 0 {
+		for i := range s.entities {
+			s.entities[i] = renameProperties(s.entities[i], config.RenamedProperties)
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(SessionsPath, s.handleLogin)
+	mux.HandleFunc(SearchPath, s.handleSearch)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Logins, returns the number of successful logins.
+func (s *Server) Logins() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logins
+}
+
+// Searches, returns the number of search requests, including rejected
+// ones.
+func (s *Server) Searches() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.searches
+}
+
+// ExpireSessions, expires all sessions, the next search requests are
+// answered with 401 until the client logs in again.
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = map[string]int{}
+}
+
+// handleLogin, creates a session and sets its cookie, like the login of
+// Crunchbase it answers with 201.
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	credentials := struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+		http.Error(w, "invalid login request", http.StatusBadRequest)
+		return
+	}
+	if (s.config.Email != "" || s.config.Password != "") && (credentials.Email != s.config.Email || credentials.Password != s.config.Password) {
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.Lock()
+	if s.config.MaxLogins > 0 && s.logins >= s.config.MaxLogins {
+		s.mu.Unlock()
+		http.Error(w, "too many logins", http.StatusUnauthorized)
+		return
+	}
+	s.logins++
+	session := fmt.Sprintf("session-%d", s.logins)
+	s.sessions[session] = 0
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: SessionCookie, Value: session, Path: "/"})
+	w.WriteHeader(http.StatusCreated)
+}
+
+// handleSearch, answers a search request with the page of organizations
+// after the payload's after_id.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if status := s.admit(r); status != http.StatusOK {
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", strconv.Itoa(int(s.config.RetryAfter/time.Second)))
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	payload := struct {
+		Limit   int    `json:"limit"`
+		AfterId string `json:"after_id"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Limit <= 0 {
+		http.Error(w, "invalid search payload", http.StatusBadRequest)
+		return
+	}
+	start := 0
+	if payload.AfterId != "" {
+		index, ok := s.index(payload.AfterId)
+		if !ok {
+			http.Error(w, "unknown after_id", http.StatusBadRequest)
+			return
+		}
+		start = index + 1
+	} else if payload.Limit > 1 {
+		s.mu.Lock()
+		s.passes++
+		s.mu.Unlock()
+	}
+	end := start + payload.Limit
+	if end > len(s.entities) {
+		end = len(s.entities)
+	}
+	page := s.entities[start:end]
+	if s.skipping() {
+		page = make([]json.RawMessage, 0, end-start)
+		for i := start; i < end; i++ {
+			if (i+1)%s.config.SkipEvery != 0 {
+				page = append(page, s.entities[i])
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Count    int               `json:"count"`
+		Entities []json.RawMessage `json:"entities"`
+	}{len(s.entities), page})
+}
+
+// skipping, returns true if organizations are left out of the pages, i.e.
+// during the first pass through the organizations.
+func (s *Server) skipping() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.config.SkipEvery > 0 && s.passes <= 1
+}
+
+// admit, returns the status of a search request 'r': 401 without a valid
+// session, 429 if the request is throttled and 200 otherwise.
+func (s *Server) admit(r *http.Request) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.searches++
+
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil {
+		return http.StatusUnauthorized
+	}
+	served, ok := s.sessions[cookie.Value]
+	if !ok || (s.config.SessionRequests > 0 && served >= s.config.SessionRequests) {
+		delete(s.sessions, cookie.Value)
+		return http.StatusUnauthorized
+	}
+	if s.config.ThrottleEvery > 0 && s.searches%s.config.ThrottleEvery == 0 {
+		return http.StatusTooManyRequests
+	}
+	s.sessions[cookie.Value] = served + 1
+	return http.StatusOK
+}
+
+// index, returns the index of the organization with the UUID 'uuid'.
+func (s *Server) index(uuid string) (int, bool) {
+	if !strings.HasPrefix(uuid, uuidPrefix) {
+		return 0, false
+	}
+	index, err := strconv.ParseInt(strings.TrimPrefix(uuid, uuidPrefix), 16, 64)
+	if err != nil || index < 0 || int(index) >= len(s.entities) {
+		return 0, false
+	}
+	return int(index), true
+}
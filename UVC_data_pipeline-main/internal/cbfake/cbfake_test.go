@@ -0,0 +1,87 @@
+package cbfake
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"testing"
+)
+
+// search, sends a search request for 'limit' entities after 'afterId' and
+// returns the status code and the decoded response.
+func search(t *testing.T, client *http.Client, url, afterId string, limit int) (int, []string, int) {
+	t.Helper()
+	payload, _ := json.Marshal(map[string]interface{}{"limit": limit, "after_id": afterId})
+	res, err := client.Post(url+SearchPath+"lists/organization.companies/1", "application/json", strings.NewReader(string(payload)))
+	if err != nil {
+		t.Fatalf("search request error = %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return res.StatusCode, nil, 0
+	}
+	page := struct {
+		Count    int `json:"count"`
+		Entities []struct {
+			Uuid string `json:"uuid"`
+		} `json:"entities"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+		t.Fatalf("unable to decode the search response: %v", err)
+	}
+	uuids := []string{}
+	for _, entity := range page.Entities {
+		uuids = append(uuids, entity.Uuid)
+	}
+	return res.StatusCode, uuids, page.Count
+}
+
+func TestServer(t *testing.T) {
+	server := NewServer(Config{Entities: 5, SessionRequests: 3, ThrottleEvery: 4})
+	defer server.Close()
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+
+	if status, _, _ := search(t, client, server.URL, "", 2); status != http.StatusUnauthorized {
+		t.Fatalf("search without session status = %d, want 401", status)
+	}
+	res, err := client.Post(server.URL+SessionsPath, "application/json", strings.NewReader(`{"email": "a", "password": "b"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("login status = %d, want 201", res.StatusCode)
+	}
+
+	tests := []struct {
+		name       string
+		afterId    string
+		wantStatus int
+		wantUUIDs  []string
+	}{
+		{name: "First page", afterId: "", wantStatus: http.StatusOK, wantUUIDs: []string{UUID(0), UUID(1)}},
+		{name: "Next page", afterId: UUID(1), wantStatus: http.StatusOK, wantUUIDs: []string{UUID(2), UUID(3)}},
+		{name: "Throttled", afterId: UUID(3), wantStatus: http.StatusTooManyRequests},
+		{name: "Last page", afterId: UUID(3), wantStatus: http.StatusOK, wantUUIDs: []string{UUID(4)}},
+		{name: "Expired session", afterId: UUID(4), wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, uuids, count := search(t, client, server.URL, tt.afterId, 2)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if status != http.StatusOK {
+				return
+			}
+			if count != 5 {
+				t.Errorf("count = %d, want 5", count)
+			}
+			if strings.Join(uuids, ",") != strings.Join(tt.wantUUIDs, ",") {
+				t.Errorf("entities = %v, want %v", uuids, tt.wantUUIDs)
+			}
+		})
+	}
+}
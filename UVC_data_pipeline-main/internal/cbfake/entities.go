@@ -0,0 +1,176 @@
+package cbfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// uuidPrefix, prefix of the UUIDs of generated organizations, followed by
+// the index of the organization in hexadecimal.
+const uuidPrefix = "00000000-0000-4000-8000-"
+
+// identifier, identifier of an entity in a response of Crunchbase.
+type identifier struct {
+	EntityDefId  string `json:"entity_def_id"`
+	Permalink    string `json:"permalink"`
+	Uuid         string `json:"uuid"`
+	Value        string `json:"value"`
+	LocationType string `json:"location_type,omitempty"`
+}
+
+// value, field of a response of Crunchbase with a value, e.g. a website.
+type value struct {
+	Value     string `json:"value"`
+	Precision string `json:"precision,omitempty"`
+}
+
+// money, amount of a response of Crunchbase, e.g. the total funding.
+type money struct {
+	Value    int    `json:"value"`
+	Currency string `json:"currency"`
+	ValueUSD int    `json:"value_usd"`
+}
+
+// organization, generated organization, shaped like the entities of a
+// search for organizations (see cmd/payloadbody.json).
+type organization struct {
+	Uuid       string `json:"uuid"`
+	Properties struct {
+		FoundedOn             value        `json:"founded_on"`
+		Website               value        `json:"website"`
+		Identifier            identifier   `json:"identifier"`
+		IpoStatus             string       `json:"ipo_status"`
+		FounderIdentifiers    []identifier `json:"founder_identifiers"`
+		Linkedin              value        `json:"linkedin"`
+		ShortDescription      string       `json:"short_description"`
+		OperatingStatus       string       `json:"operating_status"`
+		NumEmployeesEnum      string       `json:"num_employees_enum"`
+		FundingTotal          money        `json:"funding_total"`
+		NumFundingRounds      int          `json:"num_funding_rounds"`
+		LastEquityFundingType string       `json:"last_equity_funding_type"`
+		LastFundingType       string       `json:"last_funding_type"`
+		Categories            []identifier `json:"categories"`
+		LocationIdentifiers   []identifier `json:"location_identifiers"`
+		LastFundingAt         string       `json:"last_funding_at"`
+		ContactEmail          string       `json:"contact_email"`
+		RankOrgCompany        int          `json:"rank_org_company"`
+		FundingStage          string       `json:"funding_stage"`
+		LastFundingTotal      money        `json:"last_funding_total"`
+		NumFounders           int          `json:"num_founders"`
+		Twitter               value        `json:"twitter"`
+		SemrushGlobalRank     int          `json:"semrush_global_rank"`
+		NumEventAppearances   int          `json:"num_event_appearances"`
+	} `json:"properties"`
+}
+
+// locations, cities (with their region and country) of the generated
+// organizations.
+var locations = [][3]string{
+	{"Zurich", "Zurich", "Switzerland"},
+	{"Berlin", "Berlin", "Germany"},
+	{"Munich", "Bavaria", "Germany"},
+	{"Vienna", "Wien", "Austria"},
+}
+
+// Organizations, returns 'n' generated organizations as raw JSON entities.
+// The organizations are always the same for the same index, their UUIDs are
+// ascending.
+func Organizations(n int) []json.RawMessage {
+	entities := make([]json.RawMessage, n)
+	for i := range entities {
+		data, err := json.Marshal(newOrganization(i))
+		if err != nil {
+			// The generated organizations only contain encodable fields.
+			panic(fmt.Sprintf("unable to encode generated organization %d: %v", i, err))
+		}
+		entities[i] = data
+	}
+	return entities
+}
+
+// UUID, returns the UUID of the generated organization with the index
+// 'index'.
+func UUID(index int) string {
+	return fmt.Sprintf("%s%012x", uuidPrefix, index)
+}
+
+// malformedOrganization, returns the organization with the index 'index' as
+// raw JSON entity with an invalid founding date, without locations and
+// without a last funding date.
+func malformedOrganization(index int) json.RawMessage {
+	o := newOrganization(index)
+	o.Properties.FoundedOn = value{Value: "sometime in 2015", Precision: "year"}
+	o.Properties.LocationIdentifiers = nil
+	o.Properties.LastFundingAt = ""
+	data, err := json.Marshal(o)
+	if err != nil {
+		panic(fmt.Sprintf("unable to encode generated organization %d: %v", index, err))
+	}
+	return data
+}
+
+// renameProperties, returns the raw entity 'entity' with its properties
+// renamed as defined by 'renames'.
+func renameProperties(entity json.RawMessage, renames map[string]string) json.RawMessage {
+	e := struct {
+		Uuid       string                     `json:"uuid"`
+		Properties map[string]json.RawMessage `json:"properties"`
+	}{}
+	if err := json.Unmarshal(entity, &e); err != nil {
+		panic(fmt.Sprintf("unable to decode generated entity: %v", err))
+	}
+	for name, renamed := range renames {
+		if value, ok := e.Properties[name]; ok {
+			delete(e.Properties, name)
+			e.Properties[renamed] = value
+		}
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		panic(fmt.Sprintf("unable to encode generated entity %s: %v", e.Uuid, err))
+	}
+	return data
+}
+
+// newOrganization, generates the organization with the index 'index'.
+func newOrganization(index int) organization {
+	name := fmt.Sprintf("Company %d", index)
+	permalink := fmt.Sprintf("company-%d", index)
+	location := locations[index%len(locations)]
+	founded := time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, index)
+	funding := money{Value: 100000 * (index%10 + 1), Currency: "USD", ValueUSD: 100000 * (index%10 + 1)}
+
+	o := organization{Uuid: UUID(index)}
+	p := &o.Properties
+	p.Identifier = identifier{EntityDefId: "organization", Permalink: permalink, Uuid: o.Uuid, Value: name}
+	p.FoundedOn = value{Value: founded.Format("2006-01-02"), Precision: "day"}
+	p.Website = value{Value: fmt.Sprintf("https://www.%s.com", permalink)}
+	p.IpoStatus = "private"
+	p.FounderIdentifiers = []identifier{{EntityDefId: "person", Permalink: fmt.Sprintf("founder-%d", index), Uuid: fmt.Sprintf("10000000-0000-4000-8000-%012x", index), Value: fmt.Sprintf("Founder %d", index)}}
+	p.Linkedin = value{Value: fmt.Sprintf("https://www.linkedin.com/company/%s", permalink)}
+	p.ShortDescription = fmt.Sprintf("%s does deep tech", name)
+	p.OperatingStatus = "active"
+	p.NumEmployeesEnum = "c_00001_00010"
+	p.FundingTotal = funding
+	p.NumFundingRounds = 1
+	p.LastEquityFundingType = "seed"
+	p.LastFundingType = "seed"
+	p.Categories = []identifier{{EntityDefId: "category", Permalink: "software", Uuid: "c08b5441-a05b-9777-b7a6-012728caddd9", Value: "Software"}}
+	p.LocationIdentifiers = []identifier{
+		{EntityDefId: "location", Permalink: location[0], Value: location[0], LocationType: "city"},
+		{EntityDefId: "location", Permalink: location[1], Value: location[1], LocationType: "region"},
+		{EntityDefId: "location", Permalink: location[2], Value: location[2], LocationType: "country"},
+		{EntityDefId: "location", Permalink: "europe", Value: "Europe", LocationType: "continent"},
+	}
+	p.LastFundingAt = founded.AddDate(1, 0, 0).Format("2006-01-02")
+	p.ContactEmail = fmt.Sprintf("hello@%s.com", permalink)
+	p.RankOrgCompany = index + 1
+	p.FundingStage = "seed"
+	p.LastFundingTotal = funding
+	p.NumFounders = 1
+	p.Twitter = value{Value: fmt.Sprintf("https://twitter.com/%s", permalink)}
+	p.SemrushGlobalRank = 1000000 + index
+	p.NumEventAppearances = index % 5
+	return o
+}
@@ -0,0 +1,238 @@
+// Package fixture records the HTTP requests sent to Crunchbase and their
+// responses as fixtures, and replays them later, e.g. to run an extraction in
+// tests without network access. Credentials and session cookies are removed
+// before a fixture is stored.
+package fixture
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// redacted, replaces the values of credentials and session cookies in a
+// fixture.
+const redacted = "REDACTED"
+
+// sensitiveHeaders, request headers whose values are never stored.
+var sensitiveHeaders = []string{"Authorization", "Cookie", "X-Cb-User-Key"}
+
+// sensitiveFields, keys of a JSON request body (e.g. of the login request)
+// and query parameters whose values are never stored.
+var sensitiveFields = []string{"email", "password", "user_key"}
+
+// Interaction, a request and the response which was received for it.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request, sanitised HTTP request of an Interaction.
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// Response, sanitised HTTP response of an Interaction.
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body,omitempty"`
+}
+
+// Body, content of a request or a response. It is stored as a string if it
+// is valid UTF-8 (e.g. JSON), otherwise as base64, e.g. a compressed body.
+type Body []byte
+
+// MarshalJSON, encodes the body as a string or as a base64 object.
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(struct {
+		Base64 string `json:"base64"`
+	}{base64.StdEncoding.EncodeToString(b)})
+}
+
+// UnmarshalJSON, decodes a body encoded by MarshalJSON.
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*b = Body(text)
+		return nil
+	}
+	encoded := struct {
+		Base64 string `json:"base64"`
+	}{}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return fmt.Errorf("invalid body: %w", err)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded.Base64)
+	if err != nil {
+		return fmt.Errorf("invalid base64 body: %w", err)
+	}
+	*b = decoded
+	return nil
+}
+
+// newRequest, returns the sanitised fixture of 'req' with the body 'body'. It
+// fails if the body cannot be sanitised, see sanitizeBody.
+func newRequest(req *http.Request, body []byte) (Request, error) {
+	header := req.Header.Clone()
+	for _, name := range sensitiveHeaders {
+		if header.Get(name) != "" {
+			header.Set(name, redacted)
+		}
+	}
+	sanitized, err := sanitizeBody(body)
+	if err != nil {
+		return Request{}, fmt.Errorf("unable to sanitise the body of %s %s: %w", req.Method, sanitizeURL(req.URL), err)
+	}
+	return Request{
+		Method: req.Method,
+		URL:    sanitizeURL(req.URL),
+		Header: header,
+		Body:   sanitized,
+	}, nil
+}
+
+// newResponse, returns the sanitised fixture of 'res' with the body 'body'.
+// The names and attributes of session cookies are kept, so that a replayed
+// login still sets them, but their values are removed.
+func newResponse(res *http.Response, body []byte) Response {
+	header := res.Header.Clone()
+	if cookies := header.Values("Set-Cookie"); len(cookies) > 0 {
+		header.Del("Set-Cookie")
+		for _, cookie := range cookies {
+			header.Add("Set-Cookie", sanitizeCookie(cookie))
+		}
+	}
+	return Response{
+		StatusCode: res.StatusCode,
+		Header:     header,
+		Body:       body,
+	}
+}
+
+// sanitizeURL, returns 'u' without the values of sensitive query
+// parameters.
+func sanitizeURL(u *url.URL) string {
+	sanitized := *u
+	query := sanitized.Query()
+	changed := false
+	for _, field := range sensitiveFields {
+		if query.Has(field) {
+			query.Set(field, redacted)
+			changed = true
+		}
+	}
+	if changed {
+		sanitized.RawQuery = query.Encode()
+	}
+	return sanitized.String()
+}
+
+// sanitizeBody, returns a JSON object 'body' without the values of sensitive
+// fields. Empty bodies and other JSON values are returned unchanged. A body
+// which is not valid JSON cannot be checked for credentials, it returns an
+// error instead of being stored.
+func sanitizeBody(body []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return body, nil
+	}
+	if !json.Valid(body) {
+		return nil, errors.New("the body is not valid JSON")
+	}
+	object := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &object); err != nil {
+		return body, nil
+	}
+	changed := false
+	for _, field := range sensitiveFields {
+		if _, ok := object[field]; ok {
+			object[field] = json.RawMessage(`"` + redacted + `"`)
+			changed = true
+		}
+	}
+	if !changed {
+		return body, nil
+	}
+	sanitized, err := json.Marshal(object)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode the sanitised body: %w", err)
+	}
+	return sanitized, nil
+}
+
+// sanitizeCookie, replaces the value of the Set-Cookie header value
+// 'cookie', e.g. 'session=abc; Path=/' becomes 'session=REDACTED; Path=/'.
+func sanitizeCookie(cookie string) string {
+	nameValue, attributes, hasAttributes := strings.Cut(cookie, ";")
+	name, _, _ := strings.Cut(nameValue, "=")
+	sanitized := strings.TrimSpace(name) + "=" + redacted
+	if hasAttributes {
+		sanitized += ";" + attributes
+	}
+	return sanitized
+}
+
+// readBody, reads and closes 'body' and returns its content. A nil body is
+// empty.
+func readBody(body io.ReadCloser) ([]byte, error) {
+	if body == nil {
+		return nil, nil
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// sameBody, returns true if the bodies 'a' and 'b' are equal. JSON bodies
+// are compared by their content, so that the order of the keys of an object
+// does not matter.
+func sameBody(a, b []byte) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	var valueA, valueB interface{}
+	if json.Unmarshal(a, &valueA) != nil || json.Unmarshal(b, &valueB) != nil {
+		return false
+	}
+	normalizedA, errA := json.Marshal(valueA)
+	normalizedB, errB := json.Marshal(valueB)
+	return errA == nil && errB == nil && bytes.Equal(normalizedA, normalizedB)
+}
+
+// ReadFile, reads the interactions stored in the fixture file at 'path', one
+// JSON object per line.
+func ReadFile(path string) ([]Interaction, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open fixture file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	interactions := []Interaction{}
+	decoder := json.NewDecoder(f)
+	for {
+		var interaction Interaction
+		err := decoder.Decode(&interaction)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode interaction %d of fixture file %s: %w", len(interactions)+1, path, err)
+		}
+		interactions = append(interactions, interaction)
+	}
+	return interactions, nil
+}
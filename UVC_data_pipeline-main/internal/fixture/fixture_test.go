@@ -0,0 +1,174 @@
+package fixture
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret-session", Path: "/"})
+			w.WriteHeader(http.StatusCreated)
+		case "/search":
+			w.Write([]byte(`{"count": 1, "request": ` + string(body) + `}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "fixtures.ndjson")
+	recorder, err := NewRecorder(path, nil)
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+	recording := &http.Client{Transport: recorder}
+
+	login, err := http.NewRequest("POST", server.URL+"/login?user_key=secret-key", strings.NewReader(`{"email": "me@uvc.com", "password": "secret-password"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	login.Header.Set("X-cb-user-key", "secret-key")
+	if _, err := recording.Do(login); err != nil {
+		t.Fatalf("recorded login error = %v", err)
+	}
+	res, err := recording.Post(server.URL+"/search", "application/json", strings.NewReader(`{"limit": 1, "after_id": "1a"}`))
+	if err != nil {
+		t.Fatalf("recorded search error = %v", err)
+	}
+	recordedBody, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"me@uvc.com", "secret-password", "secret-session", "secret-key"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("fixture file contains the secret %q", secret)
+		}
+	}
+
+	server.Close()
+	replayer, err := OpenReplayer(path)
+	if err != nil {
+		t.Fatalf("OpenReplayer() error = %v", err)
+	}
+	replaying := &http.Client{Transport: replayer}
+
+	tests := []struct {
+		name       string
+		url        string
+		body       string
+		wantStatus int
+		wantBody   string
+		wantErr    error
+	}{
+		{
+			name:       "Login with other credentials",
+			url:        "/login?user_key=other-key",
+			body:       `{"password": "other-password", "email": "you@uvc.com"}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "Search with the keys in another order",
+			url:        "/search",
+			body:       `{"after_id": "1a", "limit": 1}`,
+			wantStatus: http.StatusOK,
+			wantBody:   string(recordedBody),
+		},
+		{
+			name:    "Search which was already served",
+			url:     "/search",
+			body:    `{"limit": 1, "after_id": "1a"}`,
+			wantErr: ErrNotRecorded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := replaying.Post(server.URL+tt.url, "application/json", strings.NewReader(tt.body))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("replayed request error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer res.Body.Close()
+			body, _ := io.ReadAll(res.Body)
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("status code = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if tt.wantBody != "" && string(body) != tt.wantBody {
+				t.Errorf("body = %s, want %s", body, tt.wantBody)
+			}
+		})
+	}
+	if unserved := replayer.Unserved(); unserved != 0 {
+		t.Errorf("Unserved() = %d, want 0", unserved)
+	}
+}
+
+func TestRecordUnsanitizedBody(t *testing.T) {
+	sent := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = true
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "fixtures.ndjson")
+	recorder, err := NewRecorder(path, nil)
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+	recording := &http.Client{Transport: recorder}
+
+	// An unescaped password makes the body invalid JSON.
+	if _, err := recording.Post(server.URL+"/login", "application/json", strings.NewReader(`{"email": "me@uvc.com", "password": "se"cret"}`)); err == nil {
+		t.Fatal("recorded request with an invalid JSON body, want error")
+	}
+	if sent {
+		t.Error("the request which cannot be sanitised was sent")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 0 {
+		t.Errorf("fixture file = %s, want empty", data)
+	}
+}
+
+func TestBodyJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		body Body
+	}{
+		{name: "Text", body: Body(`{"count": 1}`)},
+		{name: "Binary", body: Body{0x1f, 0x8b, 0xff, 0x00}},
+		{name: "Empty", body: Body{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.body.MarshalJSON()
+			if err != nil {
+				t.Fatalf("MarshalJSON() error = %v", err)
+			}
+			var got Body
+			if err := got.UnmarshalJSON(data); err != nil {
+				t.Fatalf("UnmarshalJSON() error = %v", err)
+			}
+			if string(got) != string(tt.body) {
+				t.Errorf("round trip = %v, want %v", got, tt.body)
+			}
+		})
+	}
+}
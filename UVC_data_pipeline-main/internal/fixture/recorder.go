@@ -0,0 +1,107 @@
+package fixture
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Recorder, http.RoundTripper which sends requests through another
+// RoundTripper and appends every sanitised request and its response to a
+// fixture file. Every interaction is written as soon as its response was
+// received, so that a cancelled run keeps the fixtures recorded so far.
+type Recorder struct {
+	path string
+	next http.RoundTripper
+	// mu, serialises the writes to the fixture file.
+	mu sync.Mutex
+}
+
+// NewRecorder, creates (or truncates) the fixture file at 'path' and returns
+// a Recorder which sends the requests through 'next', or through
+// http.DefaultTransport if 'next' is nil.
+func NewRecorder(path string, next http.RoundTripper) (*Recorder, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	// The working directory may change while recording.
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve the path of fixture file %s: %w", path, err)
+	}
+	path = absPath
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create fixture file %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("unable to create fixture file %s: %w", path, err)
+	}
+	return &Recorder{path: path, next: next}, nil
+}
+
+// RoundTrip, sends 'req' and records it with its response. The bodies of the
+// request and the response are read completely, the returned response can be
+// read as usual.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestBody, err := readBody(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the body of the recorded request: %w", err)
+	}
+	// A request which cannot be sanitised is not sent, so that its
+	// credentials are never stored.
+	request, err := newRequest(req, requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("unable to record the request: %w", err)
+	}
+	// The request must not be modified by a RoundTripper, send a copy with
+	// the body which was read.
+	sent := req.Clone(req.Context())
+	if req.Body != nil {
+		sent.Body = io.NopCloser(bytes.NewReader(requestBody))
+	}
+
+	res, err := r.next.RoundTrip(sent)
+	if err != nil {
+		return nil, err
+	}
+	responseBody, err := readBody(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the body of the recorded response: %w", err)
+	}
+	res.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	interaction := Interaction{
+		Request:  request,
+		Response: newResponse(res, responseBody),
+	}
+	if err := r.write(interaction); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// write, appends 'interaction' as a line to the fixture file.
+func (r *Recorder) write(interaction Interaction) error {
+	line, err := json.Marshal(interaction)
+	if err != nil {
+		return fmt.Errorf("unable to encode the recorded interaction: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_WRONLY, 0640)
+	if err != nil {
+		return fmt.Errorf("unable to open fixture file %s: %w", r.path, err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write to fixture file %s: %w", r.path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,103 @@
+package fixture
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ErrNotRecorded, is returned by a Replayer for a request without a recorded
+// response.
+var ErrNotRecorded = errors.New("no recorded response for the request")
+
+// Replayer, http.RoundTripper which serves recorded responses instead of
+// sending requests. A request is answered by the first interaction which was
+// not served yet and has the same method, URL and body, so that repeated
+// requests (e.g. retries or pages) are answered in the order in which they
+// were recorded.
+type Replayer struct {
+	interactions []Interaction
+	served       []bool
+	mu           sync.Mutex
+}
+
+// OpenReplayer, returns a Replayer of the interactions stored in the fixture
+// file at 'path'.
+func OpenReplayer(path string) (*Replayer, error) {
+	interactions, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayer(interactions), nil
+}
+
+// NewReplayer, returns a Replayer of 'interactions'.
+func NewReplayer(interactions []Interaction) *Replayer {
+	return &Replayer{
+		interactions: interactions,
+		served:       make([]bool, len(interactions)),
+	}
+}
+
+// RoundTrip, returns the recorded response of 'req', or an error wrapping
+// ErrNotRecorded if there is none.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the body of the replayed request: %w", err)
+	}
+	recorded, err := newRequest(req, body)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.interactions {
+		if r.served[i] || interaction.Request.Method != recorded.Method || interaction.Request.URL != recorded.URL {
+			continue
+		}
+		if !sameBody(interaction.Request.Body, recorded.Body) {
+			continue
+		}
+		r.served[i] = true
+		return interaction.Response.httpResponse(req), nil
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrNotRecorded, req.Method, recorded.URL)
+}
+
+// Unserved, returns the number of recorded interactions which were not
+// served yet.
+func (r *Replayer) Unserved() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	unserved := 0
+	for _, served := range r.served {
+		if !served {
+			unserved++
+		}
+	}
+	return unserved
+}
+
+// httpResponse, returns the recorded response as the response of 'req'.
+func (res Response) httpResponse(req *http.Request) *http.Response {
+	header := res.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", res.StatusCode, http.StatusText(res.StatusCode)),
+		StatusCode:    res.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(res.Body)),
+		ContentLength: int64(len(res.Body)),
+		Request:       req,
+	}
+}
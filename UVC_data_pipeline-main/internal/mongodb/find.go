@@ -60,6 +60,25 @@ func FindAfterDate(client *mongo.Client, database, collection string, date time.
 	return results, nil
 }
 
+// FindUUIDsSinceDate, find the UUIDs of all documents in a collection that have
+// a timestamp with a date equal to or after the date used as parameter, e.g.
+// the documents upserted since a run started.
+func FindUUIDsSinceDate(client *mongo.Client, database, collection string, date time.Time) ([]string, error) {
+	filter := bson.D{{"timestamp", bson.D{{"$gte", date}}}}
+	projectionOpt := options.Find().SetProjection(bson.D{{"uuid", 1}})
+
+	results, err := find(client, database, collection, filter, projectionOpt)
+	if err != nil {
+		return nil, fmt.Errorf("could not find uuids of documents since a certain date in db: %w", err)
+	}
+
+	uuids := make([]string, 0, len(results))
+	for _, result := range results {
+		uuids = append(uuids, result.UUID)
+	}
+	return uuids, nil
+}
+
 // func unmarshalLinkedCompanies(companies []bson.D) ([]LinkedInTargetCompany, error) {
 // 	results := make([]LinkedInTargetCompany, 0, 20)
 
@@ -6,6 +6,7 @@ import (
 	"os"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -113,3 +114,78 @@ func (db *MongoDBInstance) InsertMultipleDocuments(docs []interface{}, dbName, c
 
 	return nil
 }
+
+// UpsertMultipleDocuments, inserts or replaces the documents (parameter: docs)
+// in the database (par: dbName) inside the collection (par: coll). A document
+// replaces the document of the collection which has the same value in the
+// field 'key' (e.g. 'uuid'), if there is no such document it is inserted.
+func (db *MongoDBInstance) UpsertMultipleDocuments(docs []interface{}, key, dbName, coll string) error {
+	collection := db.Client.Database(dbName).Collection(coll)
+
+	models := make([]mongo.WriteModel, 0, len(docs))
+	for i, doc := range docs {
+		raw, err := bson.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("could not encode document %d as BSON: %w", i, err)
+		}
+		value, err := bson.Raw(raw).LookupErr(key)
+		if err != nil {
+			return fmt.Errorf("document %d has no field '%s': %w", i, key, err)
+		}
+		filter := bson.D{{Key: key, Value: value}}
+		models = append(models, mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(raw).SetUpsert(true))
+	}
+	if len(models) == 0 {
+		return nil
+	}
+
+	// Configure a timeout for upserting documents.
+	timeoutDB := time.Duration(120) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDB)
+	defer cancel()
+
+	// An unordered bulk write continues with the remaining documents, if a
+	// single document fails.
+	_, err := collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return fmt.Errorf("could not upsert (many) documents to collection in db: %w", err)
+	}
+
+	return nil
+}
+
+// MergeMultipleDocuments, applies the updates (parameter: updates, e.g. a
+// '$set' of some fields) to the documents of the collection (par: coll) in the
+// database (par: dbName) which are matched by the filters (par: filters). The
+// update updates[i] is applied to the document matched by filters[i], if there
+// is no such document a new document is inserted. Unlike
+// UpsertMultipleDocuments, the fields which are not updated keep their stored
+// value.
+func (db *MongoDBInstance) MergeMultipleDocuments(filters, updates []interface{}, dbName, coll string) error {
+	if len(filters) != len(updates) {
+		return fmt.Errorf("got %d filters for %d updates", len(filters), len(updates))
+	}
+	collection := db.Client.Database(dbName).Collection(coll)
+
+	models := make([]mongo.WriteModel, 0, len(updates))
+	for i := range updates {
+		models = append(models, mongo.NewUpdateOneModel().SetFilter(filters[i]).SetUpdate(updates[i]).SetUpsert(true))
+	}
+	if len(models) == 0 {
+		return nil
+	}
+
+	// Configure a timeout for merging documents.
+	timeoutDB := time.Duration(120) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDB)
+	defer cancel()
+
+	// An unordered bulk write continues with the remaining documents, if a
+	// single document fails.
+	_, err := collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return fmt.Errorf("could not merge (many) documents into collection in db: %w", err)
+	}
+
+	return nil
+}
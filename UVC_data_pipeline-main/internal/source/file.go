@@ -0,0 +1,123 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// File, Source of the entities stored in a local file, either as a JSON array
+// or as newline-delimited JSON (one entity per line). It is used to replay an
+// extraction offline and in tests.
+type File[T any] struct {
+	// records, raw JSON of every entity of the file which was not returned
+	// yet.
+	records  []json.RawMessage
+	count    int
+	decode   func(record []byte) (T, error)
+	pageSize int
+}
+
+// OpenFile, reads the file at 'path' and returns a File source which returns
+// pages of 'pageSize' entities, decoded with 'decode'.
+func OpenFile[T any](path string, pageSize int, decode func(record []byte) (T, error)) (*File[T], error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read data from file %s: %w", path, err)
+	}
+	return NewFile(data, pageSize, decode)
+}
+
+// NewFile, returns a File source of the entities in 'data', see OpenFile.
+func NewFile[T any](data []byte, pageSize int, decode func(record []byte) (T, error)) (*File[T], error) {
+	records, err := splitRecords(data)
+	if err != nil {
+		return nil, err
+	}
+	return &File[T]{records: records, count: len(records), decode: decode, pageSize: pageSize}, nil
+}
+
+// splitRecords, splits a JSON array or newline-delimited JSON into the raw
+// JSON of every entity.
+func splitRecords(data []byte) ([]json.RawMessage, error) {
+	records := []json.RawMessage{}
+
+	// A JSON array starts with '[', everything else is parsed as NDJSON.
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(data, &records); err != nil {
+			return nil, fmt.Errorf("unable to decode json data into a slice of entities: %w", err)
+		}
+		return records, nil
+	}
+
+	reader := bufio.NewReader(bytes.NewReader(data))
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("unable to read line %d: %w", lineNumber, err)
+		}
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			if !json.Valid(trimmed) {
+				return nil, fmt.Errorf("line %d is not a valid JSON entity", lineNumber)
+			}
+			records = append(records, json.RawMessage(trimmed))
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return records, nil
+}
+
+// SkipThrough, drops all entities up to and including the first entity for
+// which 'match' returns true, e.g. to resume a replay after the last stored
+// entity. If no entity matches, an error is returned and no entity is dropped.
+func (f *File[T]) SkipThrough(match func(entity T) bool) error {
+	for i, record := range f.records {
+		entity, err := f.decode(record)
+		if err != nil {
+			return fmt.Errorf("unable to decode entity %d: %w", i+1, err)
+		}
+		if match(entity) {
+			f.records = f.records[i+1:]
+			return nil
+		}
+	}
+	return fmt.Errorf("no matching entity was found in the file")
+}
+
+// Count, returns the number of entities in the file.
+func (f *File[T]) Count(ctx context.Context) (int, error) {
+	return f.count, nil
+}
+
+// NextPage, decodes and returns the next 'pageSize' entities of the file.
+func (f *File[T]) NextPage(ctx context.Context) ([]T, error) {
+	if len(f.records) == 0 {
+		return nil, io.EOF
+	}
+	size := f.pageSize
+	if size > len(f.records) {
+		size = len(f.records)
+	}
+	page := make([]T, 0, size)
+	for i, record := range f.records[:size] {
+		entity, err := f.decode(record)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode entity %d: %w", f.count-len(f.records)+i+1, err)
+		}
+		page = append(page, entity)
+	}
+	f.records = f.records[size:]
+	return page, nil
+}
+
+// Close, the file is read completely when it is opened, there is nothing to
+// release.
+func (f *File[T]) Close() error {
+	return nil
+}
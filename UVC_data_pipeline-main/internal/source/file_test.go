@@ -0,0 +1,104 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+)
+
+type entity struct {
+	Uuid string `json:"uuid"`
+}
+
+func decodeEntity(record []byte) (entity, error) {
+	var e entity
+	err := json.Unmarshal(record, &e)
+	return e, err
+}
+
+// drain, returns all pages of 'src'.
+func drain(t *testing.T, src Source[entity]) [][]entity {
+	t.Helper()
+	pages := [][]entity{}
+	for {
+		page, err := src.NextPage(context.Background())
+		if err == io.EOF {
+			return pages
+		}
+		if err != nil {
+			t.Fatalf("NextPage() error = %v", err)
+		}
+		pages = append(pages, page)
+	}
+}
+
+func TestFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		wantCount int
+		wantPages [][]entity
+		wantErr   bool
+	}{
+		{
+			name:      "JSON array",
+			data:      `[{"uuid":"1a"},{"uuid":"2a"},{"uuid":"3a"}]`,
+			wantCount: 3,
+			wantPages: [][]entity{{{Uuid: "1a"}, {Uuid: "2a"}}, {{Uuid: "3a"}}},
+		},
+		{
+			name:      "NDJSON with empty lines",
+			data:      "{\"uuid\":\"1a\"}\n\n{\"uuid\":\"2a\"}\n",
+			wantCount: 2,
+			wantPages: [][]entity{{{Uuid: "1a"}, {Uuid: "2a"}}},
+		},
+		{
+			name:      "Empty file",
+			data:      "",
+			wantCount: 0,
+			wantPages: [][]entity{},
+		},
+		{
+			name:    "NDJSON with a truncated line",
+			data:    "{\"uuid\":\"1a\"}\n{\"uu",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := NewFile([]byte(tt.data), 2, decodeEntity)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			count, _ := src.Count(context.Background())
+			if count != tt.wantCount {
+				t.Errorf("Count() = %d, want %d", count, tt.wantCount)
+			}
+			if got := drain(t, src); !reflect.DeepEqual(got, tt.wantPages) {
+				t.Errorf("pages = %v, want %v", got, tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestFileSkipThrough(t *testing.T) {
+	src, err := NewFile([]byte("{\"uuid\":\"1a\"}\n{\"uuid\":\"2a\"}\n{\"uuid\":\"3a\"}\n"), 10, decodeEntity)
+	if err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+	if err := src.SkipThrough(func(e entity) bool { return e.Uuid == "4a" }); err == nil {
+		t.Errorf("SkipThrough() of a missing entity, want error")
+	}
+	if err := src.SkipThrough(func(e entity) bool { return e.Uuid == "2a" }); err != nil {
+		t.Fatalf("SkipThrough() error = %v", err)
+	}
+	want := [][]entity{{{Uuid: "3a"}}}
+	if got := drain(t, src); !reflect.DeepEqual(got, want) {
+		t.Errorf("pages = %v, want %v", got, want)
+	}
+}
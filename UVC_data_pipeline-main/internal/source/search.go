@@ -0,0 +1,87 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// SearchFunc, sends a search request for at most 'limit' entities, which
+// follow the entity with the UUID 'afterId' (the first entities if 'afterId'
+// is an empty string), and returns the body of the response.
+type SearchFunc func(ctx context.Context, afterId string, limit int) ([]byte, error)
+
+// Search, Source of the entities of a paginated search API, such as the
+// Crunchbase web search (session cookies) or the Crunchbase API v4. The pages
+// are requested with after_id paging.
+type Search[T any] struct {
+	search SearchFunc
+	// decode, decodes the body of a response into entities.
+	decode func(body []byte) ([]T, error)
+	// uuid, returns the UUID of an entity, used as after_id of the next page.
+	uuid     func(entity T) string
+	pageSize int
+	// afterId, UUID of the last entity returned so far.
+	afterId string
+}
+
+// NewSearch, returns a Search which requests pages of 'pageSize' entities
+// with 'search' and decodes them with 'decode'. The first page follows the
+// entity with the UUID 'afterId', e.g. to resume a search, or is the first
+// page of the search if 'afterId' is an empty string.
+func NewSearch[T any](search SearchFunc, decode func(body []byte) ([]T, error), uuid func(entity T) string, pageSize int, afterId string) *Search[T] {
+	return &Search[T]{
+		search:   search,
+		decode:   decode,
+		uuid:     uuid,
+		pageSize: pageSize,
+		afterId:  afterId,
+	}
+}
+
+// Count, requests a single entity and returns the total count of entities
+// reported by the search API.
+func (s *Search[T]) Count(ctx context.Context) (int, error) {
+	body, err := s.search(ctx, "", 1)
+	if err != nil {
+		return 0, fmt.Errorf("unable to request the total count of entities: %w", err)
+	}
+	dataContainer := struct {
+		Count int `json:"count"`
+	}{}
+	if err := json.Unmarshal(body, &dataContainer); err != nil {
+		return 0, fmt.Errorf("unable to parse the total count of entities from the response: %w", err)
+	}
+	return dataContainer.Count, nil
+}
+
+// NextPage, requests and decodes the next page of entities. A page without
+// entities means that the search is exhausted, io.EOF is returned.
+func (s *Search[T]) NextPage(ctx context.Context) ([]T, error) {
+	body, err := s.search(ctx, s.afterId, s.pageSize)
+	if err != nil {
+		return nil, err
+	}
+	page, err := s.decode(body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode the page after %q: %w", s.afterId, err)
+	}
+	if len(page) == 0 {
+		return nil, io.EOF
+	}
+	// An entity without UUID (e.g. one which cannot be parsed) cannot be
+	// used as after_id, the next page follows the last entity with a UUID.
+	for i := len(page) - 1; i >= 0; i-- {
+		if uuid := s.uuid(page[i]); uuid != "" {
+			s.afterId = uuid
+			break
+		}
+	}
+	return page, nil
+}
+
+// Close, a Search does not hold any resources.
+func (s *Search[T]) Close() error {
+	return nil
+}
@@ -0,0 +1,79 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		name      string
+		entities  []entity
+		wantPages [][]entity
+		// wantAfterIds, after_id of every page request, the last request
+		// returns an empty page.
+		wantAfterIds []string
+	}{
+		{
+			name:         "Entities with UUID",
+			entities:     []entity{{Uuid: "1a"}, {Uuid: "2a"}, {Uuid: "3a"}},
+			wantPages:    [][]entity{{{Uuid: "1a"}, {Uuid: "2a"}}, {{Uuid: "3a"}}},
+			wantAfterIds: []string{"", "2a", "3a"},
+		},
+		{
+			// The entity without UUID is returned again, as the search
+			// can only continue after the last entity with a UUID.
+			name:         "Last entity without UUID",
+			entities:     []entity{{Uuid: "1a"}, {}, {Uuid: "2a"}},
+			wantPages:    [][]entity{{{Uuid: "1a"}, {}}, {{}, {Uuid: "2a"}}},
+			wantAfterIds: []string{"", "1a", "2a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			afterIds := []string{}
+			search := func(ctx context.Context, afterId string, limit int) ([]byte, error) {
+				// A search which does not advance would be drained
+				// forever.
+				if len(afterIds) > 10 {
+					t.Fatalf("more than 10 requests, after_ids = %v", afterIds)
+				}
+				afterIds = append(afterIds, afterId)
+				start := 0
+				for i, e := range tt.entities {
+					if afterId != "" && e.Uuid == afterId {
+						start = i + 1
+					}
+				}
+				end := start + limit
+				if end > len(tt.entities) {
+					end = len(tt.entities)
+				}
+				return json.Marshal(map[string]interface{}{"count": len(tt.entities), "entities": tt.entities[start:end]})
+			}
+			decode := func(body []byte) ([]entity, error) {
+				page := struct {
+					Entities []entity `json:"entities"`
+				}{}
+				err := json.Unmarshal(body, &page)
+				return page.Entities, err
+			}
+
+			src := NewSearch(search, decode, func(e entity) string { return e.Uuid }, 2, "")
+			count, err := src.Count(context.Background())
+			if err != nil || count != len(tt.entities) {
+				t.Fatalf("Count() = %d, %v, want %d", count, err, len(tt.entities))
+			}
+
+			afterIds = []string{}
+			if got := drain(t, src); !reflect.DeepEqual(got, tt.wantPages) {
+				t.Errorf("pages = %v, want %v", got, tt.wantPages)
+			}
+			if !reflect.DeepEqual(afterIds, tt.wantAfterIds) {
+				t.Errorf("after_ids = %v, want %v", afterIds, tt.wantAfterIds)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+// Package source provides the entities of a Crunchbase search page by page,
+// independently of where they come from, e.g. the Crunchbase web search or a
+// local file with already extracted entities.
+package source
+
+import "context"
+
+// Source, provider of the entities of a search. The entities are returned
+// page by page, in the order of the search.
+type Source[T any] interface {
+	// Count, returns the total number of entities of the search.
+	Count(ctx context.Context) (int, error)
+	// NextPage, returns the next page of entities. It returns io.EOF once
+	// all entities were returned.
+	NextPage(ctx context.Context) ([]T, error)
+	// Close, releases the resources used by the source.
+	Close() error
+}